@@ -0,0 +1,140 @@
+package achievements
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule awards an achievement once Earned holds for the activity of a user.
+type Rule struct {
+	model.Achievement
+	Earned func(stats model.ActivityStats) bool
+}
+
+// Rules are the built-in achievements.
+var Rules = []Rule{
+	{model.Achievement{Code: "first_purchase", Name: "First purchase",
+		Description: "Bought the first item in the store"},
+		func(s model.ActivityStats) bool { return s.Purchases > 0 }},
+	{model.Achievement{Code: "generous", Name: "Generous colleague",
+		Description: "Sent coins to 10 different colleagues"},
+		func(s model.ActivityStats) bool { return s.DistinctRecipients >= 10 }},
+	{model.Achievement{Code: "collector", Name: "Collector",
+		Description: "Bought every item of the catalog"},
+		func(s model.ActivityStats) bool { return s.CatalogSize > 0 && s.DistinctItems >= s.CatalogSize }},
+	{model.Achievement{Code: "appreciated", Name: "Appreciated",
+		Description: "Received 1000 coins from colleagues"},
+		func(s model.ActivityStats) bool { return s.ReceivedCoins >= 1000 }},
+}
+
+// Tracker evaluates the rules after store activity and keeps the awards.
+type Tracker struct {
+	achievements storage.AchievementStorage
+	rules        []Rule
+}
+
+// CreateTracker uses the built-in rules, rewards maps achievement codes to coin rewards.
+func CreateTracker(achievements storage.AchievementStorage, rewards map[string]int) *Tracker {
+	rules := make([]Rule, len(Rules))
+	copy(rules, Rules)
+	for i := range rules {
+		rules[i].Reward = rewards[rules[i].Code]
+	}
+	return &Tracker{achievements, rules}
+}
+
+// Evaluate awards every achievement username has earned and not received yet.
+func (t *Tracker) Evaluate(ctx context.Context, username string) (err error) {
+	ctx, span := tracing.Start(ctx, "Achievements.Evaluate")
+	defer func() { tracing.End(span, err) }()
+
+	awards, err := t.achievements.GetAwards(ctx, username)
+	if err != nil {
+		return err
+	}
+	awarded := make(map[string]bool, len(awards))
+	for _, a := range awards {
+		awarded[a.Code] = true
+	}
+	if len(awarded) == len(t.rules) {
+		return nil
+	}
+
+	stats, err := t.achievements.GetStats(ctx, username)
+	if err != nil {
+		return err
+	}
+	for _, rule := range t.rules {
+		if awarded[rule.Code] || !rule.Earned(*stats) {
+			continue
+		}
+		ok, err := t.achievements.Award(ctx, username, rule.Code, rule.Reward, "achievement: "+rule.Name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if rule.Reward > 0 {
+				metrics.CoinsGranted.WithLabelValues("reward").Add(float64(rule.Reward))
+			}
+			logging.FromContext(ctx).Info("achievement awarded", "username", username,
+				"achievement", rule.Code, "reward", rule.Reward)
+		}
+	}
+	return nil
+}
+
+// Awards returns the badges of username.
+func (t *Tracker) Awards(ctx context.Context, username string) (_ []model.Award, err error) {
+	ctx, span := tracing.Start(ctx, "Achievements.Awards")
+	defer func() { tracing.End(span, err) }()
+
+	awards, err := t.achievements.GetAwards(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range awards {
+		for _, rule := range t.rules {
+			if rule.Code == awards[i].Code {
+				awards[i].Name = rule.Name
+			}
+		}
+	}
+	return awards, nil
+}
+
+// List returns the definitions of all achievements.
+func (t *Tracker) List() []model.Achievement {
+	res := make([]model.Achievement, 0, len(t.rules))
+	for _, rule := range t.rules {
+		res = append(res, rule.Achievement)
+	}
+	return res
+}
+
+// ParseRewards reads coin rewards written as "first_purchase=10,collector=100".
+func ParseRewards(value string) (map[string]int, error) {
+	rewards := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, amount, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid achievement reward %q", part)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(amount))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid amount in achievement reward %q", part)
+		}
+		rewards[strings.TrimSpace(code)] = n
+	}
+	return rewards, nil
+}
@@ -0,0 +1,145 @@
+package apikeys
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// keyPrefix starts every key, so that leaked keys are easy to find by secret scanners.
+const keyPrefix = "msk_"
+
+// touchInterval limits how often the last use of a key is written.
+const touchInterval = time.Minute
+
+var ErrInvalidKey = fmt.Errorf("invalid api key")
+var ErrKeyExpired = fmt.Errorf("api key expired")
+var ErrKeyRevoked = fmt.Errorf("api key revoked")
+var ErrInvalidRequest = fmt.Errorf("name and at least one known scope are required")
+var ErrKeyNotFound = storage.ErrAPIKeyNotFound
+
+// Manager issues API keys and authenticates integrations with them. Keys look like
+// msk_<prefix>_<secret>, the prefix finds the key and only the SHA-256 of the key is stored.
+type Manager struct {
+	keys storage.APIKeyStorage
+}
+
+func CreateManager(keys storage.APIKeyStorage) *Manager {
+	return &Manager{keys}
+}
+
+// Create issues a key, it never expires when expiresAt is nil. The returned secret
+// is the only copy of the key.
+func (m *Manager) Create(ctx context.Context, admin string, name string, scopes []string,
+	expiresAt *time.Time) (_ string, _ *model.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "Manager.CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(scopes) == 0 {
+		return "", nil, ErrInvalidRequest
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(model.Scopes, scope) {
+			return "", nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidRequest, scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("%w: expiration is in the past", ErrInvalidRequest)
+	}
+
+	prefix := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err = rand.Read(prefix); err != nil {
+		return "", nil, err
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	plain := keyPrefix + hex.EncodeToString(prefix) + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	key, err := m.keys.CreateKey(ctx, model.APIKey{Name: name, Prefix: hex.EncodeToString(prefix), Scopes: scopes, CreatedBy: admin,
+		ExpiresAt: expiresAt}, hash(plain))
+	if err != nil {
+		return "", nil, err
+	}
+	logging.FromContext(ctx).Info("api key created", "id", key.ID, "name", name, "scopes", scopes, "admin", admin)
+	return plain, key, nil
+}
+
+func (m *Manager) List(ctx context.Context) (_ []model.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "Manager.ListAPIKeys")
+	defer func() { tracing.End(span, err) }()
+
+	return m.keys.GetKeys(ctx)
+}
+
+// Revoke disables a key at once, revoking a revoked key keeps its first revocation time.
+func (m *Manager) Revoke(ctx context.Context, admin string, id int) (_ *model.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "Manager.RevokeAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	key, err := m.keys.RevokeKey(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("api key revoked", "id", id, "name", key.Name, "admin", admin)
+	return key, nil
+}
+
+// Authenticate returns the key matching plain and records its use.
+func (m *Manager) Authenticate(ctx context.Context, plain string) (_ *model.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "Manager.AuthenticateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	rest, ok := strings.CutPrefix(plain, keyPrefix)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	key, stored, err := m.keys.GetKeyByPrefix(ctx, prefix)
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(plain)), []byte(stored)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, ErrKeyRevoked
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, ErrKeyExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		if err := m.keys.TouchKey(ctx, key.ID, now); err != nil {
+			logging.FromContext(ctx).Error("cannot record api key use", "id", key.ID, "error", err)
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
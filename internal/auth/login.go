@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+)
+
+var ErrEmptyCredentials = fmt.Errorf("username or password is empty or fields is not correct")
+var ErrReservedUsername = fmt.Errorf("username is reserved")
+var ErrUnknownUser = fmt.Errorf("user does not exist")
+var ErrWrongPassword = fmt.Errorf("password is not correct")
+
+// Login checks the password of username and returns a new token. Users seen for the
+// first time are registered with password, register creates the rest of their account.
+func Login(ctx context.Context, a Authenticator, users storage.AuthStorage,
+	register func(ctx context.Context, username string) error, username string, password string) (string, error) {
+	if username == "" || password == "" {
+		return "", ErrEmptyCredentials
+	}
+	if username == model.SystemUser {
+		return "", ErrReservedUsername
+	}
+	if !users.CheckContains(ctx, username) {
+		_, span := tracing.Start(ctx, "auth.HashPassword")
+		hash, err := a.HashPassword(password)
+		tracing.End(span, err)
+		if err != nil {
+			return "", err
+		}
+		if err = users.AddUser(ctx, username, hash); err != nil {
+			return "", err
+		}
+		if err = register(ctx, username); err != nil {
+			return "", err
+		}
+	} else {
+		hash, err := users.GetUserHash(ctx, username)
+		if err != nil {
+			metrics.FailedLogins.Inc()
+			logging.FromContext(ctx).Warn("login failed", "username", username, "error", err)
+			return "", ErrUnknownUser
+		}
+		_, span := tracing.Start(ctx, "auth.CheckPassword")
+		ok := a.CheckPassword(hash, password)
+		span.End()
+		if !ok {
+			metrics.FailedLogins.Inc()
+			logging.FromContext(ctx).Warn("login failed", "username", username, "error", "wrong password")
+			return "", ErrWrongPassword
+		}
+	}
+
+	_, span := tracing.Start(ctx, "auth.GenerateKey")
+	key, err := a.GenerateKey(username)
+	tracing.End(span, err)
+	return key, err
+}
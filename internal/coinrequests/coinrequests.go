@@ -0,0 +1,163 @@
+package coinrequests
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+const maxPayers = 100
+const maxNoteLength = 500
+
+var ErrInvalidRequest = fmt.Errorf("coin request is invalid")
+var ErrForbidden = fmt.Errorf("coin request belongs to another user")
+
+// Sender executes the transfer that pays a request.
+type Sender interface {
+	SendCoin(ctx context.Context, username string, receiver string, count int) (*model.Transaction, error)
+}
+
+// Manager lets users ask colleagues for coins. Requests not paid or rejected
+// within ttl expire.
+type Manager struct {
+	requests storage.CoinRequestStorage
+	sender   Sender
+	ttl      time.Duration
+	interval time.Duration
+}
+
+func CreateManager(requests storage.CoinRequestStorage, sender Sender, ttl time.Duration,
+	interval time.Duration) *Manager {
+	return &Manager{requests, sender, ttl, interval}
+}
+
+// Run expires overdue requests every interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if err := m.ExpireDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("coin request expiry failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) ExpireDue(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequests.ExpireDue")
+	defer func() { tracing.End(span, err) }()
+
+	n, err := m.requests.ExpireRequests(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logging.FromContext(ctx).Info("coin requests expired", "count", n)
+	}
+	return nil
+}
+
+// Create asks every payer for amount coins, one request per payer.
+func (m *Manager) Create(ctx context.Context, requester string, payers []string, amount int,
+	note string) (_ []model.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequests.Create")
+	defer func() { tracing.End(span, err) }()
+
+	if amount < 1 || len(payers) == 0 || len(payers) > maxPayers || len(note) > maxNoteLength {
+		return nil, ErrInvalidRequest
+	}
+	seen := make(map[string]bool, len(payers))
+	var unique []string
+	for _, payer := range payers {
+		if payer == "" || payer == requester || payer == model.SystemUser {
+			return nil, ErrInvalidRequest
+		}
+		if !seen[payer] {
+			seen[payer] = true
+			unique = append(unique, payer)
+		}
+	}
+
+	requests, err := m.requests.CreateRequests(ctx, requester, unique, amount, note, time.Now().Add(m.ttl))
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("coins requested", "requester", requester, "payers", len(unique),
+		"amount", amount)
+	return requests, nil
+}
+
+// List returns requests sent to username and requests made by username.
+func (m *Manager) List(ctx context.Context, username string) (incoming []model.CoinRequest,
+	outgoing []model.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequests.List")
+	defer func() { tracing.End(span, err) }()
+
+	incoming, err = m.requests.GetRequests(ctx, username, true, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	outgoing, err = m.requests.GetRequests(ctx, username, false, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return incoming, outgoing, nil
+}
+
+// Pay sends the requested coins with a regular transfer. The request is marked paid
+// first so it cannot be paid twice, and reopened when the transfer fails.
+func (m *Manager) Pay(ctx context.Context, username string, id int) (_ *model.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequests.Pay")
+	defer func() { tracing.End(span, err) }()
+
+	if err = m.checkPayer(ctx, username, id); err != nil {
+		return nil, err
+	}
+	request, err := m.requests.ResolveRequest(ctx, id, model.CoinRequestPaid)
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.sender.SendCoin(ctx, request.Payer, request.Requester, request.Amount)
+	if err != nil {
+		if reopenErr := m.requests.ReopenRequest(ctx, id); reopenErr != nil {
+			logging.FromContext(ctx).Error("cannot reopen coin request", "id", id, "error", reopenErr)
+		}
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("coin request paid", "id", id, "payer", request.Payer,
+		"requester", request.Requester, "amount", request.Amount)
+	return request, nil
+}
+
+func (m *Manager) Reject(ctx context.Context, username string, id int) (_ *model.CoinRequest, err error) {
+	ctx, span := tracing.Start(ctx, "CoinRequests.Reject")
+	defer func() { tracing.End(span, err) }()
+
+	if err = m.checkPayer(ctx, username, id); err != nil {
+		return nil, err
+	}
+	return m.requests.ResolveRequest(ctx, id, model.CoinRequestRejected)
+}
+
+func (m *Manager) checkPayer(ctx context.Context, username string, id int) error {
+	request, err := m.requests.GetRequest(ctx, id)
+	if err != nil {
+		return err
+	}
+	if request.Payer == username {
+		return nil
+	}
+	// other users must not learn about requests they are not part of
+	if request.Requester != username {
+		return storage.ErrCoinRequestNotFound
+	}
+	return ErrForbidden
+}
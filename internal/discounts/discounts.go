@@ -0,0 +1,175 @@
+package discounts
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSale = fmt.Errorf("sale is invalid")
+var ErrInvalidPromoCode = fmt.Errorf("promo code is invalid")
+
+var codePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// Manager keeps sales and promo codes and prices purchases with them.
+// Of several sales active for an item the largest one applies, a promo code
+// is applied on top of the sale price.
+type Manager struct {
+	discounts storage.DiscountStorage
+	now       func() time.Time
+}
+
+func CreateManager(discounts storage.DiscountStorage) *Manager {
+	return &Manager{discounts, time.Now}
+}
+
+func (m *Manager) CreateSale(ctx context.Context, sale model.Sale) (_ *model.Sale, err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.CreateSale")
+	defer func() { tracing.End(span, err) }()
+
+	if sale.StartsAt.IsZero() {
+		sale.StartsAt = m.now()
+	}
+	if !validDiscount(sale.Kind, sale.Value) || !sale.EndsAt.After(sale.StartsAt) {
+		return nil, ErrInvalidSale
+	}
+	sale.ID, err = m.discounts.CreateSale(ctx, sale)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("sale created", "id", sale.ID, "item", sale.Item, "kind", sale.Kind,
+		"value", sale.Value, "admin", sale.CreatedBy)
+	return &sale, nil
+}
+
+// ListSales returns all sales, or only the current ones when active is set.
+func (m *Manager) ListSales(ctx context.Context, active bool) (_ []model.Sale, err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.ListSales")
+	defer func() { tracing.End(span, err) }()
+
+	var at time.Time
+	if active {
+		at = m.now()
+	}
+	return m.discounts.GetSales(ctx, at)
+}
+
+func (m *Manager) EndSale(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.EndSale")
+	defer func() { tracing.End(span, err) }()
+
+	return m.discounts.EndSale(ctx, id, m.now())
+}
+
+func (m *Manager) CreatePromoCode(ctx context.Context, code model.PromoCode) (_ *model.PromoCode, err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.CreatePromoCode")
+	defer func() { tracing.End(span, err) }()
+
+	code.Code = strings.ToUpper(code.Code)
+	if code.StartsAt.IsZero() {
+		code.StartsAt = m.now()
+	}
+	if !codePattern.MatchString(code.Code) || !validDiscount(code.Kind, code.Value) ||
+		code.MaxUses < 0 || code.MaxUsesPerUser < 0 ||
+		(code.EndsAt != nil && !code.EndsAt.After(code.StartsAt)) {
+		return nil, ErrInvalidPromoCode
+	}
+	if err = m.discounts.CreatePromoCode(ctx, code); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("promo code created", "code", code.Code, "item", code.Item,
+		"kind", code.Kind, "value", code.Value, "admin", code.CreatedBy)
+	return &code, nil
+}
+
+func (m *Manager) ListPromoCodes(ctx context.Context) (_ []model.PromoCode, err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.ListPromoCodes")
+	defer func() { tracing.End(span, err) }()
+
+	return m.discounts.GetPromoCodes(ctx)
+}
+
+func (m *Manager) DisablePromoCode(ctx context.Context, code string) (err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.DisablePromoCode")
+	defer func() { tracing.End(span, err) }()
+
+	return m.discounts.DisablePromoCode(ctx, strings.ToUpper(code), m.now())
+}
+
+// Quote prices one purchase of item by username. A promo code is only checked
+// here, the purchase redeems it together with the payment.
+func (m *Manager) Quote(ctx context.Context, username string, item string, price int,
+	promoCode string) (_ *model.Purchase, err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.Quote")
+	defer func() { tracing.End(span, err) }()
+
+	now := m.now()
+	sales, err := m.discounts.GetSales(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	purchase := &model.Purchase{Username: username, Item: item, OriginalPrice: price, Price: price}
+	purchase.Price -= saleDiscount(sales, item, price)
+
+	if promoCode != "" {
+		code, err := m.discounts.GetPromoCode(ctx, strings.ToUpper(promoCode))
+		if errors.Is(err, storage.ErrPromoCodeNotFound) {
+			return nil, storage.ErrPromoCodeNotApplicable
+		}
+		if err != nil {
+			return nil, err
+		}
+		if code.StartsAt.After(now) || (code.EndsAt != nil && !code.EndsAt.After(now)) ||
+			(code.Item != "" && code.Item != item) {
+			return nil, storage.ErrPromoCodeNotApplicable
+		}
+		purchase.Price -= model.DiscountOf(code.Kind, code.Value, purchase.Price)
+		purchase.PromoCode = code.Code
+	}
+	purchase.Discount = purchase.OriginalPrice - purchase.Price
+	return purchase, nil
+}
+
+// ApplySales sets the current prices of catalog items.
+func (m *Manager) ApplySales(ctx context.Context, items []model.CatalogItem) (err error) {
+	ctx, span := tracing.Start(ctx, "Discounts.ApplySales")
+	defer func() { tracing.End(span, err) }()
+
+	sales, err := m.discounts.GetSales(ctx, m.now())
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].Price = items[i].OriginalPrice - saleDiscount(sales, items[i].Name, items[i].OriginalPrice)
+	}
+	return nil
+}
+
+// saleDiscount returns the largest discount of the sales applying to item.
+func saleDiscount(sales []model.Sale, item string, price int) int {
+	best := 0
+	for _, sale := range sales {
+		if sale.Item == "" || sale.Item == item {
+			best = max(best, model.DiscountOf(sale.Kind, sale.Value, price))
+		}
+	}
+	return best
+}
+
+func validDiscount(kind string, value int) bool {
+	switch kind {
+	case model.DiscountPercent:
+		return value > 0 && value <= 100
+	case model.DiscountFixed:
+		return value > 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"context"
+	"fmt"
+	"time"
+)
+
+const batchSize = 100
+
+// lease is how long claimed events are hidden from other relays while they are published.
+const lease = time.Minute
+
+const maxBackoff = 10 * time.Minute
+
+// Relay publishes the events of the outbox to all sinks in order. Events are marked
+// published only after every sink accepted them, so delivery is at least once.
+type Relay struct {
+	outbox    storage.OutboxStorage
+	sinks     []Sink
+	interval  time.Duration
+	backoff   time.Duration
+	retention time.Duration
+}
+
+// CreateRelay publishes every interval and removes events published more than retention ago.
+// A failed batch is retried after backoff, doubled with every failed attempt.
+func CreateRelay(outbox storage.OutboxStorage, interval time.Duration, backoff time.Duration,
+	retention time.Duration, sinks ...Sink) *Relay {
+	return &Relay{outbox, sinks, interval, backoff, retention}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.PublishDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("publishing events failed", "error", err)
+		}
+		if deleted, err := r.outbox.DeletePublished(ctx, time.Now().Add(-r.retention)); err != nil {
+			logging.FromContext(ctx).Error("cleaning up outbox failed", "error", err)
+		} else if deleted > 0 {
+			logging.FromContext(ctx).Debug("published events removed", "count", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes batches until the outbox has no due events or a sink fails.
+func (r *Relay) PublishDue(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Relay.PublishDue")
+	defer func() { tracing.End(span, err) }()
+
+	for {
+		events, err := r.outbox.ClaimEvents(ctx, batchSize, time.Now().Add(lease))
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		seqs := make([]int64, len(events))
+		for i, e := range events {
+			seqs[i] = e.Sequence
+		}
+
+		for _, sink := range r.sinks {
+			if pubErr := sink.Publish(ctx, events); pubErr != nil {
+				// the whole batch is retried, sinks that accepted it see it again
+				backoff := min(r.backoff<<min(events[0].Attempts, 16), maxBackoff)
+				if err := r.outbox.FailEvents(ctx, seqs, pubErr.Error(), time.Now().Add(backoff)); err != nil {
+					return err
+				}
+				return fmt.Errorf("sink %s: %w", sink.Name(), pubErr)
+			}
+			metrics.EventsPublished.WithLabelValues(sink.Name()).Add(float64(len(events)))
+		}
+		if err := r.outbox.MarkPublished(ctx, seqs); err != nil {
+			return err
+		}
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+}
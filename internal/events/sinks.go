@@ -0,0 +1,150 @@
+package events
+
+import (
+	"avito-merch-store/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink receives published events. A batch is either accepted as a whole or published
+// again later, sinks must tolerate events they have already seen.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, events []model.Event) error
+}
+
+// HTTPSink posts batches as {"events": [...]} to a webhook.
+type HTTPSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// CreateHTTPSink posts to url, token is sent as a bearer token when set.
+func CreateHTTPSink(url string, token string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{url, token, &http.Client{Timeout: timeout}}
+}
+
+func (s *HTTPSink) Name() string {
+	return "http"
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, events []model.Event) error {
+	body, err := json.Marshal(struct {
+		Events []model.Event `json:"events"`
+	}{events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("event webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Message is a single event as sent to a message broker.
+type Message struct {
+	// Subject is the NATS subject or the Kafka topic.
+	Subject string
+	// Key is the event ID, used as the Kafka message key.
+	Key     string
+	Data    []byte
+	Headers map[string]string
+}
+
+// Publisher is implemented by adapters of message broker clients (NATS, Kafka).
+// Adapters for NATS JetStream should map the Event-Id header to Nats-Msg-Id
+// so that the server deduplicates redelivered events.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// BrokerSink publishes every event to the subject <prefix>.<event type>.
+type BrokerSink struct {
+	publisher Publisher
+	prefix    string
+}
+
+func CreateBrokerSink(publisher Publisher, prefix string) *BrokerSink {
+	return &BrokerSink{publisher, prefix}
+}
+
+func (s *BrokerSink) Name() string {
+	return "broker"
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, events []model.Event) error {
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		err = s.publisher.Publish(ctx, Message{
+			Subject: s.prefix + "." + e.Type,
+			Key:     e.ID,
+			Data:    data,
+			Headers: map[string]string{"Event-Id": e.ID, "Event-Type": e.Type},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileSink appends events as JSON lines to a file.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func CreateFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Publish(_ context.Context, events []model.Event) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf.Bytes()); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package expiry
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const batchSize = 1000
+
+// Expirer periodically burns coin lots whose expiration date has passed.
+type Expirer struct {
+	lots     storage.LotStorage
+	interval time.Duration
+}
+
+func CreateExpirer(lots storage.LotStorage, interval time.Duration) *Expirer {
+	return &Expirer{lots, interval}
+}
+
+func (e *Expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if err := e.ExpireDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("coin expiry failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireDue burns all expired lots in batches.
+func (e *Expirer) ExpireDue(ctx context.Context) error {
+	for {
+		batchCtx, span := tracing.Start(ctx, "Expirer.ExpireDue")
+		lots, coins, err := e.lots.ExpireLots(batchCtx, batchSize)
+		tracing.End(span, err)
+		if err != nil {
+			return err
+		}
+		if lots > 0 {
+			metrics.CoinsExpired.Add(float64(coins))
+			logging.FromContext(ctx).Info("coins expired", "lots", lots, "coins", coins)
+		}
+		if lots < batchSize {
+			return nil
+		}
+	}
+}
+
+// ParsePolicies reads lifetimes per lot source written as "allowance=720h,transfer=2160h".
+func ParsePolicies(value string) (map[string]time.Duration, error) {
+	policies := make(map[string]time.Duration)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		source, ttl, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid expiry policy %q", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid lifetime in expiry policy %q", part)
+		}
+		policies[strings.TrimSpace(source)] = d
+	}
+	return policies, nil
+}
@@ -0,0 +1,202 @@
+package fulfillment
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"strings"
+)
+
+var ErrLocationRequired = fmt.Errorf("pickup location is not chosen yet")
+var ErrInvalidLocation = fmt.Errorf("pickup location name is required")
+var ErrInvalidStatus = fmt.Errorf("status must be placed or ready_for_pickup")
+
+// open are the statuses of orders not handed over yet, they can still be cancelled.
+var open = []string{model.OrderPlaced, model.OrderReady}
+
+// Manager runs the handover of purchased items. Buyers choose where to pick up their
+// orders, office managers prepare and deliver them, orders cancelled before delivery
+// are refunded.
+type Manager struct {
+	orders   storage.OrderStorage
+	notifier Notifier
+}
+
+// Notifier tells buyers about orders moved by office managers, it handles its errors itself.
+type Notifier interface {
+	OrderStatusChanged(ctx context.Context, order model.Order)
+}
+
+type Option func(*Manager)
+
+func WithNotifier(notifier Notifier) Option {
+	return func(m *Manager) {
+		m.notifier = notifier
+	}
+}
+
+func CreateManager(orders storage.OrderStorage, opts ...Option) *Manager {
+	m := &Manager{orders: orders}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Manager) List(ctx context.Context, username string) (_ []model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.List")
+	defer func() { tracing.End(span, err) }()
+
+	return m.orders.GetOrders(ctx, username)
+}
+
+// ChooseLocation sets the pickup location of an order of username that is not ready yet.
+func (m *Manager) ChooseLocation(ctx context.Context, username string, id int, locationID int) (_ *model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.ChooseLocation")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err = m.own(ctx, username, id); err != nil {
+		return nil, err
+	}
+	order, err := m.orders.SetLocation(ctx, id, locationID, []string{model.OrderPlaced})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("pickup location chosen", "order_id", id, "location_id", locationID)
+	return order, nil
+}
+
+// Cancel cancels an order of username before its delivery and refunds it.
+func (m *Manager) Cancel(ctx context.Context, username string, id int) (_ *model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.Cancel")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err = m.own(ctx, username, id); err != nil {
+		return nil, err
+	}
+	return m.cancel(ctx, id, "")
+}
+
+// Queue lists the orders waiting for office managers, status and locationID are optional.
+func (m *Manager) Queue(ctx context.Context, status string, locationID int) (_ []model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.Queue")
+	defer func() { tracing.End(span, err) }()
+
+	statuses := open
+	if status != "" {
+		if status != model.OrderPlaced && status != model.OrderReady {
+			return nil, ErrInvalidStatus
+		}
+		statuses = []string{status}
+	}
+	return m.orders.GetQueue(ctx, statuses, locationID)
+}
+
+// MarkReady tells the buyer that the order waits at its pickup location.
+func (m *Manager) MarkReady(ctx context.Context, manager string, id int) (_ *model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.MarkReady")
+	defer func() { tracing.End(span, err) }()
+
+	order, err := m.orders.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Location == nil {
+		return nil, ErrLocationRequired
+	}
+	return m.move(ctx, manager, id, []string{model.OrderPlaced}, model.OrderReady)
+}
+
+func (m *Manager) MarkDelivered(ctx context.Context, manager string, id int) (_ *model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.MarkDelivered")
+	defer func() { tracing.End(span, err) }()
+
+	return m.move(ctx, manager, id, []string{model.OrderReady}, model.OrderDelivered)
+}
+
+// CancelByManager cancels an order that cannot be fulfilled, e.g. when the item ran out.
+func (m *Manager) CancelByManager(ctx context.Context, manager string, id int) (_ *model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.CancelByManager")
+	defer func() { tracing.End(span, err) }()
+
+	return m.cancel(ctx, id, manager)
+}
+
+func (m *Manager) CreateLocation(ctx context.Context, name string, address string) (_ *model.PickupLocation, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.CreateLocation")
+	defer func() { tracing.End(span, err) }()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidLocation
+	}
+	location, err := m.orders.CreateLocation(ctx, name, strings.TrimSpace(address))
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("pickup location created", "id", location.ID, "name", name)
+	return location, nil
+}
+
+func (m *Manager) ListLocations(ctx context.Context, activeOnly bool) (_ []model.PickupLocation, err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.ListLocations")
+	defer func() { tracing.End(span, err) }()
+
+	return m.orders.GetLocations(ctx, activeOnly)
+}
+
+// DisableLocation hides a pickup location from buyers, orders already there are not moved.
+func (m *Manager) DisableLocation(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "Fulfillment.DisableLocation")
+	defer func() { tracing.End(span, err) }()
+
+	if err = m.orders.DisableLocation(ctx, id); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("pickup location disabled", "id", id)
+	return nil
+}
+
+// own returns an order of username, orders of others are reported as not found.
+func (m *Manager) own(ctx context.Context, username string, id int) (*model.Order, error) {
+	order, err := m.orders.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Username != username {
+		return nil, storage.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (m *Manager) move(ctx context.Context, manager string, id int, from []string, status string) (*model.Order, error) {
+	order, err := m.orders.SetStatus(ctx, id, from, status, manager)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("order status changed", "order_id", id, "status", status, "manager", manager)
+	m.notify(ctx, order)
+	return order, nil
+}
+
+func (m *Manager) cancel(ctx context.Context, id int, manager string) (*model.Order, error) {
+	order, err := m.orders.CancelOrder(ctx, id, open, manager)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("order cancelled", "order_id", id, "username", order.Username,
+		"item", order.Item, "refund", order.Refund, "manager", manager)
+	if manager != "" {
+		m.notify(ctx, order)
+	}
+	return order, nil
+}
+
+func (m *Manager) notify(ctx context.Context, order *model.Order) {
+	if m.notifier != nil {
+		m.notifier.OrderStatusChanged(ctx, *order)
+	}
+}
@@ -0,0 +1,176 @@
+package grants
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const maxBatchSize = 10000
+
+var ErrInvalidBatch = fmt.Errorf("grant batch is invalid")
+var ErrSelfApproval = fmt.Errorf("grant batch must be approved by another admin")
+
+// Granter issues and revokes coins on behalf of admins. Batches moving more than
+// approvalThreshold coins in total wait for approval by a second admin.
+type Granter struct {
+	grants            storage.GrantStorage
+	approvalThreshold int
+	notifier          Notifier
+}
+
+// Notifier tells users about coins granted or revoked, it handles its errors itself.
+type Notifier interface {
+	CoinsGranted(ctx context.Context, username string, amount int, reason string)
+}
+
+type Option func(*Granter)
+
+func WithNotifier(notifier Notifier) Option {
+	return func(g *Granter) {
+		g.notifier = notifier
+	}
+}
+
+func CreateGranter(grants storage.GrantStorage, approvalThreshold int, opts ...Option) *Granter {
+	g := &Granter{grants: grants, approvalThreshold: approvalThreshold}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Submit validates and stores a batch, then applies it right away
+// unless it needs approval. Invalid items are reported in the returned batch.
+func (g *Granter) Submit(ctx context.Context, admin string, items []model.GrantItem) (_ *model.GrantBatch, err error) {
+	ctx, span := tracing.Start(ctx, "Granter.Submit")
+	defer func() { tracing.End(span, err) }()
+
+	if invalid := validate(items); invalid != nil {
+		return invalid, ErrInvalidBatch
+	}
+
+	total := 0
+	for _, item := range items {
+		total += abs(item.Amount)
+	}
+	status := model.GrantApproved
+	if total > g.approvalThreshold {
+		status = model.GrantPendingApproval
+	}
+
+	id, err := g.grants.CreateBatch(ctx, admin, status, items)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("grant batch created", "id", id, "admin", admin,
+		"items", len(items), "total", total, "status", status)
+	if status == model.GrantPendingApproval {
+		return g.grants.GetBatch(ctx, id)
+	}
+	return g.apply(ctx, id, "")
+}
+
+// Approve applies a pending batch created by another admin.
+func (g *Granter) Approve(ctx context.Context, admin string, id int) (_ *model.GrantBatch, err error) {
+	ctx, span := tracing.Start(ctx, "Granter.Approve")
+	defer func() { tracing.End(span, err) }()
+
+	batch, err := g.grants.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != model.GrantPendingApproval {
+		return nil, storage.ErrGrantNotPending
+	}
+	if batch.CreatedBy == admin {
+		return nil, ErrSelfApproval
+	}
+	return g.apply(ctx, id, admin)
+}
+
+func (g *Granter) Reject(ctx context.Context, admin string, id int) (_ *model.GrantBatch, err error) {
+	ctx, span := tracing.Start(ctx, "Granter.Reject")
+	defer func() { tracing.End(span, err) }()
+
+	err = g.grants.RejectBatch(ctx, id, admin)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("grant batch rejected", "id", id, "admin", admin)
+	return g.grants.GetBatch(ctx, id)
+}
+
+func (g *Granter) Get(ctx context.Context, id int) (*model.GrantBatch, error) {
+	return g.grants.GetBatch(ctx, id)
+}
+
+func (g *Granter) List(ctx context.Context, status string) ([]model.GrantBatch, error) {
+	return g.grants.GetBatches(ctx, status, 100)
+}
+
+func (g *Granter) apply(ctx context.Context, id int, approvedBy string) (*model.GrantBatch, error) {
+	batch, err := g.grants.ApplyBatch(ctx, id, approvedBy)
+	if errors.Is(err, storage.ErrGrantFailed) {
+		logging.FromContext(ctx).Warn("grant batch failed", "id", id)
+		return batch, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range batch.Items {
+		if item.Amount > 0 {
+			metrics.CoinsGranted.WithLabelValues("grant").Add(float64(item.Amount))
+		} else {
+			metrics.CoinsGranted.WithLabelValues("revoke").Add(float64(-item.Amount))
+		}
+		if g.notifier != nil {
+			g.notifier.CoinsGranted(ctx, item.Username, item.Amount, item.Reason)
+		}
+	}
+	logging.FromContext(ctx).Info("grant batch applied", "id", id, "total", batch.Total)
+	return batch, nil
+}
+
+// validate returns nil for a valid batch, otherwise a copy of items with errors filled in.
+func validate(items []model.GrantItem) *model.GrantBatch {
+	if len(items) == 0 || len(items) > maxBatchSize {
+		return &model.GrantBatch{Status: model.GrantFailed, Items: []model.GrantItem{
+			{Error: fmt.Sprintf("batch must contain from 1 to %d items", maxBatchSize)},
+		}}
+	}
+	report := &model.GrantBatch{Status: model.GrantFailed, Items: make([]model.GrantItem, len(items))}
+	valid := true
+	for i, item := range items {
+		switch {
+		case item.Username == "":
+			item.Error = "username is empty"
+		case item.Username == model.SystemUser:
+			item.Error = "cannot grant coins to the system account"
+		case item.Amount == 0:
+			item.Error = "amount must not be zero"
+		case item.Reason == "":
+			item.Error = "reason is empty"
+		}
+		if item.Error != "" {
+			valid = false
+		}
+		report.Items[i] = item
+	}
+	if valid {
+		return nil
+	}
+	return report
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Pinger is implemented by storages that can verify their database connection.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Check func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Checker aggregates readiness checks of the application components.
+type Checker struct {
+	mu           sync.RWMutex
+	names        []string
+	checks       []Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+}
+
+// SetShuttingDown makes every following readiness probe fail,
+// so load balancers stop routing traffic before the server stops.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently and reports whether every component is up.
+func (c *Checker) Ready(ctx context.Context) (Report, bool) {
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := append([]Check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	statuses := make([]ComponentStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			if err := check(ctx); err != nil {
+				statuses[i] = ComponentStatus{Status: StatusDown, Error: err.Error()}
+				return
+			}
+			statuses[i] = ComponentStatus{Status: StatusUp}
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(names)+1)}
+	ready := true
+	for i, name := range names {
+		report.Components[name] = statuses[i]
+		if statuses[i].Status != StatusUp {
+			ready = false
+		}
+	}
+	if c.shuttingDown.Load() {
+		report.Components["server"] = ComponentStatus{Status: StatusDown, Error: "shutting down"}
+		ready = false
+	}
+	if !ready {
+		report.Status = StatusDown
+	}
+	return report, ready
+}
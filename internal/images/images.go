@@ -0,0 +1,114 @@
+package images
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxSize is the largest image accepted for upload.
+const MaxSize = 5 << 20
+
+var ErrUnsupportedImage = fmt.Errorf("image must be a PNG, JPEG, GIF or WebP file")
+var ErrImageTooLarge = fmt.Errorf("image must not be larger than %d MB", MaxSize>>20)
+
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Store keeps uploaded catalog images in a local directory and serves them under prefix.
+type Store struct {
+	dir    string
+	prefix string
+}
+
+// CreateStore creates dir when it does not exist yet. prefix is the URL path images are
+// served under, e.g. "/images/".
+func CreateStore(dir string, prefix string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, prefix: prefix}, nil
+}
+
+// Save writes the image read from r under a random name and returns its URL.
+// The type of the image is detected from its content.
+func (s *Store) Save(r io.Reader) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	head = head[:n]
+	ext, ok := extensions[http.DetectContentType(head)]
+	if !ok {
+		return "", ErrUnsupportedImage
+	}
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return "", err
+	}
+	filename := hex.EncodeToString(name) + ext
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	written, err := io.Copy(f, io.MultiReader(bytes.NewReader(head), io.LimitReader(r, MaxSize-int64(n)+1)))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if written > MaxSize {
+		return "", ErrImageTooLarge
+	}
+	if err := os.Rename(f.Name(), filepath.Join(s.dir, filename)); err != nil {
+		return "", err
+	}
+	return s.prefix + filename, nil
+}
+
+// Remove deletes an image saved before. URLs of other images are ignored.
+func (s *Store) Remove(url string) error {
+	name, ok := strings.CutPrefix(url, s.prefix)
+	if !ok || name == "" || strings.ContainsAny(name, `/\`) {
+		return nil
+	}
+	err := os.Remove(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the saved images, it is mounted under the prefix of the store.
+// Directory listings and unfinished uploads are not served.
+func (s *Store) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.StripPrefix(s.prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.ContainsAny(r.URL.Path, `/\`) || strings.HasPrefix(r.URL.Path, ".") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// Prefix is the URL path the images are served under.
+func (s *Store) Prefix() string {
+	return s.prefix
+}
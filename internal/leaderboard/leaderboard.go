@@ -0,0 +1,97 @@
+package leaderboard
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultLimit = 10
+const maxLimit = 100
+
+var ErrUnknownBoard = fmt.Errorf("board must be balance, senders, receivers or purchases")
+var ErrUnknownPeriod = fmt.Errorf("period must be week, month or all")
+
+// Manager serves leaderboards. Activity boards are read from daily aggregates
+// that are refreshed every interval, so they may lag behind by up to interval.
+type Manager struct {
+	boards   storage.LeaderboardStorage
+	interval time.Duration
+	now      func() time.Time
+}
+
+func CreateManager(boards storage.LeaderboardStorage, interval time.Duration) *Manager {
+	return &Manager{boards, interval, time.Now}
+}
+
+// Run refreshes the aggregates every interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		refreshCtx, span := tracing.Start(ctx, "Leaderboard.Refresh")
+		err := m.boards.Refresh(refreshCtx)
+		tracing.End(span, err)
+		if err != nil {
+			logging.FromContext(ctx).Error("leaderboard refresh failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Get returns the top of board over period. A non-positive limit selects the default.
+func (m *Manager) Get(ctx context.Context, board string, period string, limit int) (_ *model.Leaderboard, err error) {
+	ctx, span := tracing.Start(ctx, "Leaderboard.Get")
+	defer func() { tracing.End(span, err) }()
+
+	switch board {
+	case model.BoardBalance, model.BoardSenders, model.BoardReceivers, model.BoardPurchases:
+	default:
+		return nil, ErrUnknownBoard
+	}
+	since, err := m.periodStart(period)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	limit = min(limit, maxLimit)
+
+	entries, err := m.boards.GetBoard(ctx, board, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Leaderboard{Board: board, Period: period, Entries: entries}, nil
+}
+
+func (m *Manager) SetOptOut(ctx context.Context, username string, optOut bool) (err error) {
+	ctx, span := tracing.Start(ctx, "Leaderboard.SetOptOut")
+	defer func() { tracing.End(span, err) }()
+
+	return m.boards.SetOptOut(ctx, username, optOut)
+}
+
+// periodStart returns the first day counted for period, today included.
+func (m *Manager) periodStart(period string) (time.Time, error) {
+	now := m.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case model.PeriodWeek:
+		return today.AddDate(0, 0, -6), nil
+	case model.PeriodMonth:
+		return today.AddDate(0, 0, -29), nil
+	case model.PeriodAllTime:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, ErrUnknownPeriod
+	}
+}
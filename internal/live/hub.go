@@ -0,0 +1,98 @@
+package live
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// bufferSize is how many updates a slow stream may lag behind before updates are dropped.
+const bufferSize = 32
+
+const reconnectDelay = time.Second
+
+// Hub delivers live updates to the streams opened on this instance. Updates go through
+// Postgres LISTEN/NOTIFY, so a stream gets them whichever instance produced them.
+type Hub struct {
+	updates  storage.UpdateStorage
+	listener storage.UpdateStorage
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan model.Update]struct{}
+	done        chan struct{}
+}
+
+// CreateHub publishes with updates, listener is used only by Run and must be a separate instance.
+func CreateHub(updates storage.UpdateStorage, listener storage.UpdateStorage) *Hub {
+	return &Hub{updates: updates, listener: listener, subscribers: make(map[string]map[chan model.Update]struct{}),
+		done: make(chan struct{})}
+}
+
+// Push publishes update to the streams of username, failures are only logged.
+func (h *Hub) Push(ctx context.Context, username string, update model.Update) {
+	if err := h.updates.PublishUpdate(ctx, username, update); err != nil {
+		logging.FromContext(ctx).Error("cannot publish live update", "username", username,
+			"type", update.Type, "error", err)
+	}
+}
+
+// Subscribe opens a stream of the updates of username, the returned function closes it.
+func (h *Hub) Subscribe(username string) (<-chan model.Update, func()) {
+	ch := make(chan model.Update, bufferSize)
+	h.mu.Lock()
+	if h.subscribers[username] == nil {
+		h.subscribers[username] = make(map[chan model.Update]struct{})
+	}
+	h.subscribers[username][ch] = struct{}{}
+	h.mu.Unlock()
+	metrics.LiveStreams.Inc()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[username], ch)
+		if len(h.subscribers[username]) == 0 {
+			delete(h.subscribers, username)
+		}
+		h.mu.Unlock()
+		metrics.LiveStreams.Dec()
+	}
+}
+
+// Done is closed when Run returns, streams end then so that they do not hold up the shutdown.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Run receives updates from all instances until ctx is done.
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
+	for {
+		err := h.listener.Listen(ctx, h.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		logging.FromContext(ctx).Error("listening for live updates failed", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (h *Hub) deliver(username string, update model.Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[username] {
+		select {
+		case ch <- update:
+		default:
+			// the stream does not keep up, it catches up with the next update
+			metrics.LiveUpdatesDropped.Inc()
+		}
+	}
+}
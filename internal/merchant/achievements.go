@@ -0,0 +1,38 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/model"
+	"context"
+)
+
+// Achievements are evaluated after successful purchases and transfers.
+type Achievements interface {
+	Evaluate(ctx context.Context, username string) error
+	Awards(ctx context.Context, username string) ([]model.Award, error)
+}
+
+func WithAchievements(achievements Achievements) Option {
+	return func(m *Merchant) {
+		m.achievements = achievements
+	}
+}
+
+// evaluateAchievements never fails the operation that triggered it, errors are only logged.
+func (m *Merchant) evaluateAchievements(ctx context.Context, usernames ...string) {
+	if m.achievements == nil {
+		return
+	}
+	for _, username := range usernames {
+		if err := m.achievements.Evaluate(ctx, username); err != nil {
+			logging.FromContext(ctx).Error("cannot evaluate achievements", "username", username, "error", err)
+		}
+	}
+}
+
+func (m *Merchant) getBadges(ctx context.Context, username string) ([]model.Award, error) {
+	if m.achievements == nil {
+		return nil, nil
+	}
+	return m.achievements.Awards(ctx, username)
+}
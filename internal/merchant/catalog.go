@@ -0,0 +1,64 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidDetails = fmt.Errorf("category must be shorter than 64 characters, " +
+	"imageUrl must be an http(s) URL or a path")
+
+// UpdateItemDetails replaces the catalog metadata of item and returns its previous image URL,
+// so that an uploaded image no longer in use can be removed.
+func (m *Merchant) UpdateItemDetails(ctx context.Context, item string, details model.ItemDetails) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.UpdateItemDetails")
+	defer func() { tracing.End(span, err) }()
+
+	details.Category = strings.TrimSpace(details.Category)
+	if len(details.Category) > 64 || !validImageURL(details.ImageURL) {
+		return "", ErrInvalidDetails
+	}
+	details.Tags = normalizeTags(details.Tags)
+	previous, err := m.merch.UpdateDetails(ctx, item, details)
+	if err != nil {
+		return "", err
+	}
+	logging.FromContext(ctx).Info("item details updated", "item", item, "category", details.Category,
+		"tags", details.Tags, "display_order", details.DisplayOrder)
+	return previous, nil
+}
+
+// SetItemImage sets the image of item and returns the previous one.
+func (m *Merchant) SetItemImage(ctx context.Context, item string, imageURL string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.SetItemImage")
+	defer func() { tracing.End(span, err) }()
+
+	previous, err := m.merch.SetImage(ctx, item, imageURL)
+	if err != nil {
+		return "", err
+	}
+	logging.FromContext(ctx).Info("item image updated", "item", item, "image_url", imageURL)
+	return previous, nil
+}
+
+func validImageURL(url string) bool {
+	return url == "" || strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") ||
+		strings.HasPrefix(url, "/")
+}
+
+// normalizeTags lowercases tags and drops empty and repeated ones.
+func normalizeTags(tags []string) []string {
+	var res []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/storage"
+	"fmt"
+	"time"
+)
+
+var ErrTransferTooLarge = fmt.Errorf("amount exceeds the per-transfer limit")
+var ErrDailyLimitExceeded = storage.ErrDailyLimitExceeded
+var ErrWeeklyLimitExceeded = storage.ErrWeeklyLimitExceeded
+var ErrRecipientLimitExceeded = storage.ErrRecipientLimitExceeded
+var ErrAccountTooNew = fmt.Errorf("account is too new to send coins")
+
+// TransferLimits restrict SendCoin. Zero values disable the corresponding check.
+type TransferLimits struct {
+	MaxPerTransfer       int
+	DailyCap             int
+	WeeklyCap            int
+	MaxPerRecipientDaily int
+	MinAccountAge        time.Duration
+}
+
+// Option configures optional behaviour of the Merchant.
+type Option func(*Merchant)
+
+func WithTransferLimits(limits TransferLimits) Option {
+	return func(m *Merchant) {
+		m.limits = limits
+	}
+}
+
+// checkTransferLimits checks the limits that do not depend on earlier transfers.
+// The caps on sent amounts go to the storage and are checked inside the transfer.
+func (m *Merchant) checkTransferLimits(senderCreated time.Time, count int) error {
+	l := m.limits
+	if l.MaxPerTransfer > 0 && count > l.MaxPerTransfer {
+		return ErrTransferTooLarge
+	}
+	if l.MinAccountAge > 0 && time.Since(senderCreated) < l.MinAccountAge {
+		return ErrAccountTooNew
+	}
+	return nil
+}
+
+func (m *Merchant) transferCaps() storage.TransferCaps {
+	return storage.TransferCaps{
+		Daily:             m.limits.DailyCap,
+		Weekly:            m.limits.WeeklyCap,
+		PerRecipientDaily: m.limits.MaxPerRecipientDaily,
+	}
+}
@@ -1,9 +1,15 @@
 package merchant
 
 import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
 	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
 	"avito-merch-store/model"
+	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Merchant struct {
@@ -11,22 +17,43 @@ type Merchant struct {
 	inventory   storage.InventoryStorage
 	transaction storage.TransactionStorage
 	merch       storage.MerchStorage
+	limits      TransferLimits
+
+	pendingTimeout time.Duration
+	achievements   Achievements
+	pricing        Pricing
+	notifier       Notifier
+	updates        Updates
 }
 
 func CreateMerchant(users storage.UserStorage, inventory storage.InventoryStorage,
-	transaction storage.TransactionStorage, merch storage.MerchStorage) Merchant {
-	return Merchant{users, inventory, transaction, merch}
+	transaction storage.TransactionStorage, merch storage.MerchStorage, opts ...Option) Merchant {
+	m := Merchant{users: users, inventory: inventory, transaction: transaction, merch: merch}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
 }
 
 type InfoResponse struct {
-	Coins       int         `json:"coins"`
-	Inventory   []Item      `json:"inventory"`
-	CoinHistory CoinHistory `json:"coinHistory"`
+	Coins       int           `json:"coins"`
+	Inventory   []Item        `json:"inventory"`
+	CoinHistory CoinHistory   `json:"coinHistory"`
+	Expirations []Expiration  `json:"expirations,omitempty"`
+	Badges      []model.Award `json:"badges,omitempty"`
+}
+
+// Expiration is a part of the balance that burns at ExpiresAt unless spent before.
+type Expiration struct {
+	Amount    int       `json:"amount"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
+// Item is an item in an inventory, Quantity counts pieces of all its variants.
 type Item struct {
-	Type     string `json:"type"`
-	Quantity int    `json:"quantity"`
+	Type     string            `json:"type"`
+	Quantity int               `json:"quantity"`
+	Variants []VariantQuantity `json:"variants,omitempty"`
 }
 
 type CoinHistory struct {
@@ -40,12 +67,16 @@ type Transaction struct {
 	Amount   int
 }
 type TransactionFrom struct {
+	ID       int    `json:"id,omitempty"`
 	FromUser string `json:"fromUser,omitempty"`
 	Amount   int    `json:"amount"`
+	Status   string `json:"status"`
 }
 type TransactionTo struct {
+	ID     int    `json:"id,omitempty"`
 	ToUser string `json:"toUser,omitempty"`
 	Amount int    `json:"amount"`
+	Status string `json:"status"`
 }
 
 type ErrorResponse struct {
@@ -66,33 +97,52 @@ type SendCoinRequest struct {
 	Amount int    `json:"amount"`
 }
 
-var ErrNotEnoughCoins = fmt.Errorf("not enough coins")
+var ErrNotEnoughCoins = storage.ErrNotEnoughCoins
 var ErrIncorrectCount = fmt.Errorf("you can't send less than one coin")
 
-func (m *Merchant) AddUser(username string) error {
-	return m.users.Create(username, 1000)
+func (m *Merchant) AddUser(ctx context.Context, username string) (err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.AddUser")
+	defer func() { tracing.End(span, err) }()
 
+	err = m.users.Create(ctx, username, 1000)
+	if err != nil {
+		return err
+	}
+	metrics.Registrations.Inc()
+	logging.FromContext(ctx).Info("user registered", "username", username)
+	return nil
 }
 
-func (m *Merchant) GetInfoByUsername(username string) (*InfoResponse, error) {
-	user, err := m.users.GetByUsername(username)
+func (m *Merchant) GetInfoByUsername(ctx context.Context, username string) (_ *InfoResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.GetInfoByUsername")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := m.users.GetByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
-	inventory, err := m.inventory.GetByUserID(user.ID, -1)
+	inventory, err := m.inventory.GetByUserID(ctx, user.ID, -1)
 	if err != nil {
 		return nil, err
 	}
 
 	var result []Item
+	index := make(map[string]int)
 	for _, item := range inventory {
-		result = append(result, Item{
-			Type:     item.ItemName,
-			Quantity: item.Quantity,
-		})
+		i, ok := index[item.ItemName]
+		if !ok {
+			i = len(result)
+			index[item.ItemName] = i
+			result = append(result, Item{Type: item.ItemName})
+		}
+		result[i].Quantity += item.Quantity
+		if item.VariantID != 0 {
+			result[i].Variants = append(result[i].Variants,
+				VariantQuantity{Size: item.Size, Color: item.Color, Quantity: item.Quantity})
+		}
 	}
 
-	trans, err := m.GetTransactions(username)
+	trans, err := m.GetTransactions(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -101,72 +151,168 @@ func (m *Merchant) GetInfoByUsername(username string) (*InfoResponse, error) {
 	for _, item := range trans {
 		tr := Transaction{FromUser: item.SenderName, ToUser: item.ReceiverName, Amount: item.Amount}
 		if item.SenderName == username {
-			transRes.Sent = append(transRes.Sent, TransactionTo{tr.ToUser, tr.Amount})
+			transRes.Sent = append(transRes.Sent, TransactionTo{item.ID, tr.ToUser, tr.Amount, item.Status})
 		} else {
-			transRes.Received = append(transRes.Received, TransactionFrom{tr.FromUser, tr.Amount})
+			transRes.Received = append(transRes.Received, TransactionFrom{item.ID, tr.FromUser, tr.Amount, item.Status})
 		}
 	}
 
-	return &InfoResponse{Coins: user.Coins, Inventory: result, CoinHistory: transRes}, nil
+	lots, err := m.users.GetExpiringLots(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	var expirations []Expiration
+	for _, lot := range lots {
+		expirations = append(expirations, Expiration{Amount: lot.Remaining, ExpiresAt: *lot.ExpiresAt})
+	}
+
+	badges, err := m.getBadges(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InfoResponse{Coins: user.Coins, Inventory: result, CoinHistory: transRes, Expirations: expirations,
+		Badges: badges}, nil
 }
 
-func (m *Merchant) GetTransactions(username string) ([]model.Transaction, error) {
-	history, err := m.transaction.GetTransactionHistory(username, -1)
+func (m *Merchant) GetTransactions(ctx context.Context, username string) (_ []model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.GetTransactions")
+	defer func() { tracing.End(span, err) }()
+
+	history, err := m.transaction.GetTransactionHistory(ctx, username, -1)
 	if err != nil {
 		return nil, err
 	}
 	return history, nil
 
 }
-func (m *Merchant) Buy(username string, item string) error {
-	user, err := m.users.GetByUsername(username)
+
+// GetBalance returns the coins of username.
+func (m *Merchant) GetBalance(ctx context.Context, username string) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.GetBalance")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := m.users.GetByUsername(ctx, username)
+	if err != nil {
+		return 0, err
+	}
+	return user.Coins, nil
+}
+
+// Buy purchases item for username at its current price, promoCode is optional.
+// Items with variants need the size and the color of the variant to buy.
+func (m *Merchant) Buy(ctx context.Context, username string, item string, size string, color string,
+	promoCode string) (err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.Buy")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := m.users.GetByUsername(ctx, username)
 	if err != nil {
 		return err
 	}
-	price, err := m.merch.GetByName(item)
+	price, err := m.merch.GetByName(ctx, item)
 	if err != nil {
 		return err
 	}
-	if user.Coins < price {
-		return ErrNotEnoughCoins
+	variant, err := m.pickVariant(ctx, item, size, color)
+	if err != nil {
+		return err
+	}
+	variantID := 0
+	if variant != nil {
+		variantID = variant.ID
+		price = max(price+variant.PriceDelta, 0)
 	}
-	err = m.users.UpdateCoins(user.ID, user.Coins-price)
+	purchase, err := m.quote(ctx, user.Username, item, price, promoCode)
 	if err != nil {
 		return err
 	}
-	err = m.inventory.AddItems(user.ID, item, 1)
+	purchase.VariantID = variantID
+	if user.Coins < purchase.Price {
+		return ErrNotEnoughCoins
+	}
+	err = m.inventory.Purchase(ctx, *purchase)
 	if err != nil {
 		return err
 	}
+	metrics.Purchases.WithLabelValues(item).Inc()
+	logging.FromContext(ctx).Info("item bought", "username", username, "item", item, "variant_id", variantID,
+		"price", purchase.Price, "discount", purchase.Discount, "promo_code", purchase.PromoCode)
+	m.evaluateAchievements(ctx, username)
+	m.pushPurchase(ctx, username, item, variant, purchase.Price)
 	return nil
 }
 
-func (m *Merchant) SendCoin(username string, receiver string, count int) error {
-	user, err := m.users.GetByUsername(username)
+// SendCoin moves count coins from username to receiver. In the pending mode the
+// returned transfer waits for the receiver, otherwise it is already completed.
+func (m *Merchant) SendCoin(ctx context.Context, username string, receiver string, count int) (_ *model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.SendCoin")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := m.users.GetByUsername(ctx, username)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if user.Coins < count {
-		return ErrNotEnoughCoins
+		return nil, ErrNotEnoughCoins
 	}
 	if count < 1 {
-		return ErrIncorrectCount
+		return nil, ErrIncorrectCount
 	}
-	user2, err := m.users.GetByUsername(receiver)
+	user2, err := m.users.GetByUsername(ctx, receiver)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = m.users.UpdateCoins(user2.ID, user2.Coins+count)
+	err = m.checkTransferLimits(user.CreatedAt, count)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = m.users.UpdateCoins(user.ID, user.Coins-count)
+	if m.pendingTimeout > 0 {
+		t, err := m.sendPending(ctx, user, user2, count)
+		if err != nil {
+			return nil, err
+		}
+		m.notifyReceived(ctx, t)
+		m.pushTransfer(ctx, t)
+		return t, nil
+	}
+	t, err := m.transaction.Transfer(ctx, user.Username, user2.Username, count, m.transferCaps())
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = m.transaction.CreateTransaction(user.Username, user2.Username, count)
+	metrics.CoinsTransferred.Add(float64(count))
+	logging.FromContext(ctx).Info("coins sent", "from", username, "to", receiver, "amount", count)
+	m.evaluateAchievements(ctx, user.Username, user2.Username)
+	m.notifyReceived(ctx, t)
+	m.pushTransfer(ctx, t)
+	return t, nil
+}
+
+// GetCatalog lists the items matching filter at their current prices.
+func (m *Merchant) GetCatalog(ctx context.Context, filter model.CatalogFilter) (_ []model.CatalogItem, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.GetCatalog")
+	defer func() { tracing.End(span, err) }()
+
+	filter.Tag = strings.ToLower(filter.Tag)
+	items, err := m.merch.GetAll(ctx, filter)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	if m.pricing != nil {
+		if err = m.pricing.ApplySales(ctx, items); err != nil {
+			return nil, err
+		}
+	}
+	variants, err := m.merch.GetVariants(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range variants {
+		for i := range items {
+			if items[i].Name == v.Item {
+				items[i].Variants = append(items[i].Variants, v)
+			}
+		}
+	}
+	return items, nil
 }
@@ -0,0 +1,24 @@
+package merchant
+
+import (
+	"avito-merch-store/model"
+	"context"
+)
+
+// Notifier tells receivers about incoming transfers, it handles its errors itself.
+type Notifier interface {
+	CoinsReceived(ctx context.Context, transfer model.Transaction)
+}
+
+func WithNotifier(notifier Notifier) Option {
+	return func(m *Merchant) {
+		m.notifier = notifier
+	}
+}
+
+func (m *Merchant) notifyReceived(ctx context.Context, transfer *model.Transaction) {
+	if m.notifier == nil {
+		return
+	}
+	m.notifier.CoinsReceived(ctx, *transfer)
+}
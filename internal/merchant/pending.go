@@ -0,0 +1,157 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrTransferForbidden = fmt.Errorf("transfer belongs to another user")
+var ErrTransactionNotFound = storage.ErrTransactionNotFound
+var ErrTransactionNotPending = storage.ErrTransactionNotPending
+var ErrTransactionExpired = storage.ErrTransactionExpired
+
+// WithPendingTransfers switches SendCoin to the two-phase mode: the amount is reserved
+// from the sender and reaches the receiver only after acceptance. Transfers that are not
+// accepted, declined or cancelled within timeout go back to the sender.
+func WithPendingTransfers(timeout time.Duration) Option {
+	return func(m *Merchant) {
+		m.pendingTimeout = timeout
+	}
+}
+
+func (m *Merchant) sendPending(ctx context.Context, sender *model.User, receiver *model.User,
+	count int) (*model.Transaction, error) {
+	t, err := m.transaction.CreatePendingTransaction(ctx, sender.Username, receiver.Username, count,
+		time.Now().Add(m.pendingTimeout), m.transferCaps())
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("coins reserved", "from", sender.Username, "to", receiver.Username,
+		"amount", count, "transfer_id", t.ID)
+	return t, nil
+}
+
+func (m *Merchant) GetPendingTransfers(ctx context.Context, username string) (_ []model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.GetPendingTransfers")
+	defer func() { tracing.End(span, err) }()
+
+	return m.transaction.GetPendingTransactions(ctx, username)
+}
+
+// AcceptTransfer credits a pending transfer to its receiver.
+func (m *Merchant) AcceptTransfer(ctx context.Context, username string, id int) (_ *model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.AcceptTransfer")
+	defer func() { tracing.End(span, err) }()
+
+	t, err := m.resolveTransfer(ctx, username, id, model.TransactionCompleted)
+	if err != nil {
+		return nil, err
+	}
+	metrics.CoinsTransferred.Add(float64(t.Amount))
+	m.evaluateAchievements(ctx, t.SenderName, t.ReceiverName)
+	return t, nil
+}
+
+// DeclineTransfer returns a pending transfer to its sender on behalf of the receiver.
+func (m *Merchant) DeclineTransfer(ctx context.Context, username string, id int) (_ *model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.DeclineTransfer")
+	defer func() { tracing.End(span, err) }()
+
+	return m.resolveTransfer(ctx, username, id, model.TransactionDeclined)
+}
+
+// CancelTransfer returns a pending transfer to its sender on behalf of the sender.
+func (m *Merchant) CancelTransfer(ctx context.Context, username string, id int) (_ *model.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.CancelTransfer")
+	defer func() { tracing.End(span, err) }()
+
+	return m.resolveTransfer(ctx, username, id, model.TransactionCancelled)
+}
+
+func (m *Merchant) resolveTransfer(ctx context.Context, username string, id int, status string) (*model.Transaction, error) {
+	t, err := m.transaction.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	owner := t.ReceiverName
+	if status == model.TransactionCancelled {
+		owner = t.SenderName
+	}
+	if owner != username {
+		// the other side must not learn about transfers it is not part of
+		if t.SenderName != username && t.ReceiverName != username {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, ErrTransferForbidden
+	}
+	t, err = m.transaction.ResolvePendingTransaction(ctx, id, status)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("transfer resolved", "transfer_id", id, "status", status,
+		"from", t.SenderName, "to", t.ReceiverName, "amount", t.Amount)
+	m.pushTransfer(ctx, t)
+	return t, nil
+}
+
+// PendingReverter periodically returns pending transfers whose timeout is over to their senders.
+type PendingReverter struct {
+	transaction storage.TransactionStorage
+	interval    time.Duration
+}
+
+func CreatePendingReverter(transaction storage.TransactionStorage, interval time.Duration) *PendingReverter {
+	return &PendingReverter{transaction, interval}
+}
+
+func (p *PendingReverter) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.RevertExpired(ctx); err != nil {
+			logging.FromContext(ctx).Error("reverting pending transfers failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RevertExpired moves all overdue pending transfers to the expired status.
+func (p *PendingReverter) RevertExpired(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "PendingReverter.RevertExpired")
+	defer func() { tracing.End(span, err) }()
+
+	const batchSize = 100
+	for {
+		ids, err := p.transaction.GetExpiredPendingIDs(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			t, err := p.transaction.ResolvePendingTransaction(ctx, id, model.TransactionExpired)
+			if err != nil {
+				// accepted or cancelled in the meantime
+				if errors.Is(err, ErrTransactionNotPending) {
+					continue
+				}
+				return err
+			}
+			logging.FromContext(ctx).Info("pending transfer expired", "transfer_id", id,
+				"from", t.SenderName, "to", t.ReceiverName, "amount", t.Amount)
+		}
+		if len(ids) < batchSize {
+			return nil
+		}
+	}
+}
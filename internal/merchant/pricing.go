@@ -0,0 +1,35 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+)
+
+var ErrPromoCodeNotApplicable = storage.ErrPromoCodeNotApplicable
+
+// Pricing applies sales and promo codes to purchases.
+type Pricing interface {
+	// Quote prices a purchase, checking promoCode when it is set. The code is
+	// redeemed by the purchase itself.
+	Quote(ctx context.Context, username string, item string, price int, promoCode string) (*model.Purchase, error)
+	// ApplySales sets the current prices of catalog items.
+	ApplySales(ctx context.Context, items []model.CatalogItem) error
+}
+
+func WithPricing(pricing Pricing) Option {
+	return func(m *Merchant) {
+		m.pricing = pricing
+	}
+}
+
+func (m *Merchant) quote(ctx context.Context, username string, item string, price int,
+	promoCode string) (*model.Purchase, error) {
+	if m.pricing == nil {
+		if promoCode != "" {
+			return nil, ErrPromoCodeNotApplicable
+		}
+		return &model.Purchase{Username: username, Item: item, OriginalPrice: price, Price: price}, nil
+	}
+	return m.pricing.Quote(ctx, username, item, price, promoCode)
+}
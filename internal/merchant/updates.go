@@ -0,0 +1,49 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/model"
+	"context"
+)
+
+// Updates pushes changes to the live streams of users, it handles its errors itself.
+type Updates interface {
+	Push(ctx context.Context, username string, update model.Update)
+}
+
+func WithUpdates(updates Updates) Option {
+	return func(m *Merchant) {
+		m.updates = updates
+	}
+}
+
+func (m *Merchant) pushTransfer(ctx context.Context, transfer *model.Transaction) {
+	if m.updates == nil {
+		return
+	}
+	m.pushUpdate(ctx, transfer.SenderName, model.Update{Type: model.UpdateTransferSent, Transfer: transfer})
+	m.pushUpdate(ctx, transfer.ReceiverName, model.Update{Type: model.UpdateTransferReceived, Transfer: transfer})
+}
+
+func (m *Merchant) pushPurchase(ctx context.Context, username string, item string, variant *model.ItemVariant,
+	price int) {
+	if m.updates == nil {
+		return
+	}
+	purchase := &model.PurchaseUpdate{Item: item, Price: price}
+	if variant != nil {
+		purchase.Size, purchase.Color = variant.Size, variant.Color
+	}
+	m.pushUpdate(ctx, username, model.Update{Type: model.UpdatePurchase, Purchase: purchase})
+}
+
+// pushUpdate sends update with the current balance of username.
+func (m *Merchant) pushUpdate(ctx context.Context, username string, update model.Update) {
+	user, err := m.users.GetByUsername(ctx, username)
+	if err != nil {
+		logging.FromContext(ctx).Error("cannot read balance for live update", "username", username, "error", err)
+		return
+	}
+	update.Coins = user.Coins
+	m.updates.Push(ctx, username, update)
+}
@@ -0,0 +1,80 @@
+package merchant
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+)
+
+var ErrVariantRequired = fmt.Errorf("item has variants, size and color are required")
+var ErrVariantNotFound = storage.ErrVariantNotFound
+var ErrOutOfStock = storage.ErrOutOfStock
+var ErrInvalidVariant = fmt.Errorf("size or color is required, stock must not be negative")
+
+// VariantQuantity is the number of pieces of one variant in an inventory.
+type VariantQuantity struct {
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Quantity int    `json:"quantity"`
+}
+
+// pickVariant finds the variant of item to buy. Items without variants are bought as is.
+func (m *Merchant) pickVariant(ctx context.Context, item string, size string, color string) (*model.ItemVariant, error) {
+	if size != "" || color != "" {
+		return m.merch.GetVariant(ctx, item, size, color)
+	}
+	variants, err := m.merch.GetVariants(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) > 0 {
+		return nil, ErrVariantRequired
+	}
+	return nil, nil
+}
+
+func (m *Merchant) GetVariants(ctx context.Context, item string) (_ []model.ItemVariant, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.GetVariants")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err = m.merch.GetByName(ctx, item); err != nil {
+		return nil, err
+	}
+	return m.merch.GetVariants(ctx, item)
+}
+
+func (m *Merchant) CreateVariant(ctx context.Context, variant model.ItemVariant) (_ *model.ItemVariant, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.CreateVariant")
+	defer func() { tracing.End(span, err) }()
+
+	if variant.Size == "" && variant.Color == "" || variant.Stock < 0 {
+		return nil, ErrInvalidVariant
+	}
+	variant.ID, err = m.merch.CreateVariant(ctx, variant)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("variant created", "item", variant.Item, "variant", variant.Label(),
+		"stock", variant.Stock, "price_delta", variant.PriceDelta)
+	return &variant, nil
+}
+
+// UpdateVariant sets the stock and the price delta of a variant.
+func (m *Merchant) UpdateVariant(ctx context.Context, id int, stock int, priceDelta int) (_ *model.ItemVariant, err error) {
+	ctx, span := tracing.Start(ctx, "Merchant.UpdateVariant")
+	defer func() { tracing.End(span, err) }()
+
+	if stock < 0 {
+		return nil, ErrInvalidVariant
+	}
+	variant, err := m.merch.UpdateVariant(ctx, id, stock, priceDelta)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("variant updated", "item", variant.Item, "variant", variant.Label(),
+		"stock", stock, "price_delta", priceDelta)
+	return variant, nil
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"avito-merch-store/model"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Channel delivers notifications outside the store.
+type Channel interface {
+	// Name is one of the model.Channel* constants.
+	Name() string
+	Send(ctx context.Context, prefs model.NotificationPreferences, n model.Notification) error
+}
+
+// Webhook posts notifications as JSON to the URL chosen by the user. Requests carry
+// X-Notification-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>"> keyed with
+// the user's webhook secret, the timestamp is sent in X-Notification-Timestamp.
+type Webhook struct {
+	client *http.Client
+}
+
+func CreateWebhook(timeout time.Duration) *Webhook {
+	return &Webhook{&http.Client{Timeout: timeout}}
+}
+
+func (wh *Webhook) Name() string {
+	return model.ChannelWebhook
+}
+
+func (wh *Webhook) Send(ctx context.Context, prefs model.NotificationPreferences, n model.Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prefs.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notification-Id", strconv.Itoa(n.ID))
+	req.Header.Set("X-Notification-Timestamp", timestamp)
+	req.Header.Set("X-Notification-Signature", "sha256="+Sign(prefs.WebhookSecret, timestamp, body))
+
+	res, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Sign computes the webhook signature of body sent at timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Email sends notifications as plain text mails through an SMTP server.
+type Email struct {
+	addr    string
+	from    string
+	auth    smtp.Auth
+	timeout time.Duration
+}
+
+// CreateEmail sends through the server at addr (host:port). The server is logged
+// into when username is set. A mail that is not sent within timeout fails.
+func CreateEmail(addr string, from string, username string, password string, timeout time.Duration) *Email {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &Email{addr, from, auth, timeout}
+}
+
+func (e *Email) Name() string {
+	return model.ChannelEmail
+}
+
+func (e *Email) Send(ctx context.Context, prefs model.NotificationPreferences, n model.Notification) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", prefs.EmailAddress)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(n.Title + "\r\n")
+	if n.Body != "" {
+		msg.WriteString("\r\n" + n.Body + "\r\n")
+	}
+
+	// smtp.SendMail has neither timeouts nor cancellation, so the exchange is made by hand
+	dialer := net.Dialer{Timeout: e.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(e.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(e.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.auth != nil {
+		if err = c.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(e.from); err != nil {
+		return err
+	}
+	if err = c.Rcpt(prefs.EmailAddress); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
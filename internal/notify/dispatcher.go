@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"slices"
+	"time"
+)
+
+const batchSize = 100
+
+// lease is how long a claimed delivery is hidden from other instances while it is sent.
+const lease = 5 * time.Minute
+
+// Dispatcher sends pending deliveries through their channels. Failed deliveries are
+// retried with exponential backoff until maxAttempts is reached.
+type Dispatcher struct {
+	notifications storage.NotificationStorage
+	channels      map[string]Channel
+	interval      time.Duration
+	maxAttempts   int
+	backoff       time.Duration
+}
+
+func CreateDispatcher(notifications storage.NotificationStorage, interval time.Duration, maxAttempts int,
+	backoff time.Duration, channels ...Channel) *Dispatcher {
+	return &Dispatcher{notifications, channelMap(channels), interval, maxAttempts, backoff}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if err := d.DeliverDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("notification delivery failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends all deliveries that are due.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Dispatcher.DeliverDue")
+	defer func() { tracing.End(span, err) }()
+
+	for {
+		deliveries, err := d.notifications.ClaimDeliveries(ctx, batchSize, time.Now().Add(lease))
+		if err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			if err := d.deliver(ctx, delivery); err != nil {
+				return err
+			}
+		}
+		if len(deliveries) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery model.Delivery) error {
+	log := logging.FromContext(ctx).With("delivery_id", delivery.ID, "channel", delivery.Channel,
+		"username", delivery.Notification.Username)
+
+	prefs, err := d.notifications.GetPreferences(ctx, delivery.Notification.Username)
+	if err != nil {
+		return err
+	}
+	channel, ok := d.channels[delivery.Channel]
+	if !ok || !slices.Contains(enabledChannels(prefs), delivery.Channel) {
+		metrics.NotificationDeliveries.WithLabelValues(delivery.Channel, "dropped").Inc()
+		return d.notifications.FailDelivery(ctx, delivery.ID, "channel is disabled", nil)
+	}
+
+	sendErr := channel.Send(ctx, *prefs, delivery.Notification)
+	if sendErr == nil {
+		metrics.NotificationDeliveries.WithLabelValues(delivery.Channel, "sent").Inc()
+		return d.notifications.CompleteDelivery(ctx, delivery.ID)
+	}
+
+	attempts := delivery.Attempts + 1
+	if attempts >= d.maxAttempts {
+		metrics.NotificationDeliveries.WithLabelValues(delivery.Channel, "failed").Inc()
+		log.Warn("notification delivery given up", "attempts", attempts, "error", sendErr)
+		return d.notifications.FailDelivery(ctx, delivery.ID, sendErr.Error(), nil)
+	}
+	metrics.NotificationDeliveries.WithLabelValues(delivery.Channel, "retried").Inc()
+	retryAt := time.Now().Add(d.backoff << (attempts - 1))
+	log.Info("notification delivery will be retried", "attempts", attempts, "retry_at", retryAt, "error", sendErr)
+	return d.notifications.FailDelivery(ctx, delivery.ID, sendErr.Error(), &retryAt)
+}
@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"net/url"
+)
+
+const inboxSize = 100
+
+var ErrInvalidPreferences = fmt.Errorf("email needs a valid emailAddress, webhook needs an http(s) webhookUrl")
+var ErrChannelUnavailable = fmt.Errorf("notification channel is not configured")
+
+// Notifier tells users about coins they received, changes of their orders and grants.
+// Notifications are kept in the inbox and delivered through the outside channels
+// the user has chosen by the Dispatcher. Failing to notify never fails the operation
+// the notification is about.
+type Notifier struct {
+	notifications storage.NotificationStorage
+	channels      map[string]Channel
+}
+
+func CreateNotifier(notifications storage.NotificationStorage, channels ...Channel) *Notifier {
+	return &Notifier{notifications, channelMap(channels)}
+}
+
+// CoinsReceived notifies the receiver of a completed or pending transfer.
+func (n *Notifier) CoinsReceived(ctx context.Context, transfer model.Transaction) {
+	body := ""
+	if transfer.Status == model.TransactionPending && transfer.ExpiresAt != nil {
+		body = fmt.Sprintf("Accept the transfer before %s, otherwise the coins go back.",
+			transfer.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	n.notify(ctx, model.Notification{
+		Username: transfer.ReceiverName,
+		Kind:     model.NotificationCoinsReceived,
+		Title:    fmt.Sprintf("%s sent you %d coins", transfer.SenderName, transfer.Amount),
+		Body:     body,
+	})
+}
+
+// OrderStatusChanged notifies the buyer about an order moved by an office manager.
+func (n *Notifier) OrderStatusChanged(ctx context.Context, order model.Order) {
+	notification := model.Notification{Username: order.Username, Kind: model.NotificationOrderStatus}
+	switch order.Status {
+	case model.OrderReady:
+		notification.Title = fmt.Sprintf("Your %s is ready for pickup", order.Item)
+		if order.Location != nil {
+			notification.Body = fmt.Sprintf("Pick it up at %s. %s", order.Location.Name, order.Location.Address)
+		}
+	case model.OrderDelivered:
+		notification.Title = fmt.Sprintf("Your %s was handed over", order.Item)
+	case model.OrderCancelled:
+		notification.Title = fmt.Sprintf("Your order of %s was cancelled", order.Item)
+		if order.Refund > 0 {
+			notification.Body = fmt.Sprintf("%d coins were refunded.", order.Refund)
+		}
+	default:
+		return
+	}
+	n.notify(ctx, notification)
+}
+
+// CoinsGranted notifies about coins granted or, with a negative amount, revoked by admins.
+func (n *Notifier) CoinsGranted(ctx context.Context, username string, amount int, reason string) {
+	title := fmt.Sprintf("You were granted %d coins", amount)
+	if amount < 0 {
+		title = fmt.Sprintf("%d coins were revoked", -amount)
+	}
+	n.notify(ctx, model.Notification{
+		Username: username,
+		Kind:     model.NotificationGrant,
+		Title:    title,
+		Body:     reason,
+	})
+}
+
+func (n *Notifier) notify(ctx context.Context, notification model.Notification) {
+	ctx, span := tracing.Start(ctx, "Notifier.Notify")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	prefs, err := n.notifications.GetPreferences(ctx, notification.Username)
+	if err != nil {
+		logging.FromContext(ctx).Error("cannot read notification preferences",
+			"username", notification.Username, "error", err)
+		return
+	}
+	var channels []string
+	for _, name := range enabledChannels(prefs) {
+		if _, ok := n.channels[name]; ok {
+			channels = append(channels, name)
+		}
+	}
+	if !prefs.Inbox && len(channels) == 0 {
+		return
+	}
+	id, err := n.notifications.CreateNotification(ctx, notification, prefs.Inbox, channels)
+	if err != nil {
+		logging.FromContext(ctx).Error("cannot create notification", "username", notification.Username,
+			"kind", notification.Kind, "error", err)
+		return
+	}
+	logging.FromContext(ctx).Debug("notification created", "id", id, "username", notification.Username,
+		"kind", notification.Kind, "channels", channels)
+}
+
+func (n *Notifier) List(ctx context.Context, username string, unreadOnly bool) (_ []model.Notification, err error) {
+	ctx, span := tracing.Start(ctx, "Notifier.List")
+	defer func() { tracing.End(span, err) }()
+
+	return n.notifications.GetNotifications(ctx, username, unreadOnly, inboxSize)
+}
+
+// MarkRead marks the listed notifications as read, all of them when ids is empty.
+func (n *Notifier) MarkRead(ctx context.Context, username string, ids []int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "Notifier.MarkRead")
+	defer func() { tracing.End(span, err) }()
+
+	return n.notifications.MarkRead(ctx, username, ids)
+}
+
+func (n *Notifier) Preferences(ctx context.Context, username string) (_ *model.NotificationPreferences, err error) {
+	ctx, span := tracing.Start(ctx, "Notifier.Preferences")
+	defer func() { tracing.End(span, err) }()
+
+	return n.notifications.GetPreferences(ctx, username)
+}
+
+// SetPreferences validates and stores the channels of username. A new webhook
+// secret is generated whenever the webhook URL changes.
+func (n *Notifier) SetPreferences(ctx context.Context, username string,
+	prefs model.NotificationPreferences) (_ *model.NotificationPreferences, err error) {
+	ctx, span := tracing.Start(ctx, "Notifier.SetPreferences")
+	defer func() { tracing.End(span, err) }()
+
+	if prefs.Email {
+		addr, err := mail.ParseAddress(prefs.EmailAddress)
+		if err != nil {
+			return nil, ErrInvalidPreferences
+		}
+		// only the bare address is usable as the recipient of a mail
+		prefs.EmailAddress = addr.Address
+		if _, ok := n.channels[model.ChannelEmail]; !ok {
+			return nil, ErrChannelUnavailable
+		}
+	}
+	if prefs.Webhook {
+		u, err := url.Parse(prefs.WebhookURL)
+		if err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+			return nil, ErrInvalidPreferences
+		}
+		if _, ok := n.channels[model.ChannelWebhook]; !ok {
+			return nil, ErrChannelUnavailable
+		}
+	}
+
+	current, err := n.notifications.GetPreferences(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	prefs.Username = username
+	prefs.WebhookSecret = current.WebhookSecret
+	if prefs.WebhookURL != current.WebhookURL || prefs.WebhookSecret == "" {
+		prefs.WebhookSecret = ""
+		if prefs.WebhookURL != "" {
+			if prefs.WebhookSecret, err = newSecret(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = n.notifications.SetPreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("notification preferences updated", "username", username,
+		"inbox", prefs.Inbox, "email", prefs.Email, "webhook", prefs.Webhook)
+	return &prefs, nil
+}
+
+func enabledChannels(prefs *model.NotificationPreferences) []string {
+	var channels []string
+	if prefs.Email {
+		channels = append(channels, model.ChannelEmail)
+	}
+	if prefs.Webhook {
+		channels = append(channels, model.ChannelWebhook)
+	}
+	return channels
+}
+
+func channelMap(channels []Channel) map[string]Channel {
+	res := make(map[string]Channel, len(channels))
+	for _, c := range channels {
+		res[c.Name()] = c
+	}
+	return res
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
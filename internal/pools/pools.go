@@ -0,0 +1,147 @@
+package pools
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const batchSize = 100
+
+var ErrIncorrectAmount = fmt.Errorf("you can't contribute less than one coin")
+var ErrNotOrganizer = fmt.Errorf("only the organizer can cancel the pool")
+var ErrInvalidRecipient = fmt.Errorf("pool recipient is invalid")
+
+// Manager runs pooled purchases. A pool collects coins for the price of an item,
+// buys it for the recipient once the price is reached and refunds everyone
+// when it is cancelled or not completed within ttl.
+type Manager struct {
+	pools    storage.PoolStorage
+	ttl      time.Duration
+	interval time.Duration
+}
+
+func CreateManager(pools storage.PoolStorage, ttl time.Duration, interval time.Duration) *Manager {
+	return &Manager{pools, ttl, interval}
+}
+
+// Run closes expired pools every interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if err := m.ExpireDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("pool expiry failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireDue refunds the contributions of all expired pools.
+func (m *Manager) ExpireDue(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Pools.ExpireDue")
+	defer func() { tracing.End(span, err) }()
+
+	for {
+		ids, err := m.pools.GetExpiredPoolIDs(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			pool, err := m.pools.ClosePool(ctx, id, model.PoolExpired)
+			if errors.Is(err, storage.ErrPoolNotOpen) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("pool expired", "id", id, "item", pool.Item,
+				"refunded", pool.Collected)
+		}
+		if len(ids) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (m *Manager) Create(ctx context.Context, organizer string, item string, recipient string) (_ *model.Pool, err error) {
+	ctx, span := tracing.Start(ctx, "Pools.Create")
+	defer func() { tracing.End(span, err) }()
+
+	if recipient == "" || recipient == model.SystemUser {
+		return nil, ErrInvalidRecipient
+	}
+	id, err := m.pools.CreatePool(ctx, organizer, item, recipient, time.Now().Add(m.ttl))
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("pool created", "id", id, "organizer", organizer, "item", item,
+		"recipient", recipient)
+	return m.pools.GetPool(ctx, id)
+}
+
+func (m *Manager) Get(ctx context.Context, id int) (_ *model.Pool, err error) {
+	ctx, span := tracing.Start(ctx, "Pools.Get")
+	defer func() { tracing.End(span, err) }()
+
+	return m.pools.GetPool(ctx, id)
+}
+
+func (m *Manager) ListOpen(ctx context.Context) (_ []model.Pool, err error) {
+	ctx, span := tracing.Start(ctx, "Pools.ListOpen")
+	defer func() { tracing.End(span, err) }()
+
+	return m.pools.GetOpenPools(ctx, 1000)
+}
+
+// Contribute moves amount coins of username into the pool escrow.
+func (m *Manager) Contribute(ctx context.Context, username string, id int, amount int) (_ *model.Pool, err error) {
+	ctx, span := tracing.Start(ctx, "Pools.Contribute")
+	defer func() { tracing.End(span, err) }()
+
+	if amount < 1 {
+		return nil, ErrIncorrectAmount
+	}
+	pool, err := m.pools.Contribute(ctx, id, username, amount)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("pool contribution", "id", id, "username", username, "amount", amount,
+		"collected", pool.Collected, "target", pool.Target)
+	if pool.Status == model.PoolCompleted {
+		metrics.Purchases.WithLabelValues(pool.Item).Inc()
+		logging.FromContext(ctx).Info("pool completed", "id", id, "item", pool.Item,
+			"recipient", pool.Recipient, "price", pool.Target)
+	}
+	return pool, nil
+}
+
+// Cancel refunds all contributions, only the organizer may cancel a pool.
+func (m *Manager) Cancel(ctx context.Context, username string, id int) (_ *model.Pool, err error) {
+	ctx, span := tracing.Start(ctx, "Pools.Cancel")
+	defer func() { tracing.End(span, err) }()
+
+	pool, err := m.pools.GetPool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Organizer != username {
+		return nil, ErrNotOrganizer
+	}
+	pool, err = m.pools.ClosePool(ctx, id, model.PoolCancelled)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("pool cancelled", "id", id, "refunded", pool.Collected)
+	return pool, nil
+}
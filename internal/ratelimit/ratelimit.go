@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Policy describes a token bucket: it holds up to Burst tokens
+// and is refilled with Rate tokens per second.
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute builds a policy allowing n requests per minute with the given burst.
+func PerMinute(n int, burst int) Policy {
+	return Policy{Rate: float64(n) / 60, Burst: burst}
+}
+
+type Limiter interface {
+	// Allow takes one token from the bucket identified by key. When the bucket is empty
+	// it returns false and the time after which the next token becomes available.
+	Allow(ctx context.Context, key string, policy Policy) (bool, time.Duration, error)
+}
+
+// Take applies the token bucket algorithm to a bucket that had tokens at elapsed ago.
+// It returns the remaining tokens, whether a token was taken and the wait for the next one.
+func Take(tokens float64, elapsed time.Duration, policy Policy) (float64, bool, time.Duration) {
+	tokens = math.Min(float64(policy.Burst), tokens+elapsed.Seconds()*policy.Rate)
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	if policy.Rate <= 0 {
+		return tokens, false, time.Hour
+	}
+	wait := time.Duration((1 - tokens) / policy.Rate * float64(time.Second))
+	return tokens, false, wait
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/tracing"
+	"context"
+	"time"
+)
+
+// IdleBuckets is implemented by limiters that keep buckets outside the process,
+// where nothing else removes them.
+type IdleBuckets interface {
+	// DeleteIdle removes the buckets not used for idle and returns how many were removed.
+	DeleteIdle(ctx context.Context, idle time.Duration) (int, error)
+}
+
+// RefillTime is how long the bucket of the slowest policy takes to fill up from empty.
+// A bucket idle for longer is full and equal to a new one.
+func RefillTime(policies map[string]Policy) time.Duration {
+	var longest time.Duration
+	for _, p := range policies {
+		if p.Rate <= 0 {
+			continue
+		}
+		longest = max(longest, time.Duration(float64(p.Burst)/p.Rate*float64(time.Second)))
+	}
+	return longest
+}
+
+// Sweeper periodically deletes buckets that have been idle for longer than the refill time.
+type Sweeper struct {
+	buckets  IdleBuckets
+	idle     time.Duration
+	interval time.Duration
+}
+
+func CreateSweeper(buckets IdleBuckets, idle time.Duration, interval time.Duration) *Sweeper {
+	return &Sweeper{buckets, idle, interval}
+}
+
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.SweepIdle(ctx); err != nil {
+			logging.FromContext(ctx).Error("deleting idle rate limit buckets failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepIdle deletes the idle buckets once.
+func (s *Sweeper) SweepIdle(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Sweeper.SweepIdle")
+	defer func() { tracing.End(span, err) }()
+
+	n, err := s.buckets.DeleteIdle(ctx, s.idle)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logging.FromContext(ctx).Debug("idle rate limit buckets deleted", "count", n)
+	}
+	return nil
+}
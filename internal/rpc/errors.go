@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"avito-merch-store/internal/auth"
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/storage"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorCodes maps errors of the merchant to gRPC status codes, the same errors
+// the JSON API answers with 4xx statuses.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{auth.ErrEmptyCredentials, codes.InvalidArgument},
+	{auth.ErrReservedUsername, codes.InvalidArgument},
+	{auth.ErrUnknownUser, codes.Unauthenticated},
+	{auth.ErrWrongPassword, codes.Unauthenticated},
+	{storage.ErrUserNotFound, codes.NotFound},
+	{storage.ErrMerchNotFound, codes.NotFound},
+	{merchant.ErrVariantNotFound, codes.NotFound},
+	{merchant.ErrIncorrectCount, codes.InvalidArgument},
+	{merchant.ErrTransferTooLarge, codes.InvalidArgument},
+	{merchant.ErrVariantRequired, codes.InvalidArgument},
+	{merchant.ErrNotEnoughCoins, codes.FailedPrecondition},
+	{merchant.ErrOutOfStock, codes.FailedPrecondition},
+	{storage.ErrPromoCodeNotApplicable, codes.FailedPrecondition},
+	{storage.ErrPromoCodeExhausted, codes.FailedPrecondition},
+	{storage.ErrPromoCodeUsed, codes.FailedPrecondition},
+	{merchant.ErrDailyLimitExceeded, codes.ResourceExhausted},
+	{merchant.ErrWeeklyLimitExceeded, codes.ResourceExhausted},
+	{merchant.ErrRecipientLimitExceeded, codes.ResourceExhausted},
+	{merchant.ErrAccountTooNew, codes.PermissionDenied},
+}
+
+// statusFromError converts err to a status, unknown errors become Internal.
+func statusFromError(err error) error {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return status.Error(e.code, err.Error())
+		}
+	}
+	return status.Error(codes.Internal, "something went wrong:\n"+err.Error())
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/rpc/storepb"
+	"avito-merch-store/internal/tracing"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type usernameKey struct{}
+
+// username returns the user authenticated by authInterceptor.
+func username(ctx context.Context) string {
+	name, _ := ctx.Value(usernameKey{}).(string)
+	return name
+}
+
+// public methods are served without a token.
+var public = map[string]bool{
+	storepb.MerchStore_Auth_FullMethodName: true,
+}
+
+// authInterceptor validates the bearer token of the "authorization" metadata, like AuthMiddleware does.
+func (s *Server) authInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	if public[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid token format")
+	}
+
+	_, span := tracing.Start(ctx, "auth.ValidateKey")
+	name, err := s.auth.ValidateKey(token)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if st, ok := ctx.Value(callStateKey{}).(*callState); ok {
+		st.username = name
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", name))
+	return handler(context.WithValue(ctx, usernameKey{}, name), req)
+}
+
+type callStateKey struct{}
+
+// callState carries the username from authInterceptor back to the access log.
+type callState struct {
+	username string
+}
+
+// loggingInterceptor assigns a request ID (taken from the x-request-id metadata when present),
+// writes an access log line and records the call metrics.
+func (s *Server) loggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if values := md.Get("x-request-id"); len(values) > 0 && len(values[0]) <= 128 {
+		id = values[0]
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+
+	st := &callState{}
+	ctx = logging.WithRequestID(ctx, id)
+	ctx = context.WithValue(ctx, callStateKey{}, st)
+	res, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+	logging.FromContext(ctx).Log(ctx, level, "grpc call",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency", time.Since(start),
+		"username", st.username,
+	)
+	metrics.GRPCRequests.WithLabelValues(info.FullMethod, code.String()).Inc()
+	metrics.GRPCDuration.WithLabelValues(info.FullMethod, code.String()).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+// tracingInterceptor wraps the call in a server span named after the method.
+func (s *Server) tracingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (_ any, err error) {
+	ctx, span := tracing.Start(ctx, info.FullMethod, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", info.FullMethod)))
+	defer func() { tracing.End(span, err) }()
+	return handler(ctx, req)
+}
@@ -0,0 +1,120 @@
+package rpc
+
+//go:generate protoc --proto_path=../../api --go_out=storepb --go_opt=paths=source_relative --go-grpc_out=storepb --go-grpc_opt=paths=source_relative store.proto
+
+import (
+	"avito-merch-store/internal/auth"
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/rpc/storepb"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the gRPC API on top of the same merchant as the JSON API.
+type Server struct {
+	storepb.UnimplementedMerchStoreServer
+	storage storage.AuthStorage
+	auth    auth.Authenticator
+	merch   *merchant.Merchant
+}
+
+// NewServer creates a gRPC server with the MerchStore service registered.
+func NewServer(storage storage.AuthStorage, auth auth.Authenticator, merch *merchant.Merchant) *grpc.Server {
+	s := &Server{storage: storage, auth: auth, merch: merch}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(s.loggingInterceptor, s.tracingInterceptor, s.authInterceptor))
+	storepb.RegisterMerchStoreServer(server, s)
+	return server
+}
+
+func (s *Server) Auth(ctx context.Context, req *storepb.AuthRequest) (*storepb.AuthResponse, error) {
+	key, err := auth.Login(ctx, s.auth, s.storage, s.merch.AddUser, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &storepb.AuthResponse{Token: key}, nil
+}
+
+func (s *Server) GetInfo(ctx context.Context, _ *storepb.GetInfoRequest) (*storepb.GetInfoResponse, error) {
+	info, err := s.merch.GetInfoByUsername(ctx, username(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	res := &storepb.GetInfoResponse{Coins: int64(info.Coins), CoinHistory: &storepb.CoinHistory{}}
+	for _, item := range info.Inventory {
+		pbItem := &storepb.InventoryItem{Type: item.Type, Quantity: int64(item.Quantity)}
+		for _, v := range item.Variants {
+			pbItem.Variants = append(pbItem.Variants,
+				&storepb.VariantQuantity{Size: v.Size, Color: v.Color, Quantity: int64(v.Quantity)})
+		}
+		res.Inventory = append(res.Inventory, pbItem)
+	}
+	for _, t := range info.CoinHistory.Received {
+		res.CoinHistory.Received = append(res.CoinHistory.Received, &storepb.ReceivedTransfer{
+			Id: int64(t.ID), FromUser: t.FromUser, Amount: int64(t.Amount), Status: t.Status})
+	}
+	for _, t := range info.CoinHistory.Sent {
+		res.CoinHistory.Sent = append(res.CoinHistory.Sent, &storepb.SentTransfer{
+			Id: int64(t.ID), ToUser: t.ToUser, Amount: int64(t.Amount), Status: t.Status})
+	}
+	return res, nil
+}
+
+func (s *Server) GetTransactions(ctx context.Context,
+	_ *storepb.GetTransactionsRequest) (*storepb.GetTransactionsResponse, error) {
+	transactions, err := s.merch.GetTransactions(ctx, username(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	res := &storepb.GetTransactionsResponse{}
+	for _, t := range transactions {
+		res.Transactions = append(res.Transactions, transaction(&t))
+	}
+	return res, nil
+}
+
+func (s *Server) SendCoin(ctx context.Context, req *storepb.SendCoinRequest) (*storepb.SendCoinResponse, error) {
+	sender := username(ctx)
+	if req.GetToUser() == sender {
+		return nil, status.Error(codes.InvalidArgument, "you can't send money for yourself")
+	}
+	if req.GetAmount() < 1 || req.GetAmount() > int64(^uint32(0)>>1) {
+		return nil, statusFromError(merchant.ErrIncorrectCount)
+	}
+	t, err := s.merch.SendCoin(ctx, sender, req.GetToUser(), int(req.GetAmount()))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &storepb.SendCoinResponse{Transfer: transaction(t)}, nil
+}
+
+func (s *Server) Buy(ctx context.Context, req *storepb.BuyRequest) (*storepb.BuyResponse, error) {
+	if req.GetItem() == "" {
+		return nil, status.Error(codes.InvalidArgument, "item is required")
+	}
+	err := s.merch.Buy(ctx, username(ctx), req.GetItem(), req.GetSize(), req.GetColor(), req.GetPromoCode())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &storepb.BuyResponse{}, nil
+}
+
+func transaction(t *model.Transaction) *storepb.Transaction {
+	res := &storepb.Transaction{
+		Id:        int64(t.ID),
+		Sender:    t.SenderName,
+		Receiver:  t.ReceiverName,
+		Amount:    int64(t.Amount),
+		Status:    t.Status,
+		Reason:    t.Reason,
+		CreatedAt: timestamppb.New(t.CreatedAt),
+	}
+	if t.ExpiresAt != nil {
+		res.ExpiresAt = timestamppb.New(*t.ExpiresAt)
+	}
+	return res
+}
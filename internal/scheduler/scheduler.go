@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+const maxRunsPerTick = 1000
+
+var ErrInvalidSchedule = fmt.Errorf("schedule is invalid")
+
+// Scheduler pays recurring allowances. Every run is stored together with the period
+// it pays for, so after a restart missed periods are paid and paid ones are not repeated.
+type Scheduler struct {
+	schedules storage.ScheduleStorage
+	interval  time.Duration
+	now       func() time.Time
+}
+
+func CreateScheduler(schedules storage.ScheduleStorage, interval time.Duration) *Scheduler {
+	return &Scheduler{schedules, interval, func() time.Time { return time.Now().UTC() }}
+}
+
+// Run executes due schedules every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("scheduler tick failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue pays every due period of every active schedule.
+func (s *Scheduler) RunDue(ctx context.Context) error {
+	for i := 0; i < maxRunsPerTick; i++ {
+		runCtx, span := tracing.Start(ctx, "Scheduler.RunDue")
+		run, err := s.schedules.RunDue(runCtx, s.now())
+		tracing.End(span, err)
+		if err != nil {
+			if run == nil {
+				return err
+			}
+			run.Error = err.Error()
+			logging.FromContext(ctx).Error("allowance run failed", "schedule", run.ScheduleID,
+				"period", run.PeriodStart, "error", err)
+			return s.schedules.SaveFailedRun(ctx, *run)
+		}
+		if run == nil {
+			return nil
+		}
+		metrics.CoinsGranted.WithLabelValues("allowance").Add(float64(run.CoinsTotal))
+		logging.FromContext(ctx).Info("allowance paid", "schedule", run.ScheduleID,
+			"period", run.PeriodStart, "users", run.UsersCredited, "coins", run.CoinsTotal)
+	}
+	return nil
+}
+
+// Create stores a new active schedule. When startAt is zero the first run
+// happens at the beginning of the next period.
+func (s *Scheduler) Create(ctx context.Context, schedule model.Schedule, startAt time.Time) (*model.Schedule, error) {
+	if schedule.Name == "" || schedule.Amount <= 0 || !validPeriod(schedule.Period) ||
+		(schedule.MaxBalance != nil && *schedule.MaxBalance <= 0) {
+		return nil, ErrInvalidSchedule
+	}
+	if startAt.IsZero() {
+		startAt = periodStart(schedule.Period, s.now())
+	}
+	schedule.Status = model.ScheduleActive
+	schedule.NextRunAt = startAt.UTC()
+
+	id, err := s.schedules.CreateSchedule(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("schedule created", "id", id, "admin", schedule.CreatedBy)
+	return s.schedules.GetSchedule(ctx, id)
+}
+
+func (s *Scheduler) List(ctx context.Context) ([]model.Schedule, error) {
+	return s.schedules.GetSchedules(ctx)
+}
+
+func (s *Scheduler) Get(ctx context.Context, id int) (*model.Schedule, []model.ScheduleRun, error) {
+	schedule, err := s.schedules.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	runs, err := s.schedules.GetRuns(ctx, id, 100)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schedule, runs, nil
+}
+
+func (s *Scheduler) Pause(ctx context.Context, id int) (*model.Schedule, error) {
+	schedule, err := s.schedules.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.schedules.UpdateStatus(ctx, id, model.SchedulePaused, schedule.NextRunAt)
+	if err != nil {
+		return nil, err
+	}
+	return s.schedules.GetSchedule(ctx, id)
+}
+
+// Resume reactivates a schedule. Periods that passed while it was paused are skipped.
+func (s *Scheduler) Resume(ctx context.Context, id int) (*model.Schedule, error) {
+	schedule, err := s.schedules.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	next := schedule.NextRunAt
+	now := s.now()
+	for next.Before(now) {
+		next = nextRun(schedule.Period, next)
+	}
+	err = s.schedules.UpdateStatus(ctx, id, model.ScheduleActive, next)
+	if err != nil {
+		return nil, err
+	}
+	return s.schedules.GetSchedule(ctx, id)
+}
+
+func validPeriod(period string) bool {
+	return period == model.PeriodDaily || period == model.PeriodWeekly || period == model.PeriodMonthly
+}
+
+// periodStart returns the beginning of the period following now:
+// next midnight, next Monday or the first day of the next month.
+func periodStart(period string, now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case model.PeriodDaily:
+		return day.AddDate(0, 0, 1)
+	case model.PeriodWeekly:
+		return day.AddDate(0, 0, 7-(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func nextRun(period string, from time.Time) time.Time {
+	switch period {
+	case model.PeriodDaily:
+		return from.AddDate(0, 0, 1)
+	case model.PeriodWeekly:
+		return from.AddDate(0, 0, 7)
+	default:
+		return from.AddDate(0, 1, 0)
+	}
+}
@@ -0,0 +1,144 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const mockKeyID = "mock"
+
+// MockProvider is a minimal OpenID Connect provider for local runs and tests. It logs
+// everyone in without asking: the login_hint of the authorization request (or "mock-user")
+// becomes the subject "mock|<hint>" with the preferred username <hint>.
+// The issuer is the URL the provider is served at.
+type MockProvider struct {
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+type mockCode struct {
+	hint      string
+	nonce     string
+	challenge string
+	redirect  string
+}
+
+func NewMockProvider(clientID string, clientSecret string) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &MockProvider{clientID: clientID, clientSecret: clientSecret, key: key, codes: make(map[string]mockCode)}, nil
+}
+
+func (p *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	issuer := "http://" + r.Host
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, discovery{Issuer: issuer, AuthorizationEndpoint: issuer + "/authorize",
+			TokenEndpoint: issuer + "/token", JWKSURI: issuer + "/jwks"})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": mockKeyID, "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}}})
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r, issuer)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != p.clientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	hint := q.Get("login_hint")
+	if hint == "" {
+		hint = "mock-user"
+	}
+	code, err := random(16)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = mockCode{hint: hint, nonce: q.Get("nonce"), challenge: q.Get("code_challenge"),
+		redirect: redirect.String()}
+	p.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *MockProvider) token(w http.ResponseWriter, r *http.Request, issuer string) {
+	// client credentials are form-encoded before basic authentication (RFC 6749, 2.3.1)
+	id, secret, ok := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if !ok || id != p.clientID || secret != p.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostFormValue("code")
+	p.mu.Lock()
+	c, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || c.redirect != r.PostFormValue("redirect_uri") ||
+		c.challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   "mock|" + c.hint,
+			Audience:  jwt.ClaimStrings{p.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce:             c.nonce,
+		Email:             c.hint + "@example.com",
+		PreferredUsername: c.hint,
+	})
+	token.Header["kid"] = mockKeyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"access_token": signed, "token_type": "Bearer",
+		"expires_in": 300, "id_token": signed})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,186 @@
+package sso
+
+import (
+	"avito-merch-store/internal/auth"
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loginTTL is how long a user has to log in at the identity provider.
+const loginTTL = 10 * time.Minute
+
+// noPassword is stored as the password hash of provisioned users, it matches no password.
+const noPassword = "!sso"
+
+var ErrInvalidState = fmt.Errorf("login expired or was not started here")
+var ErrLoginFailed = fmt.Errorf("identity provider login failed")
+
+// Manager logs users in with an OpenID Connect provider. A subject seen for the first
+// time gets a new store user, later logins map it to the same user.
+type Manager struct {
+	provider *Provider
+	logins   storage.SSOStorage
+	users    storage.AuthStorage
+	auth     auth.Authenticator
+	register func(ctx context.Context, username string) error
+}
+
+// CreateManager issues store tokens with a, register creates the account of provisioned users.
+func CreateManager(provider *Provider, logins storage.SSOStorage, users storage.AuthStorage, a auth.Authenticator,
+	register func(ctx context.Context, username string) error) *Manager {
+	return &Manager{provider, logins, users, a, register}
+}
+
+// Begin starts a login and returns the URL to redirect the user to.
+func (m *Manager) Begin(ctx context.Context, loginHint string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "SSO.Begin")
+	defer func() { tracing.End(span, err) }()
+
+	login := model.SSOLogin{ExpiresAt: time.Now().Add(loginTTL)}
+	for _, v := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		if *v, err = random(32); err != nil {
+			return "", err
+		}
+	}
+	redirect, err := m.provider.AuthCodeURL(ctx, login.State, login.Nonce, login.Verifier, loginHint)
+	if err != nil {
+		return "", err
+	}
+	if err = m.logins.CreateLogin(ctx, login); err != nil {
+		return "", err
+	}
+	return redirect, nil
+}
+
+// Complete finishes the login started with state and returns a store token for the user.
+func (m *Manager) Complete(ctx context.Context, state string, code string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "SSO.Complete")
+	defer func() { tracing.End(span, err) }()
+
+	login, err := m.logins.TakeLogin(ctx, state, time.Now())
+	if errors.Is(err, storage.ErrSSOLoginNotFound) {
+		return "", ErrInvalidState
+	}
+	if err != nil {
+		return "", err
+	}
+	claims, err := m.provider.Exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		metrics.FailedLogins.Inc()
+		logging.FromContext(ctx).Warn("sso login failed", "issuer", m.provider.Issuer(), "error", err)
+		return "", fmt.Errorf("%w: %v", ErrLoginFailed, err)
+	}
+
+	username, err := m.username(ctx, claims)
+	if err != nil {
+		return "", err
+	}
+	_, keySpan := tracing.Start(ctx, "auth.GenerateKey")
+	key, err := m.auth.GenerateKey(username)
+	tracing.End(keySpan, err)
+	return key, err
+}
+
+// username maps the subject to its user, provisioning one on the first login.
+func (m *Manager) username(ctx context.Context, claims *Claims) (string, error) {
+	issuer := m.provider.Issuer()
+	identity, err := m.logins.GetIdentity(ctx, issuer, claims.Subject)
+	if err == nil {
+		if err := m.logins.TouchIdentity(ctx, issuer, claims.Subject, claims.Email, time.Now()); err != nil {
+			logging.FromContext(ctx).Error("cannot record sso login", "username", identity.Username, "error", err)
+		}
+		return identity.Username, nil
+	}
+	if !errors.Is(err, storage.ErrIdentityNotFound) {
+		return "", err
+	}
+
+	username, err := m.provision(ctx, baseUsername(claims))
+	if err != nil {
+		return "", err
+	}
+	err = m.logins.CreateIdentity(ctx, model.Identity{Issuer: issuer, Subject: claims.Subject, Username: username,
+		Email: claims.Email})
+	if errors.Is(err, storage.ErrIdentityExists) {
+		// a concurrent first login won, its user is the one to use
+		identity, err := m.logins.GetIdentity(ctx, issuer, claims.Subject)
+		if err != nil {
+			return "", err
+		}
+		return identity.Username, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	logging.FromContext(ctx).Info("sso user provisioned", "username", username, "issuer", issuer,
+		"subject", claims.Subject)
+	return username, nil
+}
+
+// provision creates a user named base, or base-2, base-3... when the name is taken.
+// Existing users are never linked by name, that would hand their accounts to whoever
+// controls the same name at the provider.
+func (m *Manager) provision(ctx context.Context, base string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		username := base
+		if i > 1 {
+			username = base + "-" + strconv.Itoa(i)
+		}
+		if username == model.SystemUser || m.users.CheckContains(ctx, username) {
+			continue
+		}
+		if err := m.users.AddUser(ctx, username, noPassword); err != nil {
+			// taken in the meantime
+			if m.users.CheckContains(ctx, username) {
+				continue
+			}
+			return "", err
+		}
+		if err := m.register(ctx, username); err != nil {
+			return "", err
+		}
+		return username, nil
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
+
+// baseUsername derives a username from the preferred username or the email of the claims.
+func baseUsername(claims *Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	name = strings.Trim(b.String(), ".-_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		return "user"
+	}
+	return name
+}
+
+func random(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+)
+
+type AchievementStorage interface {
+	GetStats(ctx context.Context, username string) (*model.ActivityStats, error)
+	// GetAwards returns the codes of awarded achievements with their award time.
+	GetAwards(ctx context.Context, username string) ([]model.Award, error)
+	// Award stores the achievement and credits reward coins from the system user
+	// in one transaction. It reports false when the achievement was awarded before.
+	Award(ctx context.Context, username string, code string, reward int, reason string) (bool, error)
+}
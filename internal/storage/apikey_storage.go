@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrAPIKeyNotFound = fmt.Errorf("api key not found")
+
+type APIKeyStorage interface {
+	// CreateKey stores key with the hash of its secret and returns the new key.
+	CreateKey(ctx context.Context, key model.APIKey, hash string) (*model.APIKey, error)
+	// GetKeyByPrefix returns the key together with its stored hash.
+	GetKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, string, error)
+	GetKeys(ctx context.Context) ([]model.APIKey, error)
+	// TouchKey sets the last use of a key.
+	TouchKey(ctx context.Context, id int, at time.Time) error
+	RevokeKey(ctx context.Context, id int, at time.Time) (*model.APIKey, error)
+}
@@ -1,8 +1,10 @@
 package storage
 
+import "context"
+
 type AuthStorage interface {
-	AddUser(username string, hashPassword string) error
-	CheckUser(username string, hashPassword string) bool
-	CheckContains(username string) bool
-	GetUserHash(username string) (string, error)
+	AddUser(ctx context.Context, username string, hashPassword string) error
+	CheckUser(ctx context.Context, username string, hashPassword string) bool
+	CheckContains(ctx context.Context, username string) bool
+	GetUserHash(ctx context.Context, username string) (string, error)
 }
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrCoinRequestNotFound = fmt.Errorf("coin request not found")
+var ErrCoinRequestNotPending = fmt.Errorf("coin request is not pending")
+
+type CoinRequestStorage interface {
+	// CreateRequests stores one pending request per payer. It fails with ErrUserNotFound
+	// and stores nothing when one of the payers does not exist.
+	CreateRequests(ctx context.Context, requester string, payers []string, amount int, note string,
+		expiresAt time.Time) ([]model.CoinRequest, error)
+	GetRequest(ctx context.Context, id int) (*model.CoinRequest, error)
+	// GetRequests returns the requests where username is the payer (incoming)
+	// or the requester (outgoing), newest first.
+	GetRequests(ctx context.Context, username string, incoming bool, count int) ([]model.CoinRequest, error)
+	// ResolveRequest moves a pending, not yet expired request to status.
+	ResolveRequest(ctx context.Context, id int, status string) (*model.CoinRequest, error)
+	// ReopenRequest moves a request back to pending, e.g. when its payment failed.
+	ReopenRequest(ctx context.Context, id int) error
+	// ExpireRequests marks overdue pending requests as expired and returns their number.
+	ExpireRequests(ctx context.Context) (int, error)
+}
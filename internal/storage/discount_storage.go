@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrSaleNotFound = fmt.Errorf("sale not found")
+var ErrPromoCodeNotFound = fmt.Errorf("promo code not found")
+var ErrPromoCodeExists = fmt.Errorf("promo code already exists")
+var ErrPromoCodeNotApplicable = fmt.Errorf("promo code is not valid for this item now")
+var ErrPromoCodeExhausted = fmt.Errorf("promo code has no uses left")
+var ErrPromoCodeUsed = fmt.Errorf("promo code was already used by this user")
+
+type DiscountStorage interface {
+	// CreateSale fails with ErrMerchNotFound for unknown items.
+	CreateSale(ctx context.Context, sale model.Sale) (int, error)
+	// GetSales returns the sales active at the given time, or all sales when at is zero.
+	GetSales(ctx context.Context, at time.Time) ([]model.Sale, error)
+	// EndSale moves the end of a sale to at, unless it ends earlier.
+	EndSale(ctx context.Context, id int, at time.Time) error
+
+	// CreatePromoCode fails with ErrPromoCodeExists or ErrMerchNotFound.
+	CreatePromoCode(ctx context.Context, code model.PromoCode) error
+	GetPromoCodes(ctx context.Context) ([]model.PromoCode, error)
+	DisablePromoCode(ctx context.Context, code string, at time.Time) error
+	// GetPromoCode fails with ErrPromoCodeNotFound. Uses are counted by the purchase
+	// that redeems the code.
+	GetPromoCode(ctx context.Context, code string) (*model.PromoCode, error)
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+)
+
+var ErrGrantNotFound = fmt.Errorf("grant batch not found")
+var ErrGrantNotPending = fmt.Errorf("grant batch is not waiting for approval")
+var ErrGrantFailed = fmt.Errorf("grant batch cannot be applied")
+
+type GrantStorage interface {
+	CreateBatch(ctx context.Context, createdBy string, status string, items []model.GrantItem) (int, error)
+	GetBatch(ctx context.Context, id int) (*model.GrantBatch, error)
+	GetBatches(ctx context.Context, status string, count int) ([]model.GrantBatch, error)
+	// ApplyBatch credits every item of an approved or pending batch in one transaction.
+	// If any item fails nothing is applied, the batch is marked failed
+	// and ErrGrantFailed is returned together with per-item errors.
+	ApplyBatch(ctx context.Context, id int, approvedBy string) (*model.GrantBatch, error)
+	RejectBatch(ctx context.Context, id int, rejectedBy string) error
+}
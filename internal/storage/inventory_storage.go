@@ -1,8 +1,16 @@
 package storage
 
-import "avito-merch-store/model"
+import (
+	"avito-merch-store/model"
+	"context"
+)
 
 type InventoryStorage interface {
-	AddItems(userID int, item string, quantity int) error
-	GetByUserID(userID int, count int) ([]model.InventoryItem, error)
+	GetByUserID(ctx context.Context, userID int, count int) ([]model.InventoryItem, error)
+	// Purchase takes one piece of the variant from stock, redeems the promo code,
+	// debits the price from the buyer, adds the item to the inventory, logs the
+	// purchase with the discount applied, places the order to hand the item over and
+	// writes the ItemPurchased event in one transaction. It fails with ErrOutOfStock,
+	// one of the promo code errors or ErrNotEnoughCoins.
+	Purchase(ctx context.Context, purchase model.Purchase) error
 }
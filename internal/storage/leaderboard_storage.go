@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"time"
+)
+
+type LeaderboardStorage interface {
+	// GetBoard ranks users who did not opt out. Activity boards count from since,
+	// a zero since means all time. The balance board is always current.
+	GetBoard(ctx context.Context, board string, since time.Time, limit int) ([]model.LeaderboardEntry, error)
+	// Refresh recomputes the aggregated activity the boards are built from.
+	Refresh(ctx context.Context) error
+	SetOptOut(ctx context.Context, username string, optOut bool) error
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+type LotStorage interface {
+	// SetExpiryPolicies replaces the lifetimes of coins per lot source.
+	// Sources missing from policies never expire. Only new lots are affected.
+	SetExpiryPolicies(ctx context.Context, policies map[string]time.Duration) error
+	// ExpireLots burns up to limit expired lots and returns
+	// the number of burnt lots and coins.
+	ExpireLots(ctx context.Context, limit int) (int, int, error)
+}
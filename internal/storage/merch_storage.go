@@ -1,9 +1,28 @@
 package storage
 
-import "fmt"
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+)
 
 var ErrMerchNotFound = fmt.Errorf("error: cannot found item")
+var ErrVariantNotFound = fmt.Errorf("item variant not found")
+var ErrVariantExists = fmt.Errorf("item variant already exists")
+var ErrOutOfStock = fmt.Errorf("item variant is out of stock")
 
 type MerchStorage interface {
-	GetByName(item string) (int, error)
+	GetByName(ctx context.Context, item string) (int, error)
+	// GetAll returns the catalog items matching filter together with the number of users wishing for each item.
+	GetAll(ctx context.Context, filter model.CatalogFilter) ([]model.CatalogItem, error)
+	// UpdateDetails replaces the catalog metadata of item and returns its previous image URL.
+	UpdateDetails(ctx context.Context, item string, details model.ItemDetails) (string, error)
+	// SetImage sets the image of item and returns the previous one.
+	SetImage(ctx context.Context, item string, imageURL string) (string, error)
+	// GetVariants returns the variants of item, or of every item when item is empty.
+	GetVariants(ctx context.Context, item string) ([]model.ItemVariant, error)
+	GetVariant(ctx context.Context, item string, size string, color string) (*model.ItemVariant, error)
+	// CreateVariant fails with ErrMerchNotFound or ErrVariantExists.
+	CreateVariant(ctx context.Context, variant model.ItemVariant) (int, error)
+	UpdateVariant(ctx context.Context, id int, stock int, priceDelta int) (*model.ItemVariant, error)
 }
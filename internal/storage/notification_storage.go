@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"time"
+)
+
+type NotificationStorage interface {
+	// GetPreferences returns the preferences of username, the inbox only when none are set.
+	GetPreferences(ctx context.Context, username string) (*model.NotificationPreferences, error)
+	SetPreferences(ctx context.Context, prefs model.NotificationPreferences) error
+	// CreateNotification stores a notification, shown in the inbox when inbox is set,
+	// together with a pending delivery for every channel.
+	CreateNotification(ctx context.Context, n model.Notification, inbox bool, channels []string) (int, error)
+	GetNotifications(ctx context.Context, username string, unreadOnly bool, count int) ([]model.Notification, error)
+	// MarkRead marks the listed notifications of username as read, all of them when ids is empty.
+	MarkRead(ctx context.Context, username string, ids []int) (int, error)
+	// ClaimDeliveries takes up to count due pending deliveries and hides them from other
+	// claims until leaseUntil, so that several instances do not send the same delivery.
+	ClaimDeliveries(ctx context.Context, count int, leaseUntil time.Time) ([]model.Delivery, error)
+	CompleteDelivery(ctx context.Context, id int) error
+	// FailDelivery records a failed attempt. The delivery is retried at retryAt,
+	// or given up when retryAt is nil.
+	FailDelivery(ctx context.Context, id int, reason string, retryAt *time.Time) error
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+)
+
+var ErrOrderNotFound = fmt.Errorf("order not found")
+var ErrOrderStatus = fmt.Errorf("order cannot move to this status")
+var ErrLocationNotFound = fmt.Errorf("pickup location not found")
+var ErrLocationExists = fmt.Errorf("pickup location already exists")
+
+type OrderStorage interface {
+	GetOrder(ctx context.Context, id int) (*model.Order, error)
+	GetOrders(ctx context.Context, username string) ([]model.Order, error)
+	// GetQueue returns orders in one of statuses, oldest first. locationID 0 matches every location.
+	GetQueue(ctx context.Context, statuses []string, locationID int) ([]model.Order, error)
+	// SetLocation chooses an active pickup location for an order in one of statuses.
+	SetLocation(ctx context.Context, id int, locationID int, statuses []string) (*model.Order, error)
+	// SetStatus moves an order from one of from to status, failing with ErrOrderStatus otherwise.
+	SetStatus(ctx context.Context, id int, from []string, status string, handledBy string) (*model.Order, error)
+	// CancelOrder cancels an order in one of from. The refund goes back to the buyer, the item
+	// leaves the inventory and returns to the stock of its variant in the same transaction.
+	CancelOrder(ctx context.Context, id int, from []string, handledBy string) (*model.Order, error)
+
+	CreateLocation(ctx context.Context, name string, address string) (*model.PickupLocation, error)
+	GetLocations(ctx context.Context, activeOnly bool) ([]model.PickupLocation, error)
+	DisableLocation(ctx context.Context, id int) error
+}
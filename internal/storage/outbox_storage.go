@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"time"
+)
+
+// OutboxStorage reads the domain events the other storages write along with their changes.
+type OutboxStorage interface {
+	// ClaimEvents takes up to count due unpublished events in order and hides them
+	// from other claims until leaseUntil.
+	ClaimEvents(ctx context.Context, count int, leaseUntil time.Time) ([]model.Event, error)
+	MarkPublished(ctx context.Context, seqs []int64) error
+	// FailEvents records a failed publication, the events are claimed again at retryAt.
+	FailEvents(ctx context.Context, seqs []int64, reason string, retryAt time.Time) error
+	// DeletePublished removes events published before the given time.
+	DeletePublished(ctx context.Context, before time.Time) (int, error)
+}
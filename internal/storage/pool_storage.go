@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrPoolNotFound = fmt.Errorf("pool not found")
+var ErrPoolNotOpen = fmt.Errorf("pool is not open")
+var ErrPoolOverfunded = fmt.Errorf("contribution exceeds the remaining amount of the pool")
+var ErrPoolVariantItem = fmt.Errorf("items with variants can't be pooled")
+
+type PoolStorage interface {
+	// CreatePool opens a pool targeting the current price of item. It fails with
+	// ErrMerchNotFound or ErrUserNotFound when the item or the recipient is unknown
+	// and with ErrPoolVariantItem for items sold in variants.
+	CreatePool(ctx context.Context, organizer string, item string, recipient string, expiresAt time.Time) (int, error)
+	GetPool(ctx context.Context, id int) (*model.Pool, error)
+	GetOpenPools(ctx context.Context, count int) ([]model.Pool, error)
+	// Contribute takes amount coins from username into the escrow of an open pool.
+	// When the target is reached the item is added to the recipient's inventory,
+	// the contributions are written to the transaction history and the pool is
+	// completed in the same transaction.
+	Contribute(ctx context.Context, id int, username string, amount int) (*model.Pool, error)
+	// ClosePool moves an open pool to status and refunds all contributions.
+	ClosePool(ctx context.Context, id int, status string) (*model.Pool, error)
+	GetExpiredPoolIDs(ctx context.Context, count int) ([]int, error)
+}
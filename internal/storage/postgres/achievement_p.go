@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type AchievementStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateAchievementStoragePostgres(postgresConnect string) (*AchievementStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &AchievementStoragePostgres{pool}, nil
+}
+
+func (st *AchievementStoragePostgres) GetStats(ctx context.Context, username string) (*model.ActivityStats, error) {
+	query := `
+        SELECT (SELECT COUNT(*) FROM purchases WHERE username = $1),
+               (SELECT COUNT(DISTINCT item) FROM purchases WHERE username = $1),
+               (SELECT COUNT(*) FROM merch),
+               (SELECT COUNT(DISTINCT receiver_username)
+                FROM transactions
+                WHERE sender_username = $1 AND receiver_username <> $2 AND status = $3),
+               (SELECT COALESCE(SUM(amount), 0)
+                FROM transactions
+                WHERE receiver_username = $1 AND sender_username <> $2 AND status = $3)
+    `
+	ctx, end := track(ctx, "achievements.GetStats", query)
+	defer end()
+
+	var s model.ActivityStats
+	err := st.pool.QueryRow(ctx, query, username, model.SystemUser, model.TransactionCompleted).
+		Scan(&s.Purchases, &s.DistinctItems, &s.CatalogSize, &s.DistinctRecipients, &s.ReceivedCoins)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (st *AchievementStoragePostgres) GetAwards(ctx context.Context, username string) ([]model.Award, error) {
+	query := `
+        SELECT code, awarded_at
+        FROM achievements
+        WHERE username = $1
+        ORDER BY awarded_at, code
+    `
+	ctx, end := track(ctx, "achievements.GetAwards", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Award
+	for rows.Next() {
+		var a model.Award
+		if err := rows.Scan(&a.Code, &a.AwardedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+func (st *AchievementStoragePostgres) Award(ctx context.Context, username string, code string, reward int,
+	reason string) (bool, error) {
+	query := `
+        INSERT INTO achievements (username, code, reward, awarded_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (username, code) DO NOTHING
+    `
+	ctx, end := track(ctx, "achievements.Award", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, query, username, code, reward, now)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+	if reward > 0 {
+		_, err = tx.Exec(ctx, `
+            SELECT credit_coins((SELECT id FROM users WHERE username = $1), $2, $3)
+        `, username, reward, model.LotReward)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(ctx, `
+            INSERT INTO transactions (sender_username, receiver_username, amount, created_at, reason)
+            VALUES ($1, $2, $3, $4, $5)
+        `, model.SystemUser, username, reward, now, reason)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (st *AchievementStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
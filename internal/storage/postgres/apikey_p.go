@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+type APIKeyStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateAPIKeyStoragePostgres(postgresConnect string) (*APIKeyStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &APIKeyStoragePostgres{pool}, nil
+}
+
+func (st *APIKeyStoragePostgres) CreateKey(ctx context.Context, key model.APIKey, hash string) (*model.APIKey, error) {
+	query := `
+        INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING ` + apiKeyColumns
+	ctx, end := track(ctx, "api_keys.CreateKey", query)
+	defer end()
+
+	return scanAPIKey(st.pool.QueryRow(ctx, query, key.Name, key.Prefix, hash, key.Scopes, key.CreatedBy,
+		time.Now(), key.ExpiresAt))
+}
+
+func (st *APIKeyStoragePostgres) GetKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, string, error) {
+	query := `
+        SELECT ` + apiKeyColumns + `, key_hash
+        FROM api_keys
+        WHERE prefix = $1
+    `
+	ctx, end := track(ctx, "api_keys.GetKeyByPrefix", query)
+	defer end()
+
+	var key model.APIKey
+	var hash string
+	err := st.pool.QueryRow(ctx, query, prefix).Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedBy,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &key, hash, nil
+}
+
+func (st *APIKeyStoragePostgres) GetKeys(ctx context.Context) ([]model.APIKey, error) {
+	query := `
+        SELECT ` + apiKeyColumns + `
+        FROM api_keys
+        ORDER BY id
+    `
+	ctx, end := track(ctx, "api_keys.GetKeys", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (st *APIKeyStoragePostgres) TouchKey(ctx context.Context, id int, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`
+	ctx, end := track(ctx, "api_keys.TouchKey", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, id, at)
+	return err
+}
+
+func (st *APIKeyStoragePostgres) RevokeKey(ctx context.Context, id int, at time.Time) (*model.APIKey, error) {
+	query := `
+        UPDATE api_keys
+        SET revoked_at = COALESCE(revoked_at, $2)
+        WHERE id = $1
+        RETURNING ` + apiKeyColumns
+	ctx, end := track(ctx, "api_keys.RevokeKey", query)
+	defer end()
+
+	key, err := scanAPIKey(st.pool.QueryRow(ctx, query, id, at))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (st *APIKeyStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func scanAPIKey(row pgx.Row) (*model.APIKey, error) {
+	var key model.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedBy, &key.CreatedAt, &key.ExpiresAt,
+		&key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
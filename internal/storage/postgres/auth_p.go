@@ -3,38 +3,42 @@ package postgres
 import (
 	"context"
 	"errors"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type AuthStoragePostgres struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func CreateAuthStoragePostgres(postgresConnect string) (*AuthStoragePostgres, error) {
-	conn, err := pgx.Connect(context.Background(), postgresConnect)
+	pool, err := connect(postgresConnect)
 	if err != nil {
 		return nil, err
 	}
-	return &AuthStoragePostgres{conn}, nil
+	return &AuthStoragePostgres{pool}, nil
 }
 
-func (auth *AuthStoragePostgres) AddUser(username, password string) error {
+func (auth *AuthStoragePostgres) AddUser(ctx context.Context, username, password string) error {
 	query := `
         INSERT INTO auth (username, password_hash)
         VALUES ($1, $2)
     `
-	_, err := auth.conn.Exec(context.Background(), query, username, password)
+	ctx, end := track(ctx, "auth.AddUser", query)
+	defer end()
+	_, err := auth.pool.Exec(ctx, query, username, password)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (auth *AuthStoragePostgres) CheckUser(username, password string) bool {
+func (auth *AuthStoragePostgres) CheckUser(ctx context.Context, username, password string) bool {
 	query := `
         SELECT * FROM auth WHERE username = $1 AND password_hash = $2
     `
-	ans, err := auth.conn.Query(context.Background(), query, username, password)
+	ctx, end := track(ctx, "auth.CheckUser", query)
+	defer end()
+	ans, err := auth.pool.Query(ctx, query, username, password)
 	defer ans.Close()
 	if err != nil {
 		return false
@@ -42,11 +46,13 @@ func (auth *AuthStoragePostgres) CheckUser(username, password string) bool {
 	return ans.Next()
 }
 
-func (auth *AuthStoragePostgres) CheckContains(username string) bool {
+func (auth *AuthStoragePostgres) CheckContains(ctx context.Context, username string) bool {
 	query := `
         SELECT * FROM auth WHERE username = $1
     `
-	ans, err := auth.conn.Query(context.Background(), query, username)
+	ctx, end := track(ctx, "auth.CheckContains", query)
+	defer end()
+	ans, err := auth.pool.Query(ctx, query, username)
 	defer ans.Close()
 	if err != nil {
 		return false
@@ -54,11 +60,13 @@ func (auth *AuthStoragePostgres) CheckContains(username string) bool {
 	return ans.Next()
 }
 
-func (auth *AuthStoragePostgres) GetUserHash(username string) (string, error) {
+func (auth *AuthStoragePostgres) GetUserHash(ctx context.Context, username string) (string, error) {
 	query := `
         SELECT password_hash FROM auth WHERE username = $1
     `
-	ans, err := auth.conn.Query(context.Background(), query, username)
+	ctx, end := track(ctx, "auth.GetUserHash", query)
+	defer end()
+	ans, err := auth.pool.Query(ctx, query, username)
 	defer ans.Close()
 	if err != nil {
 		return "", err
@@ -73,3 +81,7 @@ func (auth *AuthStoragePostgres) GetUserHash(username string) (string, error) {
 	}
 	return r, nil
 }
+
+func (auth *AuthStoragePostgres) Ping(ctx context.Context) error {
+	return auth.pool.Ping(ctx)
+}
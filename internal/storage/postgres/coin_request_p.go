@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+const coinRequestColumns = `id, requester, payer, amount, note, status, created_at, expires_at, resolved_at`
+
+type CoinRequestStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateCoinRequestStoragePostgres(postgresConnect string) (*CoinRequestStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &CoinRequestStoragePostgres{pool}, nil
+}
+
+func (st *CoinRequestStoragePostgres) CreateRequests(ctx context.Context, requester string, payers []string,
+	amount int, note string, expiresAt time.Time) ([]model.CoinRequest, error) {
+	query := `
+        INSERT INTO coin_requests (requester, payer, amount, note, created_at, expires_at)
+        SELECT $1, username, $3, $4, $5, $6
+        FROM users
+        WHERE username = ANY ($2)
+        RETURNING ` + coinRequestColumns
+	ctx, end := track(ctx, "coin_requests.CreateRequests", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, requester, payers, amount, note, time.Now(), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	res, err := scanCoinRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != len(payers) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (st *CoinRequestStoragePostgres) GetRequest(ctx context.Context, id int) (*model.CoinRequest, error) {
+	query := `
+        SELECT ` + coinRequestColumns + `
+        FROM coin_requests
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "coin_requests.GetRequest", query)
+	defer end()
+
+	r, err := scanCoinRequest(st.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrCoinRequestNotFound
+	}
+	return r, err
+}
+
+func (st *CoinRequestStoragePostgres) GetRequests(ctx context.Context, username string, incoming bool,
+	count int) ([]model.CoinRequest, error) {
+	column := "requester"
+	if incoming {
+		column = "payer"
+	}
+	query := `
+        SELECT ` + coinRequestColumns + `
+        FROM coin_requests
+        WHERE ` + column + ` = $1
+        ORDER BY id DESC
+    `
+	if count > 0 {
+		query += " LIMIT $2"
+	}
+	ctx, end := track(ctx, "coin_requests.GetRequests", query)
+	defer end()
+
+	args := []any{username}
+	if count > 0 {
+		args = append(args, count)
+	}
+	rows, err := st.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanCoinRequests(rows)
+}
+
+func (st *CoinRequestStoragePostgres) ResolveRequest(ctx context.Context, id int, status string) (*model.CoinRequest, error) {
+	query := `
+        UPDATE coin_requests
+        SET status = $2, resolved_at = $3
+        WHERE id = $1 AND status = $4 AND expires_at > $3
+        RETURNING ` + coinRequestColumns
+	ctx, end := track(ctx, "coin_requests.ResolveRequest", query)
+	defer end()
+
+	r, err := scanCoinRequest(st.pool.QueryRow(ctx, query, id, status, time.Now(), model.CoinRequestPending))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := st.GetRequest(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, storage.ErrCoinRequestNotPending
+	}
+	return r, err
+}
+
+func (st *CoinRequestStoragePostgres) ReopenRequest(ctx context.Context, id int) error {
+	query := `
+        UPDATE coin_requests
+        SET status = $2, resolved_at = NULL
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "coin_requests.ReopenRequest", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, id, model.CoinRequestPending)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrCoinRequestNotFound
+	}
+	return nil
+}
+
+func (st *CoinRequestStoragePostgres) ExpireRequests(ctx context.Context) (int, error) {
+	query := `
+        UPDATE coin_requests
+        SET status = $1, resolved_at = expires_at
+        WHERE status = $2 AND expires_at <= $3
+    `
+	ctx, end := track(ctx, "coin_requests.ExpireRequests", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, model.CoinRequestExpired, model.CoinRequestPending, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (st *CoinRequestStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func scanCoinRequests(rows pgx.Rows) ([]model.CoinRequest, error) {
+	defer rows.Close()
+
+	var res []model.CoinRequest
+	for rows.Next() {
+		r, err := scanCoinRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *r)
+	}
+	return res, rows.Err()
+}
+
+func scanCoinRequest(row pgx.Row) (*model.CoinRequest, error) {
+	var r model.CoinRequest
+	err := row.Scan(&r.ID, &r.Requester, &r.Payer, &r.Amount, &r.Note, &r.Status, &r.CreatedAt,
+		&r.ExpiresAt, &r.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync"
+)
+
+var (
+	connPoolsMu sync.Mutex
+	connPools   = make(map[string]*pgxpool.Pool)
+)
+
+// connect returns the connection pool of postgresConnect. Storages of the same
+// database share one pool, so concurrent requests and transactions each get a
+// connection of their own while the total number of connections stays bounded.
+func connect(postgresConnect string) (*pgxpool.Pool, error) {
+	connPoolsMu.Lock()
+	defer connPoolsMu.Unlock()
+
+	if pool, ok := connPools[postgresConnect]; ok {
+		return pool, nil
+	}
+	pool, err := pgxpool.New(context.Background(), postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	// the pool connects lazily, fail at startup like a single connection did
+	if err = pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	connPools[postgresConnect] = pool
+	return pool, nil
+}
@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+const saleColumns = `id, COALESCE(item, ''), kind, value, starts_at, ends_at, created_by, created_at`
+const promoCodeColumns = `code, COALESCE(item, ''), kind, value, max_uses, max_uses_per_user, uses, starts_at, ends_at,
+               created_by, created_at`
+
+type DiscountStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateDiscountStoragePostgres(postgresConnect string) (*DiscountStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &DiscountStoragePostgres{pool}, nil
+}
+
+func (st *DiscountStoragePostgres) CreateSale(ctx context.Context, s model.Sale) (int, error) {
+	query := `
+        INSERT INTO sales (item, kind, value, starts_at, ends_at, created_by, created_at)
+        VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7)
+        RETURNING id
+    `
+	ctx, end := track(ctx, "discounts.CreateSale", query)
+	defer end()
+
+	var id int
+	err := st.pool.QueryRow(ctx, query, s.Item, s.Kind, s.Value, s.StartsAt, s.EndsAt, s.CreatedBy,
+		time.Now()).Scan(&id)
+	if isForeignKeyViolation(err) {
+		return 0, storage.ErrMerchNotFound
+	}
+	return id, err
+}
+
+func (st *DiscountStoragePostgres) GetSales(ctx context.Context, at time.Time) ([]model.Sale, error) {
+	query := `
+        SELECT ` + saleColumns + `
+        FROM sales
+        WHERE $1::TIMESTAMP IS NULL OR (starts_at <= $1 AND ends_at > $1)
+        ORDER BY id
+    `
+	ctx, end := track(ctx, "discounts.GetSales", query)
+	defer end()
+
+	var arg *time.Time
+	if !at.IsZero() {
+		arg = &at
+	}
+	rows, err := st.pool.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Sale
+	for rows.Next() {
+		var s model.Sale
+		err := rows.Scan(&s.ID, &s.Item, &s.Kind, &s.Value, &s.StartsAt, &s.EndsAt, &s.CreatedBy, &s.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (st *DiscountStoragePostgres) EndSale(ctx context.Context, id int, at time.Time) error {
+	query := `
+        UPDATE sales SET ends_at = LEAST(ends_at, $2) WHERE id = $1
+    `
+	ctx, end := track(ctx, "discounts.EndSale", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, id, at)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrSaleNotFound
+	}
+	return nil
+}
+
+func (st *DiscountStoragePostgres) CreatePromoCode(ctx context.Context, c model.PromoCode) error {
+	query := `
+        INSERT INTO promo_codes (code, item, kind, value, max_uses, max_uses_per_user, starts_at, ends_at,
+                                 created_by, created_at)
+        VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10)
+    `
+	ctx, end := track(ctx, "discounts.CreatePromoCode", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, c.Code, c.Item, c.Kind, c.Value, c.MaxUses, c.MaxUsesPerUser,
+		c.StartsAt, c.EndsAt, c.CreatedBy, time.Now())
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return storage.ErrPromoCodeExists
+	}
+	if isForeignKeyViolation(err) {
+		return storage.ErrMerchNotFound
+	}
+	return err
+}
+
+func (st *DiscountStoragePostgres) GetPromoCodes(ctx context.Context) ([]model.PromoCode, error) {
+	query := `
+        SELECT ` + promoCodeColumns + `
+        FROM promo_codes
+        ORDER BY created_at, code
+    `
+	ctx, end := track(ctx, "discounts.GetPromoCodes", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.PromoCode
+	for rows.Next() {
+		c, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *c)
+	}
+	return res, rows.Err()
+}
+
+func (st *DiscountStoragePostgres) DisablePromoCode(ctx context.Context, code string, at time.Time) error {
+	query := `
+        UPDATE promo_codes SET ends_at = LEAST(COALESCE(ends_at, $2), $2) WHERE code = $1
+    `
+	ctx, end := track(ctx, "discounts.DisablePromoCode", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, code, at)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrPromoCodeNotFound
+	}
+	return nil
+}
+
+func (st *DiscountStoragePostgres) GetPromoCode(ctx context.Context, code string) (*model.PromoCode, error) {
+	query := `
+        SELECT ` + promoCodeColumns + `
+        FROM promo_codes
+        WHERE code = $1
+    `
+	ctx, end := track(ctx, "discounts.GetPromoCode", query)
+	defer end()
+
+	c, err := scanPromoCode(st.pool.QueryRow(ctx, query, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrPromoCodeNotFound
+	}
+	return c, err
+}
+
+func (st *DiscountStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation
+}
+
+func scanPromoCode(row pgx.Row) (*model.PromoCode, error) {
+	var c model.PromoCode
+	err := row.Scan(&c.Code, &c.Item, &c.Kind, &c.Value, &c.MaxUses, &c.MaxUsesPerUser, &c.Uses, &c.StartsAt,
+		&c.EndsAt, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// redeemPromoCode counts one use of code by username for item within tx,
+// checking the validity window and both usage limits under the row lock.
+func redeemPromoCode(ctx context.Context, tx pgx.Tx, code string, username string, item string) error {
+	var c model.PromoCode
+	var active bool
+	err := tx.QueryRow(ctx, `
+        SELECT `+promoCodeColumns+`,
+               starts_at <= $2 AND (ends_at IS NULL OR ends_at > $2)
+        FROM promo_codes
+        WHERE code = $1
+        FOR UPDATE
+    `, code, time.Now()).Scan(&c.Code, &c.Item, &c.Kind, &c.Value, &c.MaxUses, &c.MaxUsesPerUser, &c.Uses,
+		&c.StartsAt, &c.EndsAt, &c.CreatedBy, &c.CreatedAt, &active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrPromoCodeNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !active || (c.Item != "" && c.Item != item) {
+		return storage.ErrPromoCodeNotApplicable
+	}
+	if c.MaxUses > 0 && c.Uses >= c.MaxUses {
+		return storage.ErrPromoCodeExhausted
+	}
+	if c.MaxUsesPerUser > 0 {
+		var used int
+		err = tx.QueryRow(ctx, `
+            SELECT COUNT(*) FROM promo_redemptions WHERE code = $1 AND username = $2
+        `, code, username).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used >= c.MaxUsesPerUser {
+			return storage.ErrPromoCodeUsed
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO promo_redemptions (code, username, created_at) VALUES ($1, $2, $3)
+    `, code, username, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE promo_codes SET uses = uses + 1 WHERE code = $1`, code)
+	return err
+}
@@ -0,0 +1,311 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type GrantStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateGrantStoragePostgres(postgresConnect string) (*GrantStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &GrantStoragePostgres{pool}, nil
+}
+
+func (st *GrantStoragePostgres) CreateBatch(ctx context.Context, createdBy string, status string, items []model.GrantItem) (int, error) {
+	query := `
+        INSERT INTO grant_batches (created_by, status, total)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `
+	ctx, end := track(ctx, "grants.CreateBatch", query)
+	defer end()
+
+	total := 0
+	for _, item := range items {
+		total += abs(item.Amount)
+	}
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx, query, createdBy, status, total).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([][]any, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []any{id, item.Username, item.Amount, item.Reason})
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"grant_items"},
+		[]string{"batch_id", "username", "amount", "reason"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+func (st *GrantStoragePostgres) GetBatch(ctx context.Context, id int) (*model.GrantBatch, error) {
+	query := `
+        SELECT id, created_by, status, total, COALESCE(approved_by, ''), created_at
+        FROM grant_batches
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "grants.GetBatch", query)
+	defer end()
+
+	var batch model.GrantBatch
+	err := st.pool.QueryRow(ctx, query, id).Scan(&batch.ID, &batch.CreatedBy, &batch.Status,
+		&batch.Total, &batch.ApprovedBy, &batch.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	batch.Items, err = getGrantItems(ctx, st.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (st *GrantStoragePostgres) GetBatches(ctx context.Context, status string, count int) ([]model.GrantBatch, error) {
+	query := `
+        SELECT id, created_by, status, total, COALESCE(approved_by, ''), created_at
+        FROM grant_batches
+        WHERE $1 = '' OR status = $1
+        ORDER BY id DESC
+    `
+	ctx, end := track(ctx, "grants.GetBatches", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []model.GrantBatch
+	for i := 0; rows.Next() && (i < count || count == -1); i++ {
+		var b model.GrantBatch
+		err := rows.Scan(&b.ID, &b.CreatedBy, &b.Status, &b.Total, &b.ApprovedBy, &b.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+func (st *GrantStoragePostgres) ApplyBatch(ctx context.Context, id int, approvedBy string) (*model.GrantBatch, error) {
+	query := `
+        SELECT status FROM grant_batches WHERE id = $1 FOR UPDATE
+    `
+	ctx, end := track(ctx, "grants.ApplyBatch", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, query, id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrGrantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != model.GrantPendingApproval && status != model.GrantApproved {
+		return nil, storage.ErrGrantNotPending
+	}
+
+	items, err := getGrantItems(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := false
+	now := time.Now()
+	for i := range items {
+		item := &items[i]
+		var userID, coins int
+		err := tx.QueryRow(ctx, `
+            SELECT id, coins FROM users WHERE username = $1 FOR UPDATE
+        `, item.Username).Scan(&userID, &coins)
+		if errors.Is(err, pgx.ErrNoRows) {
+			item.Error = storage.ErrUserNotFound.Error()
+			failed = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if coins+item.Amount < 0 {
+			item.Error = "not enough coins to revoke"
+			failed = true
+			continue
+		}
+		if failed {
+			// the batch is rolled back anyway, only validate the rest
+			continue
+		}
+
+		sender, receiver := model.SystemUser, item.Username
+		if item.Amount > 0 {
+			_, err = tx.Exec(ctx, `SELECT credit_coins($1, $2, $3)`, userID, item.Amount, model.LotGrant)
+		} else {
+			sender, receiver = item.Username, model.SystemUser
+			_, err = tx.Exec(ctx, `SELECT debit_coins($1, $2)`, userID, -item.Amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+            INSERT INTO transactions (sender_username, receiver_username, amount, created_at, reason)
+            VALUES ($1, $2, $3, $4, $5)
+        `, sender, receiver, abs(item.Amount), now, item.Reason)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if failed {
+		err = tx.Rollback(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = st.markFailed(ctx, id, items)
+		if err != nil {
+			return nil, err
+		}
+		batch, err := st.GetBatch(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return batch, storage.ErrGrantFailed
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE grant_batches
+        SET status = $2, approved_by = NULLIF($3, ''), applied_at = $4
+        WHERE id = $1
+    `, id, model.GrantApplied, approvedBy, now)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return st.GetBatch(ctx, id)
+}
+
+// markFailed stores the failed status and the item errors, so the report can be read later.
+func (st *GrantStoragePostgres) markFailed(ctx context.Context, id int, items []model.GrantItem) error {
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+        UPDATE grant_batches SET status = $2 WHERE id = $1
+    `, id, model.GrantFailed)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Error == "" {
+			continue
+		}
+		_, err = tx.Exec(ctx, `
+            UPDATE grant_items SET error = $2 WHERE id = $1
+        `, item.ID, item.Error)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (st *GrantStoragePostgres) RejectBatch(ctx context.Context, id int, rejectedBy string) error {
+	query := `
+        UPDATE grant_batches
+        SET status = $2, approved_by = $3
+        WHERE id = $1 AND status = $4
+    `
+	ctx, end := track(ctx, "grants.RejectBatch", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, id, model.GrantRejected, rejectedBy, model.GrantPendingApproval)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		_, err := st.GetBatch(ctx, id)
+		if err != nil {
+			return err
+		}
+		return storage.ErrGrantNotPending
+	}
+	return nil
+}
+
+func (st *GrantStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func getGrantItems(ctx context.Context, q querier, batchID int) ([]model.GrantItem, error) {
+	rows, err := q.Query(ctx, `
+        SELECT id, username, amount, reason, COALESCE(error, '')
+        FROM grant_items
+        WHERE batch_id = $1
+        ORDER BY id
+    `, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.GrantItem
+	for rows.Next() {
+		var item model.GrantItem
+		err := rows.Scan(&item.ID, &item.Username, &item.Amount, &item.Reason, &item.Error)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
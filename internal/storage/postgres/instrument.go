@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/tracing"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+// track measures a storage call: it opens a client span carrying the SQL statement
+// and returns a function that ends the span and records latency. Use as
+//
+//	ctx, end := track(ctx, "users.Create", query)
+//	defer end()
+func track(ctx context.Context, method string, query string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", method),
+			attribute.String("db.query.text", strings.Join(strings.Fields(query), " ")),
+		),
+	)
+	return ctx, func() {
+		span.End()
+		metrics.ObserveQuery(method, start)
+		logging.FromContext(ctx).Debug("db query", "method", method, "duration", time.Since(start))
+	}
+}
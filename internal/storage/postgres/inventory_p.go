@@ -1,46 +1,39 @@
 package postgres
 
 import (
+	"avito-merch-store/internal/storage"
 	"avito-merch-store/model"
 	"context"
-	"github.com/jackc/pgx/v5"
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type InventoryStoragePostgres struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func CreateInventoryStoragePostgres(postgresConnect string) (*InventoryStoragePostgres, error) {
-	conn, err := pgx.Connect(context.Background(), postgresConnect)
+	pool, err := connect(postgresConnect)
 	if err != nil {
 		return nil, err
 	}
-	return &InventoryStoragePostgres{conn}, nil
+	return &InventoryStoragePostgres{pool}, nil
 }
 
-func (st *InventoryStoragePostgres) AddItems(userID int, item string, quantity int) error {
-	ctx := context.Background()
-
-	query := `
-        INSERT INTO inventory (user_id, item_name, quantity)
-        VALUES ($1, $2, $3)
-        ON CONFLICT (user_id, item_name)
-        DO UPDATE SET quantity = inventory.quantity + EXCLUDED.quantity
-    `
-
-	_, err := st.conn.Exec(ctx, query, userID, item, quantity)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (st *InventoryStoragePostgres) GetByUserID(userID int, count int) ([]model.InventoryItem, error) {
+func (st *InventoryStoragePostgres) GetByUserID(ctx context.Context, userID int, count int) ([]model.InventoryItem, error) {
 	query := `
-        SELECT * FROM inventory WHERE user_id = $1
+        SELECT i.user_id, i.item_name, i.quantity, i.variant_id, COALESCE(v.size, ''), COALESCE(v.color, '')
+        FROM inventory i
+                 LEFT JOIN item_variants v ON v.id = i.variant_id
+        WHERE i.user_id = $1
+        ORDER BY i.item_name, i.variant_id
     `
-	response, err := st.conn.Query(context.Background(), query, userID)
+	ctx, end := track(ctx, "inventory.GetByUserID", query)
+	defer end()
+	response, err := st.pool.Query(ctx, query, userID)
 	defer response.Close()
 	if err != nil {
 		return nil, err
@@ -48,7 +41,7 @@ func (st *InventoryStoragePostgres) GetByUserID(userID int, count int) ([]model.
 	var res []model.InventoryItem
 	for i := 0; response.Next() && (i < count || count == -1); i++ {
 		var item model.InventoryItem
-		err := response.Scan(&item.UserID, &item.ItemName, &item.Quantity)
+		err := response.Scan(&item.UserID, &item.ItemName, &item.Quantity, &item.VariantID, &item.Size, &item.Color)
 		if err != nil {
 			return nil, err
 		}
@@ -57,3 +50,86 @@ func (st *InventoryStoragePostgres) GetByUserID(userID int, count int) ([]model.
 
 	return res, nil
 }
+
+func (st *InventoryStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func (st *InventoryStoragePostgres) Purchase(ctx context.Context, p model.Purchase) error {
+	query := `
+        INSERT INTO purchases (username, item, price, original_price, discount, promo_code, variant_id, created_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+        RETURNING id
+    `
+	ctx, end := track(ctx, "inventory.Purchase", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if p.VariantID != 0 {
+		result, err := tx.Exec(ctx, `
+            UPDATE item_variants SET stock = stock - 1 WHERE id = $1 AND stock > 0
+        `, p.VariantID)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return storage.ErrOutOfStock
+		}
+	}
+	if p.PromoCode != "" {
+		if err = redeemPromoCode(ctx, tx, p.PromoCode, p.Username, p.Item); err != nil {
+			return err
+		}
+	}
+	if p.Price > 0 {
+		_, err = tx.Exec(ctx, `SELECT debit_coins((SELECT id FROM users WHERE username = $1), $2)`, p.Username, p.Price)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return storage.ErrNotEnoughCoins
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO inventory (user_id, item_name, quantity, variant_id)
+        VALUES ((SELECT id FROM users WHERE username = $1), $2, 1, $3)
+        ON CONFLICT (user_id, item_name, variant_id)
+        DO UPDATE SET quantity = inventory.quantity + EXCLUDED.quantity
+    `, p.Username, p.Item, p.VariantID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var id int
+	err = tx.QueryRow(ctx, query, p.Username, p.Item, p.Price, p.OriginalPrice, p.Discount, p.PromoCode,
+		p.VariantID, now).Scan(&id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO orders (purchase_id, username, item, variant_id, refund, status, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+    `, id, p.Username, p.Item, p.VariantID, p.Price, model.OrderPlaced, now)
+	if err != nil {
+		return err
+	}
+	err = insertEvent(ctx, tx, model.EventItemPurchased, model.ItemPurchased{
+		PurchaseID:    id,
+		Username:      p.Username,
+		Item:          p.Item,
+		VariantID:     p.VariantID,
+		Price:         p.Price,
+		OriginalPrice: p.OriginalPrice,
+		PromoCode:     p.PromoCode,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
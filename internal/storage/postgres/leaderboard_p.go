@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// activityColumns maps activity boards to the columns of leaderboard_daily.
+var activityColumns = map[string]string{
+	model.BoardSenders:   "sent",
+	model.BoardReceivers: "received",
+	model.BoardPurchases: "bought",
+}
+
+type LeaderboardStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateLeaderboardStoragePostgres(postgresConnect string) (*LeaderboardStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaderboardStoragePostgres{pool}, nil
+}
+
+func (st *LeaderboardStoragePostgres) GetBoard(ctx context.Context, board string, since time.Time,
+	limit int) ([]model.LeaderboardEntry, error) {
+	var query string
+	args := []any{model.SystemUser, limit}
+	if board == model.BoardBalance {
+		query = `
+        SELECT username, coins
+        FROM users
+        WHERE NOT leaderboard_opt_out AND username <> $1
+        ORDER BY coins DESC, username
+        LIMIT $2
+    `
+	} else {
+		column, ok := activityColumns[board]
+		if !ok {
+			return nil, fmt.Errorf("unknown leaderboard %q", board)
+		}
+		query = `
+        SELECT d.username, SUM(d.` + column + `)::INT AS value
+        FROM leaderboard_daily d
+                 JOIN users u ON u.username = d.username
+        WHERE NOT u.leaderboard_opt_out AND d.username <> $1 AND d.day >= $3
+        GROUP BY d.username
+        HAVING SUM(d.` + column + `) > 0
+        ORDER BY value DESC, d.username
+        LIMIT $2
+    `
+		args = append(args, since)
+	}
+	ctx, end := track(ctx, "leaderboards.GetBoard", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.LeaderboardEntry{}
+	for rows.Next() {
+		entry := model.LeaderboardEntry{Rank: len(entries) + 1}
+		if err := rows.Scan(&entry.Username, &entry.Value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (st *LeaderboardStoragePostgres) Refresh(ctx context.Context) error {
+	query := `
+        REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard_daily
+    `
+	ctx, end := track(ctx, "leaderboards.Refresh", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query)
+	return err
+}
+
+func (st *LeaderboardStoragePostgres) SetOptOut(ctx context.Context, username string, optOut bool) error {
+	query := `
+        UPDATE users SET leaderboard_opt_out = $2 WHERE username = $1
+    `
+	ctx, end := track(ctx, "leaderboards.SetOptOut", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, username, optOut)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func (st *LeaderboardStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type LotStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateLotStoragePostgres(postgresConnect string) (*LotStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &LotStoragePostgres{pool}, nil
+}
+
+func (st *LotStoragePostgres) SetExpiryPolicies(ctx context.Context, policies map[string]time.Duration) error {
+	query := `
+        INSERT INTO coin_expiry_policies (source, ttl)
+        VALUES ($1, $2)
+    `
+	ctx, end := track(ctx, "lots.SetExpiryPolicies", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM coin_expiry_policies`)
+	if err != nil {
+		return err
+	}
+	for source, ttl := range policies {
+		_, err = tx.Exec(ctx, query, source, ttl)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (st *LotStoragePostgres) ExpireLots(ctx context.Context, limit int) (int, int, error) {
+	query := `
+        WITH owners AS (
+            SELECT id
+            FROM users
+            WHERE id IN (SELECT user_id FROM coin_lots WHERE remaining > 0 AND expires_at <= NOW())
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ), due AS (
+            SELECT l.id, l.user_id, l.remaining
+            FROM coin_lots l
+                     JOIN owners o ON o.id = l.user_id
+            WHERE l.remaining > 0 AND l.expires_at <= NOW()
+            ORDER BY l.expires_at
+            LIMIT $1
+            FOR UPDATE OF l
+        ), burnt AS (
+            UPDATE coin_lots l
+            SET remaining = 0
+            FROM due
+            WHERE l.id = due.id
+        ), per_user AS (
+            SELECT user_id, SUM(remaining) AS amount FROM due GROUP BY user_id
+        ), debited AS (
+            UPDATE users u
+            SET coins = u.coins - p.amount
+            FROM per_user p
+            WHERE u.id = p.user_id
+            RETURNING u.username, p.amount
+        ), history AS (
+            INSERT INTO transactions (sender_username, receiver_username, amount, created_at, reason)
+            SELECT username, $2, amount, NOW(), 'coins expired' FROM debited
+        )
+        SELECT (SELECT COUNT(*) FROM due), COALESCE((SELECT SUM(amount) FROM debited), 0)
+    `
+	ctx, end := track(ctx, "lots.ExpireLots", query)
+	defer end()
+
+	// owners are locked before their lots like in debit_coins, users busy with a debit are skipped
+	var lots, coins int
+	err := st.pool.QueryRow(ctx, query, limit, model.SystemUser).Scan(&lots, &coins)
+	if err != nil {
+		return 0, 0, err
+	}
+	return lots, coins, nil
+}
+
+func (st *LotStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
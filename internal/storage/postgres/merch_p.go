@@ -4,15 +4,19 @@ import (
 	"avito-merch-store/internal/storage"
 	"avito-merch-store/model"
 	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type MerchStoragePostgres struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func CreateMerchStoragePostgres(postgresConnect string, items []model.Item) (*MerchStoragePostgres, error) {
-	conn, err := pgx.Connect(context.Background(), postgresConnect)
+	pool, err := connect(postgresConnect)
 	if err != nil {
 		return nil, err
 	}
@@ -23,25 +27,23 @@ func CreateMerchStoragePostgres(postgresConnect string, items []model.Item) (*Me
     `
 
 	for _, item := range items {
-		_, err := conn.Exec(context.Background(), query, item.Name, item.Price)
+		_, err := pool.Exec(context.Background(), query, item.Name, item.Price)
 
 		if err != nil {
-			err1 := conn.Close(context.Background())
-			if err1 != nil {
-				return nil, err1
-			}
 			return nil, err
 		}
 	}
 
-	return &MerchStoragePostgres{conn}, nil
+	return &MerchStoragePostgres{pool}, nil
 }
 
-func (st *MerchStoragePostgres) GetByName(item string) (int, error) {
+func (st *MerchStoragePostgres) GetByName(ctx context.Context, item string) (int, error) {
 	query := `
         SELECT price from merch WHERE name=$1
     `
-	ans, err := st.conn.Query(context.Background(), query, item)
+	ctx, end := track(ctx, "merch.GetByName", query)
+	defer end()
+	ans, err := st.pool.Query(ctx, query, item)
 	defer ans.Close()
 	if err != nil {
 		return -1, err
@@ -56,3 +58,172 @@ func (st *MerchStoragePostgres) GetByName(item string) (int, error) {
 	}
 	return res, nil
 }
+
+func (st *MerchStoragePostgres) GetAll(ctx context.Context, filter model.CatalogFilter) ([]model.CatalogItem, error) {
+	query := `
+        SELECT m.name, m.price, COUNT(w.user_id), m.category, m.description, m.image_url, m.tags, m.display_order
+        FROM merch m
+                 LEFT JOIN wishlist_items w ON w.item_name = m.name
+        WHERE ($1 = '' OR m.category = $1)
+          AND ($2 = '' OR $2 = ANY (m.tags))
+        GROUP BY m.id
+        ORDER BY m.display_order, m.id
+    `
+	ctx, end := track(ctx, "merch.GetAll", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, filter.Category, filter.Tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.CatalogItem
+	for rows.Next() {
+		var item model.CatalogItem
+		err := rows.Scan(&item.Name, &item.Price, &item.Wishes, &item.Category, &item.Description, &item.ImageURL,
+			&item.Tags, &item.DisplayOrder)
+		if err != nil {
+			return nil, err
+		}
+		item.OriginalPrice = item.Price
+		res = append(res, item)
+	}
+	return res, rows.Err()
+}
+
+func (st *MerchStoragePostgres) UpdateDetails(ctx context.Context, item string, details model.ItemDetails) (string, error) {
+	query := `
+        UPDATE merch m
+        SET category = $2, description = $3, image_url = $4, tags = $5, display_order = $6
+        FROM merch old
+        WHERE m.name = $1 AND old.id = m.id
+        RETURNING old.image_url
+    `
+	ctx, end := track(ctx, "merch.UpdateDetails", query)
+	defer end()
+
+	tags := details.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var previous string
+	err := st.pool.QueryRow(ctx, query, item, details.Category, details.Description, details.ImageURL, tags,
+		details.DisplayOrder).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", storage.ErrMerchNotFound
+	}
+	return previous, err
+}
+
+func (st *MerchStoragePostgres) SetImage(ctx context.Context, item string, imageURL string) (string, error) {
+	query := `
+        UPDATE merch m
+        SET image_url = $2
+        FROM merch old
+        WHERE m.name = $1 AND old.id = m.id
+        RETURNING old.image_url
+    `
+	ctx, end := track(ctx, "merch.SetImage", query)
+	defer end()
+
+	var previous string
+	err := st.pool.QueryRow(ctx, query, item, imageURL).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", storage.ErrMerchNotFound
+	}
+	return previous, err
+}
+
+func (st *MerchStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func (st *MerchStoragePostgres) GetVariants(ctx context.Context, item string) ([]model.ItemVariant, error) {
+	query := `
+        SELECT id, item_name, size, color, stock, price_delta
+        FROM item_variants
+        WHERE $1 = '' OR item_name = $1
+        ORDER BY item_name, id
+    `
+	ctx, end := track(ctx, "merch.GetVariants", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, item)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.ItemVariant
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *v)
+	}
+	return res, rows.Err()
+}
+
+func (st *MerchStoragePostgres) GetVariant(ctx context.Context, item string, size string, color string) (*model.ItemVariant, error) {
+	query := `
+        SELECT id, item_name, size, color, stock, price_delta
+        FROM item_variants
+        WHERE item_name = $1 AND size = $2 AND color = $3
+    `
+	ctx, end := track(ctx, "merch.GetVariant", query)
+	defer end()
+
+	v, err := scanVariant(st.pool.QueryRow(ctx, query, item, size, color))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrVariantNotFound
+	}
+	return v, err
+}
+
+func (st *MerchStoragePostgres) CreateVariant(ctx context.Context, v model.ItemVariant) (int, error) {
+	query := `
+        INSERT INTO item_variants (item_name, size, color, stock, price_delta)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `
+	ctx, end := track(ctx, "merch.CreateVariant", query)
+	defer end()
+
+	var id int
+	err := st.pool.QueryRow(ctx, query, v.Item, v.Size, v.Color, v.Stock, v.PriceDelta).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return 0, storage.ErrVariantExists
+	}
+	if isForeignKeyViolation(err) {
+		return 0, storage.ErrMerchNotFound
+	}
+	return id, err
+}
+
+func (st *MerchStoragePostgres) UpdateVariant(ctx context.Context, id int, stock int, priceDelta int) (*model.ItemVariant, error) {
+	query := `
+        UPDATE item_variants
+        SET stock = $2, price_delta = $3
+        WHERE id = $1
+        RETURNING id, item_name, size, color, stock, price_delta
+    `
+	ctx, end := track(ctx, "merch.UpdateVariant", query)
+	defer end()
+
+	v, err := scanVariant(st.pool.QueryRow(ctx, query, id, stock, priceDelta))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrVariantNotFound
+	}
+	return v, err
+}
+
+func scanVariant(row pgx.Row) (*model.ItemVariant, error) {
+	var v model.ItemVariant
+	if err := row.Scan(&v.ID, &v.Item, &v.Size, &v.Color, &v.Stock, &v.PriceDelta); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
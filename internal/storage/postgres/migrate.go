@@ -1,8 +1,14 @@
 package postgres
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"os"
 	"strings"
 )
 
@@ -52,3 +58,76 @@ func DownMigrations(postgresConnect string, path string) error {
 	}
 	return nil
 }
+
+// latestMigration returns the highest migration version available at path.
+func latestMigration(path string) (uint, error) {
+	src, err := source.Open("file:/" + path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// MigrationStatusPostgres backs the readiness checks. It has a pool of its own,
+// so probes neither wait for nor interfere with the connections serving requests.
+type MigrationStatusPostgres struct {
+	pool     *pgxpool.Pool
+	expected uint
+}
+
+func CreateMigrationStatusPostgres(postgresConnect string, path string) (*MigrationStatusPostgres, error) {
+	expected, err := latestMigration(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.New(context.Background(), postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &MigrationStatusPostgres{pool, expected}, nil
+}
+
+// Ping fails when the database does not accept connections.
+func (ms *MigrationStatusPostgres) Ping(ctx context.Context) error {
+	return ms.pool.Ping(ctx)
+}
+
+// Check fails when the schema is dirty or behind the migrations shipped with the binary.
+func (ms *MigrationStatusPostgres) Check(ctx context.Context) error {
+	query := `
+        SELECT version, dirty FROM schema_migrations LIMIT 1
+    `
+	ctx, end := track(ctx, "migrations.Check", query)
+	defer end()
+	var version uint
+	var dirty bool
+	err := ms.pool.QueryRow(ctx, query).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("no migrations applied, expected version %d", ms.expected)
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < ms.expected {
+		return fmt.Errorf("schema version %d, expected %d", version, ms.expected)
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_wishlist_items_item;
+
+DROP TABLE IF EXISTS wishlist_items;
+
+ALTER TABLE users DROP COLUMN IF EXISTS wishlist_visibility;
@@ -0,0 +1,13 @@
+-- Wishlists are private unless their owner shares them
+ALTER TABLE users ADD COLUMN IF NOT EXISTS wishlist_visibility VARCHAR(16) NOT NULL DEFAULT 'private';
+
+-- Create wishlist items table
+CREATE TABLE IF NOT EXISTS wishlist_items
+(
+    user_id    INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    item_name  VARCHAR(255) NOT NULL REFERENCES merch (name) ON DELETE CASCADE,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, item_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_item ON wishlist_items (item_name);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_leaderboard_daily;
+DROP MATERIALIZED VIEW IF EXISTS leaderboard_daily;
+
+DROP INDEX IF EXISTS idx_purchases_username;
+DROP TABLE IF EXISTS purchases;
+
+ALTER TABLE users DROP COLUMN IF EXISTS leaderboard_opt_out;
@@ -0,0 +1,42 @@
+-- Users may hide themselves from leaderboards
+ALTER TABLE users ADD COLUMN IF NOT EXISTS leaderboard_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create purchases log, inventory keeps only the quantities
+CREATE TABLE IF NOT EXISTS purchases
+(
+    id         SERIAL PRIMARY KEY,
+    username   VARCHAR(255) NOT NULL,
+    item       VARCHAR(255) NOT NULL,
+    price      INT          NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchases_username ON purchases (username);
+
+-- Earlier purchases have no date, they only count for the all-time board
+INSERT INTO purchases (username, item, price, created_at)
+SELECT u.username, i.item_name, COALESCE(m.price, 0), TIMESTAMP '1970-01-01'
+FROM inventory i
+         JOIN users u ON u.id = i.user_id
+         LEFT JOIN merch m ON m.name = i.item_name
+         CROSS JOIN generate_series(1, i.quantity)
+WHERE NOT EXISTS (SELECT 1 FROM purchases);
+
+-- Daily activity per user, refreshed periodically by the application
+CREATE MATERIALIZED VIEW IF NOT EXISTS leaderboard_daily AS
+SELECT username, day, SUM(sent) AS sent, SUM(received) AS received, SUM(bought) AS bought
+FROM (SELECT sender_username AS username, date_trunc('day', created_at) AS day,
+             amount AS sent, 0 AS received, 0 AS bought
+      FROM transactions
+      WHERE status = 'completed' AND sender_username <> 'system' AND receiver_username <> 'system'
+      UNION ALL
+      SELECT receiver_username, date_trunc('day', created_at), 0, amount, 0
+      FROM transactions
+      WHERE status = 'completed' AND sender_username <> 'system' AND receiver_username <> 'system'
+      UNION ALL
+      SELECT username, date_trunc('day', created_at), 0, 0, 1
+      FROM purchases) activity
+GROUP BY username, day;
+
+-- Required by REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS idx_leaderboard_daily ON leaderboard_daily (username, day);
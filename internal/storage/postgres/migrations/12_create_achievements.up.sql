@@ -0,0 +1,9 @@
+-- Create awarded achievements table, every achievement is awarded once per user
+CREATE TABLE IF NOT EXISTS achievements
+(
+    username   VARCHAR(255) NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+    code       VARCHAR(64)  NOT NULL,
+    reward     INT          NOT NULL DEFAULT 0,
+    awarded_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (username, code)
+);
@@ -0,0 +1,10 @@
+ALTER TABLE purchases DROP COLUMN IF EXISTS promo_code;
+ALTER TABLE purchases DROP COLUMN IF EXISTS discount;
+ALTER TABLE purchases DROP COLUMN IF EXISTS original_price;
+
+DROP INDEX IF EXISTS idx_promo_redemptions_code;
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
+
+DROP INDEX IF EXISTS idx_sales_active;
+DROP TABLE IF EXISTS sales;
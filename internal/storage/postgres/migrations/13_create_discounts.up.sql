@@ -0,0 +1,45 @@
+-- Create sales table, a sale without item applies to every item
+CREATE TABLE IF NOT EXISTS sales
+(
+    id         SERIAL PRIMARY KEY,
+    item       VARCHAR(255) REFERENCES merch (name) ON DELETE CASCADE,
+    kind       VARCHAR(16)  NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value      INT          NOT NULL CHECK (value > 0),
+    starts_at  TIMESTAMP    NOT NULL,
+    ends_at    TIMESTAMP    NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_active ON sales (starts_at, ends_at);
+
+-- Create promo codes table, zero limits mean unlimited
+CREATE TABLE IF NOT EXISTS promo_codes
+(
+    code              VARCHAR(32) PRIMARY KEY,
+    item              VARCHAR(255) REFERENCES merch (name) ON DELETE CASCADE,
+    kind              VARCHAR(16)  NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value             INT          NOT NULL CHECK (value > 0),
+    max_uses          INT          NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+    max_uses_per_user INT          NOT NULL DEFAULT 1 CHECK (max_uses_per_user >= 0),
+    uses              INT          NOT NULL DEFAULT 0,
+    starts_at         TIMESTAMP    NOT NULL,
+    ends_at           TIMESTAMP,
+    created_by        VARCHAR(255) NOT NULL,
+    created_at        TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions
+(
+    id         SERIAL PRIMARY KEY,
+    code       VARCHAR(32)  NOT NULL REFERENCES promo_codes (code) ON DELETE CASCADE,
+    username   VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_code ON promo_redemptions (code, username);
+
+-- Purchases keep the price before discounts and the discount applied
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS original_price INT;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS discount INT NOT NULL DEFAULT 0;
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS promo_code VARCHAR(32);
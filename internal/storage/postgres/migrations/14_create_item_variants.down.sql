@@ -0,0 +1,14 @@
+ALTER TABLE purchases DROP COLUMN IF EXISTS variant_id;
+
+-- Merge the variants of every item back into one row
+WITH merged AS (DELETE FROM inventory RETURNING user_id, item_name, quantity)
+INSERT INTO inventory (user_id, item_name, quantity, variant_id)
+SELECT user_id, item_name, SUM(quantity), 0
+FROM merged
+GROUP BY user_id, item_name;
+
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_pkey;
+ALTER TABLE inventory DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE inventory ADD PRIMARY KEY (user_id, item_name);
+
+DROP TABLE IF EXISTS item_variants;
@@ -0,0 +1,18 @@
+-- Create item variants table, every combination of size and color has its own stock
+CREATE TABLE IF NOT EXISTS item_variants
+(
+    id          SERIAL PRIMARY KEY,
+    item_name   VARCHAR(255) NOT NULL REFERENCES merch (name) ON DELETE CASCADE,
+    size        VARCHAR(32)  NOT NULL DEFAULT '',
+    color       VARCHAR(32)  NOT NULL DEFAULT '',
+    stock       INT          NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    price_delta INT          NOT NULL DEFAULT 0,
+    UNIQUE (item_name, size, color)
+);
+
+-- Inventory counts items per variant, 0 stands for items without variants
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS variant_id INT NOT NULL DEFAULT 0;
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_pkey;
+ALTER TABLE inventory ADD PRIMARY KEY (user_id, item_name, variant_id);
+
+ALTER TABLE purchases ADD COLUMN IF NOT EXISTS variant_id INT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_merch_tags;
+DROP INDEX IF EXISTS idx_merch_category;
+
+ALTER TABLE merch DROP COLUMN IF EXISTS display_order;
+ALTER TABLE merch DROP COLUMN IF EXISTS tags;
+ALTER TABLE merch DROP COLUMN IF EXISTS image_url;
+ALTER TABLE merch DROP COLUMN IF EXISTS description;
+ALTER TABLE merch DROP COLUMN IF EXISTS category;
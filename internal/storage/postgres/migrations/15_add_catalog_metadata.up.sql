@@ -0,0 +1,9 @@
+-- Catalog metadata of items, image_url points either outside or to an uploaded image
+ALTER TABLE merch ADD COLUMN IF NOT EXISTS category VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE merch ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE merch ADD COLUMN IF NOT EXISTS image_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE merch ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE merch ADD COLUMN IF NOT EXISTS display_order INT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_merch_category ON merch (category);
+CREATE INDEX IF NOT EXISTS idx_merch_tags ON merch USING GIN (tags);
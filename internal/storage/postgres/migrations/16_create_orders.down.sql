@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_queue;
+DROP INDEX IF EXISTS idx_orders_username;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS pickup_locations;
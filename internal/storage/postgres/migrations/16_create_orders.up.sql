@@ -0,0 +1,29 @@
+-- Offices where purchased merch is handed over
+CREATE TABLE IF NOT EXISTS pickup_locations
+(
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL UNIQUE,
+    address    TEXT         NOT NULL DEFAULT '',
+    active     BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+-- Every purchase becomes an order to fulfill, earlier purchases are considered handed over
+CREATE TABLE IF NOT EXISTS orders
+(
+    id          SERIAL PRIMARY KEY,
+    purchase_id INT          NOT NULL UNIQUE REFERENCES purchases (id),
+    username    VARCHAR(255) NOT NULL,
+    item        VARCHAR(255) NOT NULL,
+    variant_id  INT          NOT NULL DEFAULT 0,
+    -- coins returned on cancellation, pooled purchases are paid by others and refund nothing
+    refund      INT          NOT NULL DEFAULT 0,
+    status      VARCHAR(32)  NOT NULL DEFAULT 'placed',
+    location_id INT REFERENCES pickup_locations (id),
+    handled_by  VARCHAR(255),
+    created_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_username ON orders (username);
+CREATE INDEX IF NOT EXISTS idx_orders_queue ON orders (status, location_id) WHERE status IN ('placed', 'ready_for_pickup');
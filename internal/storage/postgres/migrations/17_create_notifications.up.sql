@@ -0,0 +1,42 @@
+-- Channels a user wants to be notified through, users without a row get only the inbox
+CREATE TABLE IF NOT EXISTS notification_preferences
+(
+    username       VARCHAR(255) PRIMARY KEY,
+    inbox          BOOLEAN      NOT NULL DEFAULT TRUE,
+    email          BOOLEAN      NOT NULL DEFAULT FALSE,
+    email_address  VARCHAR(255) NOT NULL DEFAULT '',
+    webhook        BOOLEAN      NOT NULL DEFAULT FALSE,
+    webhook_url    TEXT         NOT NULL DEFAULT '',
+    webhook_secret VARCHAR(64)  NOT NULL DEFAULT '',
+    updated_at     TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS notifications
+(
+    id         SERIAL PRIMARY KEY,
+    username   VARCHAR(255) NOT NULL,
+    kind       VARCHAR(32)  NOT NULL,
+    title      TEXT         NOT NULL,
+    body       TEXT         NOT NULL DEFAULT '',
+    -- shown in the inbox, notifications for other channels only are kept for their deliveries
+    inbox      BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    read_at    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_inbox ON notifications (username, id) WHERE inbox;
+
+-- Sending of a notification through an outside channel, retried until it succeeds or runs out of attempts
+CREATE TABLE IF NOT EXISTS notification_deliveries
+(
+    id              SERIAL PRIMARY KEY,
+    notification_id INT         NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+    channel         VARCHAR(16) NOT NULL,
+    status          VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP   NOT NULL DEFAULT NOW(),
+    last_error      TEXT        NOT NULL DEFAULT '',
+    updated_at      TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries (next_attempt_at) WHERE status = 'pending';
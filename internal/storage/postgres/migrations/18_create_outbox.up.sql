@@ -0,0 +1,17 @@
+-- Domain events written in the same transaction as the change they describe,
+-- the relay publishes them in seq order and marks them published
+CREATE TABLE IF NOT EXISTS outbox
+(
+    seq             BIGSERIAL PRIMARY KEY,
+    id              UUID        NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    type            VARCHAR(64) NOT NULL,
+    payload         JSONB       NOT NULL,
+    created_at      TIMESTAMP   NOT NULL DEFAULT NOW(),
+    published_at    TIMESTAMP,
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP   NOT NULL DEFAULT NOW(),
+    last_error      TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (seq) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,14 @@
+-- Machine credentials of integrations, only the SHA-256 of a key is stored
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id           SERIAL PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    prefix       VARCHAR(32)  NOT NULL UNIQUE,
+    key_hash     VARCHAR(64)  NOT NULL,
+    scopes       TEXT[]       NOT NULL,
+    created_by   VARCHAR(255) NOT NULL,
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at   TIMESTAMP
+);
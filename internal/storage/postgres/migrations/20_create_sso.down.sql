@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sso_logins;
+DROP INDEX IF EXISTS idx_identities_username;
+DROP TABLE IF EXISTS identities;
@@ -0,0 +1,22 @@
+-- Corporate identities mapped to store users, a subject is unique per issuer
+CREATE TABLE IF NOT EXISTS identities
+(
+    issuer        VARCHAR(255) NOT NULL,
+    subject       VARCHAR(255) NOT NULL,
+    username      VARCHAR(255) NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+    email         VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMP    NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identities_username ON identities (username);
+
+-- Logins started at the identity provider and not completed yet
+CREATE TABLE IF NOT EXISTS sso_logins
+(
+    state      VARCHAR(64)  NOT NULL PRIMARY KEY,
+    nonce      VARCHAR(64)  NOT NULL,
+    verifier   VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP    NOT NULL
+);
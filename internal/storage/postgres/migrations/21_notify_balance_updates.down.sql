@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS users_balance_update ON users;
+DROP FUNCTION IF EXISTS notify_balance_update();
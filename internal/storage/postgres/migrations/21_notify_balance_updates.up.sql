@@ -0,0 +1,18 @@
+-- Every balance change is pushed to the live streams as a balance update, whichever
+-- path made it. The notification is delivered only when the transaction commits.
+CREATE OR REPLACE FUNCTION notify_balance_update() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('user_updates', json_build_object(
+            'username', NEW.username,
+            'update', json_build_object('type', 'balance', 'coins', NEW.coins))::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_balance_update
+    AFTER UPDATE OF coins
+    ON users
+    FOR EACH ROW
+    WHEN (OLD.coins IS DISTINCT FROM NEW.coins)
+EXECUTE FUNCTION notify_balance_update();
@@ -0,0 +1,7 @@
+-- Create token buckets table shared by all application instances
+CREATE TABLE IF NOT EXISTS rate_limits
+(
+    key        VARCHAR(255) PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP        NOT NULL DEFAULT NOW()
+);
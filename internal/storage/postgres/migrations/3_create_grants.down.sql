@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_grant_batches_status;
+DROP INDEX IF EXISTS idx_grant_items_batch;
+
+DROP TABLE IF EXISTS grant_items;
+DROP TABLE IF EXISTS grant_batches;
+
+DELETE FROM transactions WHERE sender_username = 'system' OR receiver_username = 'system';
+DELETE FROM users WHERE username = 'system';
+ALTER TABLE transactions DROP COLUMN IF EXISTS reason;
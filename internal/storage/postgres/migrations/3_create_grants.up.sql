@@ -0,0 +1,33 @@
+-- Reason of transactions issued by the store
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reason TEXT;
+
+-- System account used as sender of grants and receiver of revocations
+INSERT INTO users (username, coins)
+VALUES ('system', 0)
+ON CONFLICT (username) DO NOTHING;
+
+-- Create grant batches table
+CREATE TABLE IF NOT EXISTS grant_batches
+(
+    id          SERIAL PRIMARY KEY,
+    created_by  VARCHAR(255) NOT NULL,
+    status      VARCHAR(32)  NOT NULL,
+    total       INT          NOT NULL,
+    approved_by VARCHAR(255),
+    created_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    applied_at  TIMESTAMP
+);
+
+-- Create grant items table
+CREATE TABLE IF NOT EXISTS grant_items
+(
+    id       SERIAL PRIMARY KEY,
+    batch_id INT          NOT NULL REFERENCES grant_batches (id) ON DELETE CASCADE,
+    username VARCHAR(255) NOT NULL,
+    amount   INT          NOT NULL CHECK (amount <> 0),
+    reason   TEXT         NOT NULL,
+    error    TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_grant_items_batch ON grant_items (batch_id);
+CREATE INDEX IF NOT EXISTS idx_grant_batches_status ON grant_batches (status);
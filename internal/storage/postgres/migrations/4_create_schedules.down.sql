@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_allowance_schedules_due;
+DROP INDEX IF EXISTS idx_allowance_runs_period;
+
+DROP TABLE IF EXISTS allowance_runs;
+DROP TABLE IF EXISTS allowance_schedules;
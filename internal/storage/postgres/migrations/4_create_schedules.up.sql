@@ -0,0 +1,30 @@
+-- Create recurring allowance schedules table
+CREATE TABLE IF NOT EXISTS allowance_schedules
+(
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    amount      INT          NOT NULL CHECK (amount > 0),
+    max_balance INT CHECK (max_balance > 0),
+    period      VARCHAR(16)  NOT NULL CHECK (period IN ('daily', 'weekly', 'monthly')),
+    status      VARCHAR(16)  NOT NULL DEFAULT 'active',
+    next_run_at TIMESTAMP    NOT NULL,
+    created_by  VARCHAR(255) NOT NULL,
+    created_at  TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+-- Create schedule runs table, one completed run per schedule and period
+CREATE TABLE IF NOT EXISTS allowance_runs
+(
+    id             SERIAL PRIMARY KEY,
+    schedule_id    INT         NOT NULL REFERENCES allowance_schedules (id) ON DELETE CASCADE,
+    period_start   TIMESTAMP   NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    users_credited INT         NOT NULL DEFAULT 0,
+    coins_total    INT         NOT NULL DEFAULT 0,
+    error          TEXT,
+    finished_at    TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_allowance_runs_period
+    ON allowance_runs (schedule_id, period_start) WHERE status = 'completed';
+CREATE INDEX IF NOT EXISTS idx_allowance_schedules_due ON allowance_schedules (status, next_run_at);
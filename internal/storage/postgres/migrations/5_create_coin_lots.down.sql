@@ -0,0 +1,8 @@
+DROP FUNCTION IF EXISTS debit_coins(INT, INT);
+DROP FUNCTION IF EXISTS credit_coins(INT, INT, VARCHAR);
+
+DROP INDEX IF EXISTS idx_coin_lots_expires;
+DROP INDEX IF EXISTS idx_coin_lots_user;
+
+DROP TABLE IF EXISTS coin_lots;
+DROP TABLE IF EXISTS coin_expiry_policies;
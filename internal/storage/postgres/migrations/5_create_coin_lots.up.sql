@@ -0,0 +1,61 @@
+-- Lifetime of coins by the way they were received, sources without a row never expire
+CREATE TABLE IF NOT EXISTS coin_expiry_policies
+(
+    source VARCHAR(32) PRIMARY KEY,
+    ttl    INTERVAL    NOT NULL
+);
+
+-- Every credit of coins forms a lot, users.coins is the sum of remaining amounts
+CREATE TABLE IF NOT EXISTS coin_lots
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount     INT         NOT NULL CHECK (amount > 0),
+    remaining  INT         NOT NULL CHECK (remaining >= 0),
+    source     VARCHAR(32) NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_coin_lots_user ON coin_lots (user_id) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_coin_lots_expires ON coin_lots (expires_at) WHERE remaining > 0;
+
+-- Existing balances become lots that never expire
+INSERT INTO coin_lots (user_id, amount, remaining, source)
+SELECT id, coins, coins, 'initial'
+FROM users
+WHERE coins > 0;
+
+-- credit_coins adds coins to the balance and records them as a new lot
+CREATE OR REPLACE FUNCTION credit_coins(p_user_id INT, p_amount INT, p_source VARCHAR) RETURNS VOID AS
+$$
+BEGIN
+    UPDATE users SET coins = coins + p_amount WHERE id = p_user_id;
+    INSERT INTO coin_lots (user_id, amount, remaining, source, expires_at)
+    VALUES (p_user_id, p_amount, p_amount, p_source,
+            NOW() + (SELECT ttl FROM coin_expiry_policies WHERE source = p_source));
+END;
+$$ LANGUAGE plpgsql;
+
+-- debit_coins takes coins from the balance consuming the oldest lots first
+CREATE OR REPLACE FUNCTION debit_coins(p_user_id INT, p_amount INT) RETURNS VOID AS
+$$
+DECLARE
+    lot  RECORD;
+    left INT := p_amount;
+    took INT;
+BEGIN
+    UPDATE users SET coins = coins - p_amount WHERE id = p_user_id;
+    FOR lot IN SELECT id, remaining
+               FROM coin_lots
+               WHERE user_id = p_user_id AND remaining > 0
+               ORDER BY created_at, id
+               FOR UPDATE
+        LOOP
+            EXIT WHEN left = 0;
+            took := LEAST(lot.remaining, left);
+            UPDATE coin_lots SET remaining = remaining - took WHERE id = lot.id;
+            left := left - took;
+        END LOOP;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_sender_created;
+
+ALTER TABLE users DROP COLUMN IF EXISTS created_at;
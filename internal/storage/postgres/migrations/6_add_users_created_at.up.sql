@@ -0,0 +1,9 @@
+-- Registration time of users, used to restrict transfers from new accounts
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+UPDATE users u
+SET created_at = a.created_at
+FROM auth a
+WHERE a.username = u.username AND a.created_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_sender_created ON transactions (sender_username, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_pending;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS resolved_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS status;
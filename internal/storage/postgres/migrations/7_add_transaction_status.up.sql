@@ -0,0 +1,6 @@
+-- Transfers may wait for the recipient to accept them
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'completed';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_pending ON transactions (expires_at) WHERE status = 'pending';
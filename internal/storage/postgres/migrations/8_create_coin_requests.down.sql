@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_coin_requests_pending;
+DROP INDEX IF EXISTS idx_coin_requests_requester;
+DROP INDEX IF EXISTS idx_coin_requests_payer;
+
+DROP TABLE IF EXISTS coin_requests;
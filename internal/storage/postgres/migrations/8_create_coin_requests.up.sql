@@ -0,0 +1,17 @@
+-- Create coin requests table, one row per asked user
+CREATE TABLE IF NOT EXISTS coin_requests
+(
+    id          SERIAL PRIMARY KEY,
+    requester   VARCHAR(255) NOT NULL,
+    payer       VARCHAR(255) NOT NULL,
+    amount      INT          NOT NULL CHECK (amount > 0),
+    note        TEXT         NOT NULL DEFAULT '',
+    status      VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    created_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMP    NOT NULL,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_coin_requests_payer ON coin_requests (payer, status);
+CREATE INDEX IF NOT EXISTS idx_coin_requests_requester ON coin_requests (requester, status);
+CREATE INDEX IF NOT EXISTS idx_coin_requests_pending ON coin_requests (expires_at) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_pools_open;
+DROP INDEX IF EXISTS idx_pool_contributions_pool;
+
+DROP TABLE IF EXISTS pool_contributions;
+DROP TABLE IF EXISTS pools;
@@ -0,0 +1,27 @@
+-- Create pooled purchases table
+CREATE TABLE IF NOT EXISTS pools
+(
+    id         SERIAL PRIMARY KEY,
+    item       VARCHAR(255) NOT NULL,
+    recipient  VARCHAR(255) NOT NULL,
+    organizer  VARCHAR(255) NOT NULL,
+    target     INT          NOT NULL CHECK (target > 0),
+    collected  INT          NOT NULL DEFAULT 0 CHECK (collected >= 0 AND collected <= target),
+    status     VARCHAR(16)  NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP    NOT NULL,
+    closed_at  TIMESTAMP
+);
+
+-- Create pool contributions table, the coins are held in escrow while the pool is open
+CREATE TABLE IF NOT EXISTS pool_contributions
+(
+    id         SERIAL PRIMARY KEY,
+    pool_id    INT          NOT NULL REFERENCES pools (id) ON DELETE CASCADE,
+    username   VARCHAR(255) NOT NULL,
+    amount     INT          NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pool_contributions_pool ON pool_contributions (pool_id);
+CREATE INDEX IF NOT EXISTS idx_pools_open ON pools (expires_at) WHERE status = 'open';
@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type NotificationStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateNotificationStoragePostgres(postgresConnect string) (*NotificationStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationStoragePostgres{pool}, nil
+}
+
+func (st *NotificationStoragePostgres) GetPreferences(ctx context.Context,
+	username string) (*model.NotificationPreferences, error) {
+	query := `
+        SELECT inbox, email, email_address, webhook, webhook_url, webhook_secret
+        FROM notification_preferences
+        WHERE username = $1
+    `
+	ctx, end := track(ctx, "notifications.GetPreferences", query)
+	defer end()
+
+	prefs := model.NotificationPreferences{Username: username}
+	err := st.pool.QueryRow(ctx, query, username).Scan(&prefs.Inbox, &prefs.Email, &prefs.EmailAddress,
+		&prefs.Webhook, &prefs.WebhookURL, &prefs.WebhookSecret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &model.NotificationPreferences{Username: username, Inbox: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (st *NotificationStoragePostgres) SetPreferences(ctx context.Context, prefs model.NotificationPreferences) error {
+	query := `
+        INSERT INTO notification_preferences
+            (username, inbox, email, email_address, webhook, webhook_url, webhook_secret, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (username) DO UPDATE
+            SET inbox          = EXCLUDED.inbox,
+                email          = EXCLUDED.email,
+                email_address  = EXCLUDED.email_address,
+                webhook        = EXCLUDED.webhook,
+                webhook_url    = EXCLUDED.webhook_url,
+                webhook_secret = EXCLUDED.webhook_secret,
+                updated_at     = EXCLUDED.updated_at
+    `
+	ctx, end := track(ctx, "notifications.SetPreferences", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, prefs.Username, prefs.Inbox, prefs.Email, prefs.EmailAddress, prefs.Webhook,
+		prefs.WebhookURL, prefs.WebhookSecret, time.Now())
+	return err
+}
+
+func (st *NotificationStoragePostgres) CreateNotification(ctx context.Context, n model.Notification, inbox bool,
+	channels []string) (int, error) {
+	query := `
+        INSERT INTO notifications (username, kind, title, body, inbox, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	ctx, end := track(ctx, "notifications.CreateNotification", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	var id int
+	err = tx.QueryRow(ctx, query, n.Username, n.Kind, n.Title, n.Body, inbox, now).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	for _, channel := range channels {
+		_, err = tx.Exec(ctx, `
+            INSERT INTO notification_deliveries (notification_id, channel, next_attempt_at, updated_at)
+            VALUES ($1, $2, $3, $3)
+        `, id, channel, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit(ctx)
+}
+
+func (st *NotificationStoragePostgres) GetNotifications(ctx context.Context, username string, unreadOnly bool,
+	count int) ([]model.Notification, error) {
+	query := `
+        SELECT id, username, kind, title, body, created_at, read_at
+        FROM notifications
+        WHERE username = $1 AND inbox AND (read_at IS NULL OR NOT $2)
+        ORDER BY id DESC
+        LIMIT $3
+    `
+	ctx, end := track(ctx, "notifications.GetNotifications", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, username, unreadOnly, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Notification
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(&n.ID, &n.Username, &n.Kind, &n.Title, &n.Body, &n.CreatedAt, &n.ReadAt); err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, rows.Err()
+}
+
+func (st *NotificationStoragePostgres) MarkRead(ctx context.Context, username string, ids []int) (int, error) {
+	query := `
+        UPDATE notifications
+        SET read_at = $2
+        WHERE username = $1 AND inbox AND read_at IS NULL AND (cardinality($3::INT[]) = 0 OR id = ANY ($3))
+    `
+	ctx, end := track(ctx, "notifications.MarkRead", query)
+	defer end()
+
+	if ids == nil {
+		ids = []int{}
+	}
+	result, err := st.pool.Exec(ctx, query, username, time.Now(), ids)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (st *NotificationStoragePostgres) ClaimDeliveries(ctx context.Context, count int,
+	leaseUntil time.Time) ([]model.Delivery, error) {
+	query := `
+        UPDATE notification_deliveries d
+        SET next_attempt_at = $2, updated_at = $3
+        FROM (SELECT id
+              FROM notification_deliveries
+              WHERE status = $4 AND next_attempt_at <= $3
+              ORDER BY next_attempt_at
+              LIMIT $1 FOR UPDATE SKIP LOCKED) due,
+             notifications n
+        WHERE d.id = due.id AND n.id = d.notification_id
+        RETURNING d.id, d.channel, d.attempts, n.id, n.username, n.kind, n.title, n.body, n.created_at
+    `
+	ctx, end := track(ctx, "notifications.ClaimDeliveries", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, count, leaseUntil, time.Now(), model.DeliveryPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		n := &d.Notification
+		err := rows.Scan(&d.ID, &d.Channel, &d.Attempts, &n.ID, &n.Username, &n.Kind, &n.Title, &n.Body, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+func (st *NotificationStoragePostgres) CompleteDelivery(ctx context.Context, id int) error {
+	query := `
+        UPDATE notification_deliveries
+        SET status = $2, attempts = attempts + 1, last_error = '', updated_at = $3
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "notifications.CompleteDelivery", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, id, model.DeliverySent, time.Now())
+	return err
+}
+
+func (st *NotificationStoragePostgres) FailDelivery(ctx context.Context, id int, reason string,
+	retryAt *time.Time) error {
+	query := `
+        UPDATE notification_deliveries
+        SET status          = CASE WHEN $3::TIMESTAMP IS NULL THEN $4 ELSE status END,
+            next_attempt_at = COALESCE($3, next_attempt_at),
+            attempts        = attempts + 1,
+            last_error      = $2,
+            updated_at      = $5
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "notifications.FailDelivery", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, id, reason, retryAt, model.DeliveryFailed, time.Now())
+	return err
+}
+
+func (st *NotificationStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
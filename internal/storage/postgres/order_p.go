@@ -0,0 +1,323 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"time"
+)
+
+const orderColumns = `o.id, o.purchase_id, o.username, o.item, o.variant_id, COALESCE(v.size, ''), COALESCE(v.color, ''),
+        o.refund, o.status, COALESCE(o.handled_by, ''), o.created_at, o.updated_at, l.id, l.name, l.address, l.active`
+
+const orderSource = `orders o
+                 LEFT JOIN item_variants v ON v.id = o.variant_id
+                 LEFT JOIN pickup_locations l ON l.id = o.location_id`
+
+type OrderStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateOrderStoragePostgres(postgresConnect string) (*OrderStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderStoragePostgres{pool}, nil
+}
+
+func (st *OrderStoragePostgres) GetOrder(ctx context.Context, id int) (*model.Order, error) {
+	query := `
+        SELECT ` + orderColumns + `
+        FROM ` + orderSource + `
+        WHERE o.id = $1
+    `
+	ctx, end := track(ctx, "orders.GetOrder", query)
+	defer end()
+
+	order, err := scanOrder(st.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrOrderNotFound
+	}
+	return order, err
+}
+
+func (st *OrderStoragePostgres) GetOrders(ctx context.Context, username string) ([]model.Order, error) {
+	query := `
+        SELECT ` + orderColumns + `
+        FROM ` + orderSource + `
+        WHERE o.username = $1
+        ORDER BY o.id DESC
+    `
+	ctx, end := track(ctx, "orders.GetOrders", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+	return collectOrders(rows)
+}
+
+func (st *OrderStoragePostgres) GetQueue(ctx context.Context, statuses []string, locationID int) ([]model.Order, error) {
+	query := `
+        SELECT ` + orderColumns + `
+        FROM ` + orderSource + `
+        WHERE o.status = ANY ($1) AND ($2 = 0 OR o.location_id = $2)
+        ORDER BY o.created_at, o.id
+    `
+	ctx, end := track(ctx, "orders.GetQueue", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, statuses, locationID)
+	if err != nil {
+		return nil, err
+	}
+	return collectOrders(rows)
+}
+
+func (st *OrderStoragePostgres) SetLocation(ctx context.Context, id int, locationID int,
+	statuses []string) (*model.Order, error) {
+	query := `
+        UPDATE orders SET location_id = $2, updated_at = $3 WHERE id = $1
+    `
+	ctx, end := track(ctx, "orders.SetLocation", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(statuses, status) {
+		return nil, storage.ErrOrderStatus
+	}
+	var active bool
+	err = tx.QueryRow(ctx, `SELECT active FROM pickup_locations WHERE id = $1`, locationID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && !active {
+		return nil, storage.ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(ctx, query, id, locationID, time.Now()); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return st.GetOrder(ctx, id)
+}
+
+func (st *OrderStoragePostgres) SetStatus(ctx context.Context, id int, from []string, status string,
+	handledBy string) (*model.Order, error) {
+	query := `
+        UPDATE orders
+        SET status = $2, handled_by = NULLIF($3, ''), updated_at = $4
+        WHERE id = $1 AND status = ANY ($5)
+    `
+	ctx, end := track(ctx, "orders.SetStatus", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, id, status, handledBy, time.Now(), from)
+	if err != nil {
+		return nil, err
+	}
+	order, err := st.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, storage.ErrOrderStatus
+	}
+	return order, nil
+}
+
+func (st *OrderStoragePostgres) CancelOrder(ctx context.Context, id int, from []string,
+	handledBy string) (*model.Order, error) {
+	query := `
+        UPDATE orders
+        SET status = $2, handled_by = NULLIF($3, ''), updated_at = $4
+        WHERE id = $1
+        RETURNING username, item, variant_id, refund
+    `
+	ctx, end := track(ctx, "orders.CancelOrder", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(from, status) {
+		return nil, storage.ErrOrderStatus
+	}
+	var username, item string
+	var variantID, refund int
+	err = tx.QueryRow(ctx, query, id, model.OrderCancelled, handledBy, time.Now()).
+		Scan(&username, &item, &variantID, &refund)
+	if err != nil {
+		return nil, err
+	}
+	if refund > 0 {
+		_, err = tx.Exec(ctx, `
+            SELECT credit_coins((SELECT id FROM users WHERE username = $1), $2, $3)
+        `, username, refund, model.LotRefund)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(ctx, `
+        WITH removed AS (
+            DELETE FROM inventory
+            WHERE user_id = (SELECT id FROM users WHERE username = $1) AND item_name = $2 AND variant_id = $3
+              AND quantity = 1
+        )
+        UPDATE inventory
+        SET quantity = quantity - 1
+        WHERE user_id = (SELECT id FROM users WHERE username = $1) AND item_name = $2 AND variant_id = $3
+          AND quantity > 1
+    `, username, item, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if variantID != 0 {
+		_, err = tx.Exec(ctx, `UPDATE item_variants SET stock = stock + 1 WHERE id = $1`, variantID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return st.GetOrder(ctx, id)
+}
+
+func (st *OrderStoragePostgres) CreateLocation(ctx context.Context, name string,
+	address string) (*model.PickupLocation, error) {
+	query := `
+        INSERT INTO pickup_locations (name, address, created_at)
+        VALUES ($1, $2, $3)
+        RETURNING id, name, address, active
+    `
+	ctx, end := track(ctx, "orders.CreateLocation", query)
+	defer end()
+
+	location, err := scanLocation(st.pool.QueryRow(ctx, query, name, address, time.Now()))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return nil, storage.ErrLocationExists
+	}
+	return location, err
+}
+
+func (st *OrderStoragePostgres) GetLocations(ctx context.Context, activeOnly bool) ([]model.PickupLocation, error) {
+	query := `
+        SELECT id, name, address, active
+        FROM pickup_locations
+        WHERE active OR NOT $1
+        ORDER BY name
+    `
+	ctx, end := track(ctx, "orders.GetLocations", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.PickupLocation
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *location)
+	}
+	return res, rows.Err()
+}
+
+func (st *OrderStoragePostgres) DisableLocation(ctx context.Context, id int) error {
+	query := `
+        UPDATE pickup_locations SET active = FALSE WHERE id = $1
+    `
+	ctx, end := track(ctx, "orders.DisableLocation", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrLocationNotFound
+	}
+	return nil
+}
+
+func (st *OrderStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func lockOrder(ctx context.Context, tx pgx.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow(ctx, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", storage.ErrOrderNotFound
+	}
+	return status, err
+}
+
+func collectOrders(rows pgx.Rows) ([]model.Order, error) {
+	defer rows.Close()
+
+	var res []model.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *order)
+	}
+	return res, rows.Err()
+}
+
+func scanOrder(row pgx.Row) (*model.Order, error) {
+	var o model.Order
+	var locationID *int
+	var name, address *string
+	var active *bool
+	err := row.Scan(&o.ID, &o.PurchaseID, &o.Username, &o.Item, &o.VariantID, &o.Size, &o.Color, &o.Refund,
+		&o.Status, &o.HandledBy, &o.CreatedAt, &o.UpdatedAt, &locationID, &name, &address, &active)
+	if err != nil {
+		return nil, err
+	}
+	if locationID != nil {
+		o.Location = &model.PickupLocation{ID: *locationID, Name: *name, Address: *address, Active: *active}
+	}
+	return &o, nil
+}
+
+func scanLocation(row pgx.Row) (*model.PickupLocation, error) {
+	var l model.PickupLocation
+	if err := row.Scan(&l.ID, &l.Name, &l.Address, &l.Active); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
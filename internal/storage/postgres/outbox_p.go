@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"avito-merch-store/model"
+	"cmp"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"time"
+)
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// insertEvent writes a domain event to the outbox, it is called inside the
+// transaction of the change the event describes.
+func insertEvent(ctx context.Context, q execer, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+        INSERT INTO outbox (type, payload, created_at) VALUES ($1, $2, $3)
+    `, eventType, data, time.Now())
+	return err
+}
+
+type OutboxStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateOutboxStoragePostgres(postgresConnect string) (*OutboxStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxStoragePostgres{pool}, nil
+}
+
+func (st *OutboxStoragePostgres) ClaimEvents(ctx context.Context, count int, leaseUntil time.Time) ([]model.Event, error) {
+	query := `
+        UPDATE outbox o
+        SET next_attempt_at = $2
+        FROM (SELECT seq
+              FROM outbox
+              WHERE published_at IS NULL AND next_attempt_at <= $3
+              ORDER BY seq
+              LIMIT $1 FOR UPDATE SKIP LOCKED) due
+        WHERE o.seq = due.seq
+        RETURNING o.seq, o.id::TEXT, o.type, o.payload, o.created_at, o.attempts
+    `
+	ctx, end := track(ctx, "outbox.ClaimEvents", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, count, leaseUntil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Event
+	for rows.Next() {
+		var e model.Event
+		if err := rows.Scan(&e.Sequence, &e.ID, &e.Type, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(res, func(a, b model.Event) int {
+		return cmp.Compare(a.Sequence, b.Sequence)
+	})
+	return res, nil
+}
+
+func (st *OutboxStoragePostgres) MarkPublished(ctx context.Context, seqs []int64) error {
+	query := `
+        UPDATE outbox SET published_at = $2, attempts = attempts + 1, last_error = '' WHERE seq = ANY ($1)
+    `
+	ctx, end := track(ctx, "outbox.MarkPublished", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, seqs, time.Now())
+	return err
+}
+
+func (st *OutboxStoragePostgres) FailEvents(ctx context.Context, seqs []int64, reason string, retryAt time.Time) error {
+	query := `
+        UPDATE outbox
+        SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+        WHERE seq = ANY ($1)
+    `
+	ctx, end := track(ctx, "outbox.FailEvents", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, seqs, reason, retryAt)
+	return err
+}
+
+func (st *OutboxStoragePostgres) DeletePublished(ctx context.Context, before time.Time) (int, error) {
+	query := `
+        DELETE FROM outbox WHERE published_at < $1
+    `
+	ctx, end := track(ctx, "outbox.DeletePublished", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (st *OutboxStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
@@ -0,0 +1,358 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+const poolColumns = `id, item, recipient, organizer, target, collected, status, created_at, expires_at, closed_at`
+
+type PoolStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreatePoolStoragePostgres(postgresConnect string) (*PoolStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &PoolStoragePostgres{pool}, nil
+}
+
+func (st *PoolStoragePostgres) CreatePool(ctx context.Context, organizer string, item string, recipient string,
+	expiresAt time.Time) (int, error) {
+	query := `
+        INSERT INTO pools (item, recipient, organizer, target, created_at, expires_at)
+        SELECT name, $2, $3, price, $4, $5
+        FROM merch
+        WHERE name = $1
+        RETURNING id
+    `
+	ctx, end := track(ctx, "pools.CreatePool", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)`, recipient).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, storage.ErrUserNotFound
+	}
+	// variant stock is taken by the buyer's choice, a pool has nobody to choose
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM item_variants WHERE item_name = $1)`, item).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, storage.ErrPoolVariantItem
+	}
+
+	var id int
+	err = tx.QueryRow(ctx, query, item, recipient, organizer, time.Now(), expiresAt).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, storage.ErrMerchNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+func (st *PoolStoragePostgres) GetPool(ctx context.Context, id int) (*model.Pool, error) {
+	query := `
+        SELECT ` + poolColumns + `
+        FROM pools
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "pools.GetPool", query)
+	defer end()
+
+	pool, err := scanPool(st.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrPoolNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	pool.Contributions, err = getPoolContributions(ctx, st.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+func (st *PoolStoragePostgres) GetOpenPools(ctx context.Context, count int) ([]model.Pool, error) {
+	query := `
+        SELECT ` + poolColumns + `
+        FROM pools
+        WHERE status = $1 AND expires_at > $2
+        ORDER BY expires_at
+        LIMIT $3
+    `
+	ctx, end := track(ctx, "pools.GetOpenPools", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, model.PoolOpen, time.Now(), count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Pool
+	for rows.Next() {
+		pool, err := scanPool(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *pool)
+	}
+	return res, rows.Err()
+}
+
+func (st *PoolStoragePostgres) Contribute(ctx context.Context, id int, username string, amount int) (*model.Pool, error) {
+	query := `
+        UPDATE pools
+        SET collected = collected + $2
+        WHERE id = $1
+        RETURNING collected, target
+    `
+	ctx, end := track(ctx, "pools.Contribute", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	pool, err := lockOpenPool(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if amount > pool.Target-pool.Collected {
+		return nil, storage.ErrPoolOverfunded
+	}
+
+	_, err = tx.Exec(ctx, `SELECT debit_coins((SELECT id FROM users WHERE username = $1), $2)`, username, amount)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+		return nil, storage.ErrNotEnoughCoins
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO pool_contributions (pool_id, username, amount, created_at) VALUES ($1, $2, $3, $4)
+    `, id, username, amount, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var collected, target int
+	if err = tx.QueryRow(ctx, query, id, amount).Scan(&collected, &target); err != nil {
+		return nil, err
+	}
+	if collected == target {
+		_, err = tx.Exec(ctx, `
+            INSERT INTO inventory (user_id, item_name, quantity)
+            SELECT id, $2, 1 FROM users WHERE username = $1
+            ON CONFLICT (user_id, item_name, variant_id)
+            DO UPDATE SET quantity = inventory.quantity + EXCLUDED.quantity
+        `, pool.Recipient, pool.Item)
+		if err != nil {
+			return nil, err
+		}
+		var purchaseID int
+		err = tx.QueryRow(ctx, `
+            WITH purchase AS (
+                INSERT INTO purchases (username, item, price, original_price, created_at)
+                VALUES ($1, $2, $3, $3, $4)
+                RETURNING id
+            )
+            INSERT INTO orders (purchase_id, username, item, status, created_at, updated_at)
+            SELECT id, $1, $2, $5, $4, $4 FROM purchase
+            RETURNING purchase_id
+        `, pool.Recipient, pool.Item, pool.Target, time.Now(), model.OrderPlaced).Scan(&purchaseID)
+		if err != nil {
+			return nil, err
+		}
+		err = insertEvent(ctx, tx, model.EventItemPurchased, model.ItemPurchased{
+			PurchaseID:    purchaseID,
+			Username:      pool.Recipient,
+			Item:          pool.Item,
+			Price:         pool.Target,
+			OriginalPrice: pool.Target,
+			Pooled:        true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+            INSERT INTO transactions (sender_username, receiver_username, amount, created_at, reason)
+            SELECT username, $2, SUM(amount), $3, $4
+            FROM pool_contributions
+            WHERE pool_id = $1
+            GROUP BY username
+        `, id, model.SystemUser, time.Now(), fmt.Sprintf("pool %d: %s for %s", id, pool.Item, pool.Recipient))
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+            UPDATE pools SET status = $2, closed_at = $3 WHERE id = $1
+        `, id, model.PoolCompleted, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return st.GetPool(ctx, id)
+}
+
+func (st *PoolStoragePostgres) ClosePool(ctx context.Context, id int, status string) (*model.Pool, error) {
+	query := `
+        SELECT credit_coins((SELECT id FROM users WHERE username = $1), $2, $3)
+    `
+	ctx, end := track(ctx, "pools.ClosePool", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// expired pools are still open in the table until the background job closes them
+	pool, err := lockPool(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Status != model.PoolOpen {
+		return nil, storage.ErrPoolNotOpen
+	}
+	contributions, err := getPoolContributions(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range contributions {
+		if _, err = tx.Exec(ctx, query, c.Username, c.Amount, model.LotRefund); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(ctx, `
+        UPDATE pools SET status = $2, closed_at = $3 WHERE id = $1
+    `, id, status, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return st.GetPool(ctx, id)
+}
+
+func (st *PoolStoragePostgres) GetExpiredPoolIDs(ctx context.Context, count int) ([]int, error) {
+	query := `
+        SELECT id
+        FROM pools
+        WHERE status = $1 AND expires_at <= $2
+        ORDER BY expires_at
+        LIMIT $3
+    `
+	ctx, end := track(ctx, "pools.GetExpiredPoolIDs", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, model.PoolOpen, time.Now(), count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (st *PoolStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func lockPool(ctx context.Context, tx pgx.Tx, id int) (*model.Pool, error) {
+	pool, err := scanPool(tx.QueryRow(ctx, `SELECT `+poolColumns+` FROM pools WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrPoolNotFound
+	}
+	return pool, err
+}
+
+func lockOpenPool(ctx context.Context, tx pgx.Tx, id int) (*model.Pool, error) {
+	pool, err := lockPool(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	// compared in the database, timestamps are stored without time zone
+	var active bool
+	err = tx.QueryRow(ctx, `SELECT expires_at > $2 FROM pools WHERE id = $1`, id, time.Now()).Scan(&active)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Status != model.PoolOpen || !active {
+		return nil, storage.ErrPoolNotOpen
+	}
+	return pool, nil
+}
+
+func getPoolContributions(ctx context.Context, q querier, poolID int) ([]model.PoolContribution, error) {
+	rows, err := q.Query(ctx, `
+        SELECT username, amount, created_at
+        FROM pool_contributions
+        WHERE pool_id = $1
+        ORDER BY id
+    `, poolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.PoolContribution
+	for rows.Next() {
+		var c model.PoolContribution
+		if err := rows.Scan(&c.Username, &c.Amount, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func scanPool(row pgx.Row) (*model.Pool, error) {
+	var p model.Pool
+	err := row.Scan(&p.ID, &p.Item, &p.Recipient, &p.Organizer, &p.Target, &p.Collected, &p.Status,
+		&p.CreatedAt, &p.ExpiresAt, &p.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
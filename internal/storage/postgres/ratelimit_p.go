@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/ratelimit"
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// RateLimitStoragePostgres keeps token buckets in the database
+// so that every application instance shares the same limits.
+type RateLimitStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateRateLimitStoragePostgres(postgresConnect string) (*RateLimitStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimitStoragePostgres{pool}, nil
+}
+
+func (st *RateLimitStoragePostgres) Allow(ctx context.Context, key string, policy ratelimit.Policy) (bool, time.Duration, error) {
+	query := `
+        INSERT INTO rate_limits (key, tokens, updated_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+        RETURNING tokens, EXTRACT(EPOCH FROM NOW() - updated_at)
+    `
+	ctx, end := track(ctx, "rate_limits.Allow", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the no-op update locks the row until the transaction ends
+	var tokens, elapsed float64
+	err = tx.QueryRow(ctx, query, key, policy.Burst).Scan(&tokens, &elapsed)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, allowed, wait := ratelimit.Take(tokens, time.Duration(elapsed*float64(time.Second)), policy)
+	_, err = tx.Exec(ctx, `
+        UPDATE rate_limits SET tokens = $2, updated_at = NOW() WHERE key = $1
+    `, key, tokens)
+	if err != nil {
+		return false, 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return false, 0, err
+	}
+	return allowed, wait, nil
+}
+
+func (st *RateLimitStoragePostgres) DeleteIdle(ctx context.Context, idle time.Duration) (int, error) {
+	query := `
+        DELETE FROM rate_limits
+        WHERE updated_at < NOW() - $1 * INTERVAL '1 second'
+    `
+	ctx, end := track(ctx, "rate_limits.DeleteIdle", query)
+	defer end()
+
+	tag, err := st.pool.Exec(ctx, query, idle.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (st *RateLimitStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// nextRunSQL moves next_run_at one period forward.
+const nextRunSQL = `next_run_at + CASE period
+            WHEN 'daily' THEN INTERVAL '1 day'
+            WHEN 'weekly' THEN INTERVAL '7 days'
+            ELSE INTERVAL '1 month' END`
+
+type ScheduleStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateScheduleStoragePostgres(postgresConnect string) (*ScheduleStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduleStoragePostgres{pool}, nil
+}
+
+func (st *ScheduleStoragePostgres) CreateSchedule(ctx context.Context, s model.Schedule) (int, error) {
+	query := `
+        INSERT INTO allowance_schedules (name, amount, max_balance, period, status, next_run_at, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id
+    `
+	ctx, end := track(ctx, "schedules.CreateSchedule", query)
+	defer end()
+
+	var id int
+	err := st.pool.QueryRow(ctx, query, s.Name, s.Amount, s.MaxBalance, s.Period, s.Status,
+		s.NextRunAt, s.CreatedBy).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (st *ScheduleStoragePostgres) GetSchedule(ctx context.Context, id int) (*model.Schedule, error) {
+	query := `
+        SELECT id, name, amount, max_balance, period, status, next_run_at, created_by, created_at
+        FROM allowance_schedules
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "schedules.GetSchedule", query)
+	defer end()
+
+	s, err := scanSchedule(st.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (st *ScheduleStoragePostgres) GetSchedules(ctx context.Context) ([]model.Schedule, error) {
+	query := `
+        SELECT id, name, amount, max_balance, period, status, next_run_at, created_by, created_at
+        FROM allowance_schedules
+        ORDER BY id
+    `
+	ctx, end := track(ctx, "schedules.GetSchedules", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *s)
+	}
+	return res, rows.Err()
+}
+
+func (st *ScheduleStoragePostgres) UpdateStatus(ctx context.Context, id int, status string, nextRunAt time.Time) error {
+	query := `
+        UPDATE allowance_schedules
+        SET status = $2, next_run_at = $3
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "schedules.UpdateStatus", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, id, status, nextRunAt)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (st *ScheduleStoragePostgres) GetRuns(ctx context.Context, scheduleID int, count int) ([]model.ScheduleRun, error) {
+	query := `
+        SELECT id, schedule_id, period_start, status, users_credited, coins_total, COALESCE(error, ''), finished_at
+        FROM allowance_runs
+        WHERE schedule_id = $1
+        ORDER BY id DESC
+    `
+	ctx, end := track(ctx, "schedules.GetRuns", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []model.ScheduleRun
+	for i := 0; rows.Next() && (i < count || count == -1); i++ {
+		var r model.ScheduleRun
+		err := rows.Scan(&r.ID, &r.ScheduleID, &r.PeriodStart, &r.Status, &r.UsersCredited,
+			&r.CoinsTotal, &r.Error, &r.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (st *ScheduleStoragePostgres) RunDue(ctx context.Context, now time.Time) (*model.ScheduleRun, error) {
+	query := `
+        SELECT id, name, amount, max_balance, period, status, next_run_at, created_by, created_at
+        FROM allowance_schedules
+        WHERE status = 'active' AND next_run_at <= $1
+        ORDER BY next_run_at
+        LIMIT 1
+        FOR UPDATE SKIP LOCKED
+    `
+	ctx, end := track(ctx, "schedules.RunDue", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := scanSchedule(tx.QueryRow(ctx, query, now))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	run := &model.ScheduleRun{ScheduleID: s.ID, PeriodStart: s.NextRunAt, Status: model.RunCompleted, FinishedAt: now}
+	err = tx.QueryRow(ctx, `
+        WITH amounts AS (
+            SELECT id, username,
+                   CASE WHEN $2::INT IS NULL THEN $1 ELSE LEAST($1, $2::INT - coins) END AS amount
+            FROM users
+            WHERE username <> $3
+            FOR UPDATE
+        ), credited AS (
+            SELECT username, amount, credit_coins(id, amount, $6)
+            FROM amounts
+            WHERE amount > 0
+        ), history AS (
+            INSERT INTO transactions (sender_username, receiver_username, amount, created_at, reason)
+            SELECT $3, username, amount, $4, $5 FROM credited
+        )
+        SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM credited
+    `, s.Amount, s.MaxBalance, model.SystemUser, now, "allowance: "+s.Name,
+		model.LotAllowance).Scan(&run.UsersCredited, &run.CoinsTotal)
+	if err != nil {
+		return run, err
+	}
+
+	err = tx.QueryRow(ctx, `
+        INSERT INTO allowance_runs (schedule_id, period_start, status, users_credited, coins_total, finished_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `, run.ScheduleID, run.PeriodStart, run.Status, run.UsersCredited, run.CoinsTotal, run.FinishedAt).Scan(&run.ID)
+	if err != nil {
+		return run, err
+	}
+	_, err = tx.Exec(ctx, `
+        UPDATE allowance_schedules SET next_run_at = `+nextRunSQL+` WHERE id = $1
+    `, s.ID)
+	if err != nil {
+		return run, err
+	}
+	return run, tx.Commit(ctx)
+}
+
+func (st *ScheduleStoragePostgres) SaveFailedRun(ctx context.Context, run model.ScheduleRun) error {
+	query := `
+        INSERT INTO allowance_runs (schedule_id, period_start, status, error, finished_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	ctx, end := track(ctx, "schedules.SaveFailedRun", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, run.ScheduleID, run.PeriodStart, model.RunFailed, run.Error, run.FinishedAt)
+	return err
+}
+
+func (st *ScheduleStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func scanSchedule(row pgx.Row) (*model.Schedule, error) {
+	var s model.Schedule
+	err := row.Scan(&s.ID, &s.Name, &s.Amount, &s.MaxBalance, &s.Period, &s.Status,
+		&s.NextRunAt, &s.CreatedBy, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type SSOStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateSSOStoragePostgres(postgresConnect string) (*SSOStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &SSOStoragePostgres{pool}, nil
+}
+
+func (st *SSOStoragePostgres) CreateLogin(ctx context.Context, login model.SSOLogin) error {
+	query := `
+        WITH expired AS (
+            DELETE FROM sso_logins WHERE expires_at < $5
+        )
+        INSERT INTO sso_logins (state, nonce, verifier, expires_at)
+        VALUES ($1, $2, $3, $4)
+    `
+	ctx, end := track(ctx, "sso.CreateLogin", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, login.State, login.Nonce, login.Verifier, login.ExpiresAt, time.Now())
+	return err
+}
+
+func (st *SSOStoragePostgres) TakeLogin(ctx context.Context, state string, now time.Time) (*model.SSOLogin, error) {
+	query := `
+        DELETE FROM sso_logins
+        WHERE state = $1
+        RETURNING state, nonce, verifier, expires_at
+    `
+	ctx, end := track(ctx, "sso.TakeLogin", query)
+	defer end()
+
+	var login model.SSOLogin
+	err := st.pool.QueryRow(ctx, query, state).Scan(&login.State, &login.Nonce, &login.Verifier, &login.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && login.ExpiresAt.Before(now) {
+		return nil, storage.ErrSSOLoginNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+func (st *SSOStoragePostgres) GetIdentity(ctx context.Context, issuer string, subject string) (*model.Identity, error) {
+	query := `
+        SELECT issuer, subject, username, email, created_at, last_login_at
+        FROM identities
+        WHERE issuer = $1 AND subject = $2
+    `
+	ctx, end := track(ctx, "sso.GetIdentity", query)
+	defer end()
+
+	var identity model.Identity
+	err := st.pool.QueryRow(ctx, query, issuer, subject).Scan(&identity.Issuer, &identity.Subject,
+		&identity.Username, &identity.Email, &identity.CreatedAt, &identity.LastLoginAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (st *SSOStoragePostgres) CreateIdentity(ctx context.Context, identity model.Identity) error {
+	query := `
+        INSERT INTO identities (issuer, subject, username, email, created_at, last_login_at)
+        VALUES ($1, $2, $3, $4, $5, $5)
+    `
+	ctx, end := track(ctx, "sso.CreateIdentity", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, identity.Issuer, identity.Subject, identity.Username, identity.Email,
+		time.Now())
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return storage.ErrIdentityExists
+	}
+	return err
+}
+
+func (st *SSOStoragePostgres) TouchIdentity(ctx context.Context, issuer string, subject string, email string,
+	at time.Time) error {
+	query := `
+        UPDATE identities
+        SET last_login_at = $4,
+            email         = CASE WHEN $3 = '' THEN email ELSE $3 END
+        WHERE issuer = $1 AND subject = $2
+    `
+	ctx, end := track(ctx, "sso.TouchIdentity", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, issuer, subject, email, at)
+	return err
+}
+
+func (st *SSOStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
@@ -1,48 +1,88 @@
 package postgres
 
 import (
+	"avito-merch-store/internal/storage"
 	"avito-merch-store/model"
 	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"time"
 )
 
+const transactionColumns = `id, sender_username, receiver_username, amount, created_at,
+               COALESCE(reason, ''), status, expires_at`
+
 type TransactionStoragePostgres struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func CreateTransactionStoragePostgres(postgresConnect string) (*TransactionStoragePostgres, error) {
-	conn, err := pgx.Connect(context.Background(), postgresConnect)
+	pool, err := connect(postgresConnect)
 	if err != nil {
 		return nil, err
 	}
-	return &TransactionStoragePostgres{conn}, nil
+	return &TransactionStoragePostgres{pool}, nil
 }
 
-func (st *TransactionStoragePostgres) CreateTransaction(senderName string, receiverName string, amount int) error {
-	ctx := context.Background()
-
-	var err error
+func (st *TransactionStoragePostgres) Transfer(ctx context.Context, senderName string, receiverName string,
+	amount int, caps storage.TransferCaps) (*model.Transaction, error) {
 	query := `
         INSERT INTO transactions (sender_username, receiver_username, amount, created_at)
         VALUES ($1, $2, $3, $4)
-    `
-	_, err = st.conn.Exec(ctx, query, senderName, receiverName, amount, time.Now())
+        RETURNING ` + transactionColumns
+	ctx, end := track(ctx, "transactions.Transfer", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer tx.Rollback(ctx)
+
+	err = checkTransferCaps(ctx, tx, senderName, receiverName, amount, caps)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `SELECT debit_coins((SELECT id FROM users WHERE username = $1), $2)`, senderName, amount)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+		return nil, storage.ErrNotEnoughCoins
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+        SELECT credit_coins((SELECT id FROM users WHERE username = $1), $2, $3)
+    `, receiverName, amount, model.LotTransfer)
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanTransaction(tx.QueryRow(ctx, query, senderName, receiverName, amount, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	err = insertEvent(ctx, tx, model.EventCoinsTransferred, model.CoinsTransferred{
+		TransferID: t.ID, From: senderName, To: receiverName, Amount: amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, tx.Commit(ctx)
 }
 
-func (st *TransactionStoragePostgres) GetTransactionHistory(username string, count int) ([]model.Transaction, error) {
-	ctx := context.Background()
+func (st *TransactionStoragePostgres) GetTransactionHistory(ctx context.Context, username string, count int) ([]model.Transaction, error) {
 	query := `
-        SELECT id, sender_username, receiver_username, amount, created_at
+        SELECT ` + transactionColumns + `
         FROM transactions
         WHERE sender_username = $1 OR receiver_username = $1
         ORDER BY created_at DESC
     `
-	rows, err := st.conn.Query(ctx, query, username)
+	ctx, end := track(ctx, "transactions.GetTransactionHistory", query)
+	defer end()
+	rows, err := st.pool.Query(ctx, query, username)
 	if err != nil {
 		return nil, err
 	}
@@ -50,12 +90,237 @@ func (st *TransactionStoragePostgres) GetTransactionHistory(username string, cou
 
 	var transactions []model.Transaction
 	for i := 0; rows.Next() && (i < count || count == -1); i++ {
-		var t model.Transaction
-		err := rows.Scan(&t.ID, &t.SenderName, &t.ReceiverName, &t.Amount, &t.CreatedAt)
+		t, err := scanTransaction(rows)
 		if err != nil {
 			return nil, err
 		}
-		transactions = append(transactions, t)
+		transactions = append(transactions, *t)
 	}
 	return transactions, nil
 }
+
+func (st *TransactionStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
+
+func (st *TransactionStoragePostgres) CreatePendingTransaction(ctx context.Context, senderName string,
+	receiverName string, amount int, expiresAt time.Time, caps storage.TransferCaps) (*model.Transaction, error) {
+	query := `
+        INSERT INTO transactions (sender_username, receiver_username, amount, created_at, status, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING ` + transactionColumns
+	ctx, end := track(ctx, "transactions.CreatePendingTransaction", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = checkTransferCaps(ctx, tx, senderName, receiverName, amount, caps)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `SELECT debit_coins((SELECT id FROM users WHERE username = $1), $2)`, senderName, amount)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+		return nil, storage.ErrNotEnoughCoins
+	}
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanTransaction(tx.QueryRow(ctx, query, senderName, receiverName, amount, time.Now(),
+		model.TransactionPending, expiresAt))
+	if err != nil {
+		return nil, err
+	}
+	err = insertEvent(ctx, tx, model.EventCoinsReserved, model.CoinsReserved{
+		TransferID: t.ID, From: senderName, To: receiverName, Amount: amount, ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, tx.Commit(ctx)
+}
+
+func (st *TransactionStoragePostgres) GetTransaction(ctx context.Context, id int) (*model.Transaction, error) {
+	query := `
+        SELECT ` + transactionColumns + `
+        FROM transactions
+        WHERE id = $1
+    `
+	ctx, end := track(ctx, "transactions.GetTransaction", query)
+	defer end()
+
+	t, err := scanTransaction(st.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrTransactionNotFound
+	}
+	return t, err
+}
+
+func (st *TransactionStoragePostgres) GetPendingTransactions(ctx context.Context, username string) ([]model.Transaction, error) {
+	query := `
+        SELECT ` + transactionColumns + `
+        FROM transactions
+        WHERE (sender_username = $1 OR receiver_username = $1) AND status = $2
+        ORDER BY created_at DESC
+    `
+	ctx, end := track(ctx, "transactions.GetPendingTransactions", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, username, model.TransactionPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []model.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, rows.Err()
+}
+
+func (st *TransactionStoragePostgres) ResolvePendingTransaction(ctx context.Context, id int, status string) (*model.Transaction, error) {
+	query := `
+        SELECT ` + transactionColumns + `
+        FROM transactions
+        WHERE id = $1
+        FOR UPDATE
+    `
+	ctx, end := track(ctx, "transactions.ResolvePendingTransaction", query)
+	defer end()
+
+	tx, err := st.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := scanTransaction(tx.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != model.TransactionPending {
+		return nil, storage.ErrTransactionNotPending
+	}
+	// the reverter may not have reached it yet, the coins go back to the sender anyway
+	if status == model.TransactionCompleted && t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrTransactionExpired
+	}
+
+	owner, source := t.ReceiverName, model.LotTransfer
+	if status != model.TransactionCompleted {
+		owner, source = t.SenderName, model.LotRefund
+	}
+	_, err = tx.Exec(ctx, `
+        SELECT credit_coins((SELECT id FROM users WHERE username = $1), $2, $3)
+    `, owner, t.Amount, source)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+        UPDATE transactions SET status = $2, resolved_at = $3 WHERE id = $1
+    `, id, status, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if status == model.TransactionCompleted {
+		err = insertEvent(ctx, tx, model.EventCoinsTransferred, model.CoinsTransferred{
+			TransferID: id, From: t.SenderName, To: t.ReceiverName, Amount: t.Amount,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	t.Status = status
+	return t, nil
+}
+
+func (st *TransactionStoragePostgres) GetExpiredPendingIDs(ctx context.Context, count int) ([]int, error) {
+	query := `
+        SELECT id
+        FROM transactions
+        WHERE status = $1 AND expires_at <= $2
+        ORDER BY expires_at
+        LIMIT $3
+    `
+	ctx, end := track(ctx, "transactions.GetExpiredPendingIDs", query)
+	defer end()
+
+	rows, err := st.pool.Query(ctx, query, model.TransactionPending, time.Now(), count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanTransaction(row pgx.Row) (*model.Transaction, error) {
+	var t model.Transaction
+	err := row.Scan(&t.ID, &t.SenderName, &t.ReceiverName, &t.Amount, &t.CreatedAt, &t.Reason, &t.Status, &t.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// checkTransferCaps fails when sending amount from sender to receiver within tx would
+// break caps. The sender row stays locked until tx ends, so concurrent transfers of the
+// same sender are counted one after another.
+func checkTransferCaps(ctx context.Context, tx pgx.Tx, sender string, receiver string, amount int,
+	caps storage.TransferCaps) error {
+	if caps == (storage.TransferCaps{}) {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `SELECT id FROM users WHERE username = $1 FOR UPDATE`, sender)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var daily, weekly, toReceiver int
+	err = tx.QueryRow(ctx, `
+        SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0),
+               COALESCE(SUM(amount), 0),
+               COUNT(*) FILTER (WHERE created_at >= $3 AND receiver_username = $2)
+        FROM transactions
+        WHERE sender_username = $1 AND receiver_username <> $5 AND created_at >= $4
+          AND status IN ('completed', 'pending')
+    `, sender, receiver, now.Add(-24*time.Hour), now.Add(-7*24*time.Hour), model.SystemUser).
+		Scan(&daily, &weekly, &toReceiver)
+	if err != nil {
+		return err
+	}
+	if caps.Daily > 0 && daily+amount > caps.Daily {
+		return storage.ErrDailyLimitExceeded
+	}
+	if caps.Weekly > 0 && weekly+amount > caps.Weekly {
+		return storage.ErrWeeklyLimitExceeded
+	}
+	if caps.PerRecipientDaily > 0 && toReceiver >= caps.PerRecipientDaily {
+		return storage.ErrRecipientLimitExceeded
+	}
+	return nil
+}
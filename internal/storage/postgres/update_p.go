@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// updatesChannel is the LISTEN/NOTIFY channel of live updates. The users_balance_update
+// trigger publishes a balance update to it on every change of a balance.
+const updatesChannel = "user_updates"
+
+type UpdateStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateUpdateStoragePostgres(postgresConnect string) (*UpdateStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateStoragePostgres{pool}, nil
+}
+
+type updateMessage struct {
+	Username string       `json:"username"`
+	Update   model.Update `json:"update"`
+}
+
+func (st *UpdateStoragePostgres) PublishUpdate(ctx context.Context, username string, update model.Update) error {
+	query := `SELECT pg_notify($1, $2)`
+	ctx, end := track(ctx, "updates.PublishUpdate", query)
+	defer end()
+
+	payload, err := json.Marshal(updateMessage{username, update})
+	if err != nil {
+		return err
+	}
+	_, err = st.pool.Exec(ctx, query, updatesChannel, string(payload))
+	return err
+}
+
+func (st *UpdateStoragePostgres) Listen(ctx context.Context, handle func(username string, update model.Update)) error {
+	// LISTEN needs one connection for the whole wait, it is held until Listen returns
+	conn, err := st.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	// a connection going back to the pool must not keep receiving notifications
+	defer conn.Exec(context.Background(), "UNLISTEN "+updatesChannel)
+	if _, err := conn.Exec(ctx, "LISTEN "+updatesChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var msg updateMessage
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			continue
+		}
+		handle(msg.Username, msg.Update)
+	}
+}
+
+func (st *UpdateStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
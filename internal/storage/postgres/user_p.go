@@ -8,44 +8,66 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/pgx" // Драйвер для database/sql
 	_ "github.com/golang-migrate/migrate/v4/source/file"  // Источник миграций из файлов
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib" // Адаптер pgx для database/sql
 )
 
 type UserStoragePostgres struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 }
 
 func CreateUserStoragePostgres(postgresConnect string) (*UserStoragePostgres, error) {
-	conn, err := pgx.Connect(context.Background(), postgresConnect)
+	pool, err := connect(postgresConnect)
 	if err != nil {
 		return nil, err
 	}
-	return &UserStoragePostgres{conn}, nil
+	return &UserStoragePostgres{pool}, nil
 }
 
-func (st *UserStoragePostgres) Create(username string, coins int) error {
+func (st *UserStoragePostgres) Create(ctx context.Context, username string, coins int) error {
 	query := `
         INSERT INTO users (username, coins)
-        VALUES ($1, $2)
+        VALUES ($1, 0)
+        RETURNING id
     `
+	ctx, end := track(ctx, "users.Create", query)
+	defer end()
 
-	_, err := st.conn.Exec(context.Background(), query, username, coins)
+	tx, err := st.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	return nil
+	var id int
+	err = tx.QueryRow(ctx, query, username).Scan(&id)
+	if err != nil {
+		return err
+	}
+	if coins > 0 {
+		_, err = tx.Exec(ctx, `SELECT credit_coins($1, $2, $3)`, id, coins, model.LotInitial)
+		if err != nil {
+			return err
+		}
+	}
+	err = insertEvent(ctx, tx, model.EventUserRegistered, model.UserRegistered{Username: username, Coins: coins})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-func (st *UserStoragePostgres) GetByUsername(username string) (*model.User, error) {
+func (st *UserStoragePostgres) GetByUsername(ctx context.Context, username string) (*model.User, error) {
 	query := `
-        SELECT id, username, coins 
+        SELECT id, username, coins, created_at
         FROM users 
         WHERE username = $1
     `
+	ctx, end := track(ctx, "users.GetByUsername", query)
+	defer end()
 
 	res := model.User{}
-	err := st.conn.QueryRow(context.Background(), query, username).Scan(&res.ID, &res.Username, &res.Coins)
+	err := st.pool.QueryRow(ctx, query, username).Scan(&res.ID, &res.Username, &res.Coins, &res.CreatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, storage.ErrUserNotFound
 	}
@@ -57,21 +79,49 @@ func (st *UserStoragePostgres) GetByUsername(username string) (*model.User, erro
 	return &res, nil
 }
 
-func (st *UserStoragePostgres) UpdateCoins(userID int, newCoins int) error {
+func (st *UserStoragePostgres) GetExpiringLots(ctx context.Context, userID int) ([]model.CoinLot, error) {
 	query := `
-        UPDATE users 
-        SET coins = $1 
-        WHERE id = $2
+        SELECT id, user_id, amount, remaining, source, expires_at, created_at
+        FROM coin_lots
+        WHERE user_id = $1 AND remaining > 0 AND expires_at IS NOT NULL
+        ORDER BY expires_at
     `
+	ctx, end := track(ctx, "users.GetExpiringLots", query)
+	defer end()
 
-	result, err := st.conn.Exec(context.Background(), query, newCoins, userID)
+	rows, err := st.pool.Query(ctx, query, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return errors.New("user not found")
+	var lots []model.CoinLot
+	for rows.Next() {
+		var lot model.CoinLot
+		err := rows.Scan(&lot.ID, &lot.UserID, &lot.Amount, &lot.Remaining, &lot.Source, &lot.ExpiresAt, &lot.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
 	}
-	return nil
+	return lots, rows.Err()
+}
+
+func (st *UserStoragePostgres) TotalCoins(ctx context.Context) (int, error) {
+	query := `
+        SELECT COALESCE(SUM(coins), 0) FROM users
+    `
+	ctx, end := track(ctx, "users.TotalCoins", query)
+	defer end()
+
+	var total int
+	err := st.pool.QueryRow(ctx, query).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (st *UserStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
 }
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WishlistStoragePostgres struct {
+	pool *pgxpool.Pool
+}
+
+func CreateWishlistStoragePostgres(postgresConnect string) (*WishlistStoragePostgres, error) {
+	pool, err := connect(postgresConnect)
+	if err != nil {
+		return nil, err
+	}
+	return &WishlistStoragePostgres{pool}, nil
+}
+
+func (st *WishlistStoragePostgres) GetWishlist(ctx context.Context, username string) (*model.Wishlist, error) {
+	query := `
+        SELECT w.item_name, m.price, w.created_at
+        FROM wishlist_items w
+                 JOIN users u ON u.id = w.user_id
+                 JOIN merch m ON m.name = w.item_name
+        WHERE u.username = $1
+        ORDER BY w.created_at, w.item_name
+    `
+	ctx, end := track(ctx, "wishlists.GetWishlist", query)
+	defer end()
+
+	wishlist := model.Wishlist{Username: username, Items: []model.WishlistItem{}}
+	err := st.pool.QueryRow(ctx, `SELECT wishlist_visibility FROM users WHERE username = $1`, username).
+		Scan(&wishlist.Visibility)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := st.pool.Query(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.WishlistItem
+		if err := rows.Scan(&item.Item, &item.Price, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		wishlist.Items = append(wishlist.Items, item)
+	}
+	return &wishlist, rows.Err()
+}
+
+func (st *WishlistStoragePostgres) AddItem(ctx context.Context, username string, item string) error {
+	query := `
+        INSERT INTO wishlist_items (user_id, item_name)
+        SELECT id, $2 FROM users WHERE username = $1
+        ON CONFLICT (user_id, item_name) DO NOTHING
+    `
+	ctx, end := track(ctx, "wishlists.AddItem", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, username, item)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+		return storage.ErrMerchNotFound
+	}
+	return err
+}
+
+func (st *WishlistStoragePostgres) RemoveItem(ctx context.Context, username string, item string) error {
+	query := `
+        DELETE FROM wishlist_items
+        WHERE user_id = (SELECT id FROM users WHERE username = $1) AND item_name = $2
+    `
+	ctx, end := track(ctx, "wishlists.RemoveItem", query)
+	defer end()
+
+	_, err := st.pool.Exec(ctx, query, username, item)
+	return err
+}
+
+func (st *WishlistStoragePostgres) SetVisibility(ctx context.Context, username string, visibility string) error {
+	query := `
+        UPDATE users SET wishlist_visibility = $2 WHERE username = $1
+    `
+	ctx, end := track(ctx, "wishlists.SetVisibility", query)
+	defer end()
+
+	result, err := st.pool.Exec(ctx, query, username, visibility)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func (st *WishlistStoragePostgres) Ping(ctx context.Context) error {
+	return st.pool.Ping(ctx)
+}
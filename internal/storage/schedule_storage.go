@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrScheduleNotFound = fmt.Errorf("schedule not found")
+
+type ScheduleStorage interface {
+	CreateSchedule(ctx context.Context, schedule model.Schedule) (int, error)
+	GetSchedule(ctx context.Context, id int) (*model.Schedule, error)
+	GetSchedules(ctx context.Context) ([]model.Schedule, error)
+	UpdateStatus(ctx context.Context, id int, status string, nextRunAt time.Time) error
+	GetRuns(ctx context.Context, scheduleID int, count int) ([]model.ScheduleRun, error)
+	// RunDue pays one due period of one active schedule and moves its next run forward
+	// in a single transaction. It returns nil when no schedule is due at now.
+	RunDue(ctx context.Context, now time.Time) (*model.ScheduleRun, error)
+	SaveFailedRun(ctx context.Context, run model.ScheduleRun) error
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrSSOLoginNotFound = fmt.Errorf("login not found or expired")
+var ErrIdentityNotFound = fmt.Errorf("identity not found")
+var ErrIdentityExists = fmt.Errorf("identity already exists")
+
+type SSOStorage interface {
+	// CreateLogin stores a started login and drops the expired ones.
+	CreateLogin(ctx context.Context, login model.SSOLogin) error
+	// TakeLogin removes the login started with state and returns it unless it expired before now.
+	TakeLogin(ctx context.Context, state string, now time.Time) (*model.SSOLogin, error)
+	GetIdentity(ctx context.Context, issuer string, subject string) (*model.Identity, error)
+	CreateIdentity(ctx context.Context, identity model.Identity) error
+	// TouchIdentity records a login, email is updated to the latest one from the provider.
+	TouchIdentity(ctx context.Context, issuer string, subject string, email string, at time.Time) error
+}
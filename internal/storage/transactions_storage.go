@@ -1,8 +1,47 @@
 package storage
 
-import "avito-merch-store/model"
+import (
+	"avito-merch-store/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrTransactionNotFound = fmt.Errorf("transfer not found")
+var ErrTransactionNotPending = fmt.Errorf("transfer is not pending")
+var ErrTransactionExpired = fmt.Errorf("transfer has expired")
+var ErrDailyLimitExceeded = fmt.Errorf("daily outgoing transfer limit exceeded")
+var ErrWeeklyLimitExceeded = fmt.Errorf("weekly outgoing transfer limit exceeded")
+var ErrRecipientLimitExceeded = fmt.Errorf("too many transfers to this user today")
+
+// TransferCaps bound what a sender may send to other users. They are checked inside
+// the transfer transaction, so concurrent transfers of one sender cannot exceed them.
+// Zero values disable the corresponding cap.
+type TransferCaps struct {
+	Daily             int
+	Weekly            int
+	PerRecipientDaily int
+}
 
 type TransactionStorage interface {
-	CreateTransaction(senderUsername string, receiverUsername string, amount int) error
-	GetTransactionHistory(username string, count int) ([]model.Transaction, error)
+	// Transfer moves amount coins from the sender to the receiver and records the transfer
+	// together with its CoinsTransferred event in one transaction. It fails with
+	// ErrDailyLimitExceeded, ErrWeeklyLimitExceeded or ErrRecipientLimitExceeded when the
+	// transfer would break caps.
+	Transfer(ctx context.Context, senderUsername string, receiverUsername string, amount int,
+		caps TransferCaps) (*model.Transaction, error)
+	GetTransactionHistory(ctx context.Context, username string, count int) ([]model.Transaction, error)
+	// CreatePendingTransaction takes amount coins from the sender and records a transfer that
+	// waits for the receiver until expiresAt, together with its CoinsReserved event in one
+	// transaction. Pending transfers count against caps like completed ones.
+	CreatePendingTransaction(ctx context.Context, senderUsername string, receiverUsername string,
+		amount int, expiresAt time.Time, caps TransferCaps) (*model.Transaction, error)
+	GetTransaction(ctx context.Context, id int) (*model.Transaction, error)
+	GetPendingTransactions(ctx context.Context, username string) ([]model.Transaction, error)
+	// ResolvePendingTransaction moves a pending transfer to status. Completed transfers
+	// are credited to the receiver, any other status refunds the sender. A transfer whose
+	// time is over can no longer be completed, that fails with ErrTransactionExpired.
+	ResolvePendingTransaction(ctx context.Context, id int, status string) (*model.Transaction, error)
+	// GetExpiredPendingIDs returns up to count pending transfers whose time is over.
+	GetExpiredPendingIDs(ctx context.Context, count int) ([]int, error)
 }
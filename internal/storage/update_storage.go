@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+)
+
+// UpdateStorage carries live updates between the instances of the service.
+type UpdateStorage interface {
+	// PublishUpdate sends update of username to every instance, including this one.
+	PublishUpdate(ctx context.Context, username string, update model.Update) error
+	// Listen calls handle for every published update until ctx is done or the connection fails.
+	// It blocks the storage, listeners need an instance of their own.
+	Listen(ctx context.Context, handle func(username string, update model.Update)) error
+}
@@ -2,13 +2,16 @@ package storage
 
 import (
 	"avito-merch-store/model"
+	"context"
 	"fmt"
 )
 
 var ErrUserNotFound = fmt.Errorf("user not found")
+var ErrNotEnoughCoins = fmt.Errorf("not enough coins")
 
 type UserStorage interface {
-	Create(username string, coins int) error
-	GetByUsername(username string) (*model.User, error)
-	UpdateCoins(userID int, newCoins int) error
+	Create(ctx context.Context, username string, coins int) error
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	GetExpiringLots(ctx context.Context, userID int) ([]model.CoinLot, error)
+	TotalCoins(ctx context.Context) (int, error)
 }
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"avito-merch-store/model"
+	"context"
+)
+
+type WishlistStorage interface {
+	// GetWishlist fails with ErrUserNotFound for unknown users.
+	GetWishlist(ctx context.Context, username string) (*model.Wishlist, error)
+	// AddItem fails with ErrMerchNotFound for unknown items, adding an item twice is a no-op.
+	AddItem(ctx context.Context, username string, item string) error
+	RemoveItem(ctx context.Context, username string, item string) error
+	SetVisibility(ctx context.Context, username string, visibility string) error
+}
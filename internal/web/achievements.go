@@ -0,0 +1,21 @@
+package web
+
+import (
+	"avito-merch-store/model"
+	"net/http"
+)
+
+type AchievementsResponse struct {
+	Achievements []model.Achievement `json:"achievements"`
+	Awarded      []model.Award       `json:"awarded"`
+}
+
+func (s *Service) GetAchievementsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	awards, err := s.achievements.Awards(ctx, ctx.Value("name").(string))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, AchievementsResponse{s.achievements.List(), awards})
+}
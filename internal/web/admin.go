@@ -0,0 +1,147 @@
+package web
+
+import (
+	"avito-merch-store/internal/grants"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type GrantRequest struct {
+	Grants []model.GrantItem `json:"grants"`
+}
+
+// AdminMiddleware authenticates the request and lets through only configured admins.
+func (s *Service) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if !s.admins[r.Context().Value("name").(string)] {
+			respondWithError(w, http.StatusForbidden, "admin rights required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// CreateGrantsHandler accepts a batch as JSON ({"grants": [...]})
+// or as CSV with username,amount,reason rows.
+func (s *Service) CreateGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	items, err := parseGrants(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	batch, err := s.grants.Submit(ctx, ctx.Value("name").(string), items)
+	s.respondWithBatch(w, batch, err)
+}
+
+func (s *Service) GetGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	batches, err := s.grants.List(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, batches)
+}
+
+func (s *Service) GetGrantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid batch id")
+		return
+	}
+	batch, err := s.grants.Get(r.Context(), id)
+	s.respondWithBatch(w, batch, err)
+}
+
+func (s *Service) ApproveGrantHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid batch id")
+		return
+	}
+	batch, err := s.grants.Approve(ctx, ctx.Value("name").(string), id)
+	s.respondWithBatch(w, batch, err)
+}
+
+func (s *Service) RejectGrantHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid batch id")
+		return
+	}
+	batch, err := s.grants.Reject(ctx, ctx.Value("name").(string), id)
+	s.respondWithBatch(w, batch, err)
+}
+
+func (s *Service) respondWithBatch(w http.ResponseWriter, batch *model.GrantBatch, err error) {
+	switch {
+	case errors.Is(err, grants.ErrInvalidBatch):
+		respondWithJSON(w, http.StatusBadRequest, batch)
+	case errors.Is(err, storage.ErrGrantFailed):
+		respondWithJSON(w, http.StatusUnprocessableEntity, batch)
+	case errors.Is(err, storage.ErrGrantNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrGrantNotPending):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, grants.ErrSelfApproval):
+		respondWithError(w, http.StatusForbidden, err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+	case batch.Status == model.GrantPendingApproval:
+		respondWithJSON(w, http.StatusAccepted, batch)
+	default:
+		respondWithJSON(w, http.StatusOK, batch)
+	}
+}
+
+func parseGrants(r *http.Request) ([]model.GrantItem, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return parseGrantsCSV(r.Body)
+	}
+
+	var req GrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.New("error: invalid JSON format")
+	}
+	return req.Grants, nil
+}
+
+// parseGrantsCSV reads username,amount,reason rows, a header row is optional.
+func parseGrantsCSV(body io.Reader) ([]model.GrantItem, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var items []model.GrantItem
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error: invalid CSV: %w", err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "username") {
+			continue
+		}
+		amount, err := strconv.Atoi(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("error: invalid amount on line %d", line)
+		}
+		items = append(items, model.GrantItem{Username: record[0], Amount: amount, Reason: record[2]})
+	}
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"avito-merch-store/internal/apikeys"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKeyResponse carries the key, it is not shown again.
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey *model.APIKey `json:"apiKey"`
+}
+
+type BalanceResponse struct {
+	Username string `json:"username"`
+	Coins    int    `json:"coins"`
+}
+
+// APIKeyMiddleware authenticates integrations by an API key with scope, taken from the
+// X-API-Key header or from a bearer token. The key acts as the user "apikey:<name>".
+func (s *Service) APIKeyMiddleware(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plain := r.Header.Get("X-API-Key")
+		if plain == "" {
+			plain = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if plain == "" {
+			http.Error(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		key, err := s.apiKeys.Authenticate(r.Context(), plain)
+		if errors.Is(err, apikeys.ErrInvalidKey) || errors.Is(err, apikeys.ErrKeyExpired) ||
+			errors.Is(err, apikeys.ErrKeyRevoked) {
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+			return
+		}
+		if !key.HasScope(scope) {
+			respondWithError(w, http.StatusForbidden, "api key lacks scope "+scope)
+			return
+		}
+		name := "apikey:" + key.Name
+		setUsername(r.Context(), name)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.Int("apikey.id", key.ID))
+		ctx := context.WithValue(r.Context(), "name", name)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func (s *Service) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	plain, key, err := s.apiKeys.Create(ctx, ctx.Value("name").(string), req.Name, req.Scopes, req.ExpiresAt)
+	if errors.Is(err, apikeys.ErrInvalidRequest) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, CreateAPIKeyResponse{Key: plain, APIKey: key})
+}
+
+func (s *Service) GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.apiKeys.List(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+func (s *Service) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+	key, err := s.apiKeys.Revoke(ctx, ctx.Value("name").(string), id)
+	if errors.Is(err, apikeys.ErrKeyNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, key)
+}
+
+// GetUserBalanceHandler lets integrations read the balance of any user.
+func (s *Service) GetUserBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	coins, err := s.merch.GetBalance(r.Context(), username)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, BalanceResponse{Username: username, Coins: coins})
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"avito-merch-store/internal/images"
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+)
+
+type ImageResponse struct {
+	ImageURL string `json:"imageUrl"`
+}
+
+// GetCatalogHandler lists the catalog, ?category= and ?tag= narrow it down.
+func (s *Service) GetCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	catalog, err := s.merch.GetCatalog(r.Context(), model.CatalogFilter{
+		Category: query.Get("category"),
+		Tag:      query.Get("tag"),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, catalog)
+}
+
+func (s *Service) UpdateItemDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var details model.ItemDetails
+	if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	previous, err := s.merch.UpdateItemDetails(ctx, mux.Vars(r)["item"], details)
+	if errors.Is(err, merchant.ErrInvalidDetails) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrMerchNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	if previous != details.ImageURL {
+		s.removeImage(ctx, previous)
+	}
+	respondWithJSON(w, http.StatusOK, nil)
+}
+
+// UploadItemImageHandler accepts the image either as the request body
+// or as the "image" file of a multipart form.
+func (s *Service) UploadItemImageHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body, err := imageBody(w, r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	url, err := s.images.Save(body)
+	if errors.Is(err, images.ErrUnsupportedImage) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if errors.Is(err, images.ErrImageTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	previous, err := s.merch.SetItemImage(ctx, mux.Vars(r)["item"], url)
+	if err != nil {
+		s.removeImage(ctx, url)
+	}
+	if errors.Is(err, storage.ErrMerchNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	s.removeImage(ctx, previous)
+	respondWithJSON(w, http.StatusOK, ImageResponse{ImageURL: url})
+}
+
+func imageBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 2*images.MaxSize)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return nil, errors.New("multipart form must contain an image file")
+	}
+	return file, nil
+}
+
+// removeImage deletes an uploaded image that is no longer used, external URLs are left alone.
+func (s *Service) removeImage(ctx context.Context, url string) {
+	if s.images == nil || url == "" {
+		return
+	}
+	if err := s.images.Remove(url); err != nil {
+		logging.FromContext(ctx).Error("cannot remove image", "image_url", url, "error", err)
+	}
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"avito-merch-store/internal/coinrequests"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type CreateCoinRequestRequest struct {
+	From   []string `json:"from"`
+	Amount int      `json:"amount"`
+	Note   string   `json:"note"`
+}
+
+type CoinRequestsResponse struct {
+	Incoming []model.CoinRequest `json:"incoming"`
+	Outgoing []model.CoinRequest `json:"outgoing"`
+}
+
+func (s *Service) CreateCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CreateCoinRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+
+	requests, err := s.requests.Create(ctx, ctx.Value("name").(string), req.From, req.Amount, req.Note)
+	if errors.Is(err, coinrequests.ErrInvalidRequest) {
+		respondWithError(w, http.StatusBadRequest,
+			"positive amount and up to 100 other users are required, note is limited to 500 characters")
+		return
+	}
+	if errors.Is(err, storage.ErrUserNotFound) {
+		respondWithErrorCode(w, http.StatusBadRequest, "user_not_found", "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, requests)
+}
+
+func (s *Service) GetCoinRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	incoming, outgoing, err := s.requests.List(ctx, ctx.Value("name").(string))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, CoinRequestsResponse{incoming, outgoing})
+}
+
+func (s *Service) PayCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid coin request id")
+		return
+	}
+	request, err := s.requests.Pay(ctx, ctx.Value("name").(string), id)
+	if err != nil {
+		if !respondWithCoinRequestError(w, err) {
+			respondWithSendError(w, err)
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusOK, request)
+}
+
+func (s *Service) RejectCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid coin request id")
+		return
+	}
+	request, err := s.requests.Reject(ctx, ctx.Value("name").(string), id)
+	if err != nil {
+		if !respondWithCoinRequestError(w, err) {
+			respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusOK, request)
+}
+
+// respondWithCoinRequestError reports whether err is a coin request error and was written.
+func respondWithCoinRequestError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, storage.ErrCoinRequestNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, coinrequests.ErrForbidden):
+		respondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, storage.ErrCoinRequestNotPending):
+		respondWithError(w, http.StatusConflict, err.Error())
+	default:
+		return false
+	}
+	return true
+}
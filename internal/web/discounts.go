@@ -0,0 +1,164 @@
+package web
+
+import (
+	"avito-merch-store/internal/discounts"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// promoCodeErrors maps errors of promo codes at purchase to error codes.
+var promoCodeErrors = []struct {
+	err  error
+	code string
+}{
+	{storage.ErrPromoCodeNotApplicable, "promo_code_not_applicable"},
+	{storage.ErrPromoCodeExhausted, "promo_code_exhausted"},
+	{storage.ErrPromoCodeUsed, "promo_code_used"},
+}
+
+type CreateSaleRequest struct {
+	Item     string    `json:"item"`
+	Kind     string    `json:"kind"`
+	Value    int       `json:"value"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+}
+
+type CreatePromoCodeRequest struct {
+	Code           string     `json:"code"`
+	Item           string     `json:"item"`
+	Kind           string     `json:"kind"`
+	Value          int        `json:"value"`
+	MaxUses        int        `json:"maxUses"`
+	MaxUsesPerUser *int       `json:"maxUsesPerUser"`
+	StartsAt       time.Time  `json:"startsAt"`
+	EndsAt         *time.Time `json:"endsAt"`
+}
+
+func (s *Service) CreateSaleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CreateSaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	sale, err := s.discounts.CreateSale(ctx, model.Sale{
+		Item:      req.Item,
+		Kind:      req.Kind,
+		Value:     req.Value,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		CreatedBy: ctx.Value("name").(string),
+	})
+	if errors.Is(err, discounts.ErrInvalidSale) {
+		respondWithError(w, http.StatusBadRequest,
+			"kind must be percent (1-100) or fixed with a positive value, endsAt must be after startsAt")
+		return
+	}
+	if errors.Is(err, storage.ErrMerchNotFound) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, sale)
+}
+
+func (s *Service) GetSalesHandler(w http.ResponseWriter, r *http.Request) {
+	sales, err := s.discounts.ListSales(r.Context(), r.URL.Query().Get("active") == "true")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, sales)
+}
+
+func (s *Service) EndSaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid sale id")
+		return
+	}
+	err = s.discounts.EndSale(r.Context(), id)
+	if errors.Is(err, storage.ErrSaleNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, nil)
+}
+
+func (s *Service) CreatePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CreatePromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	perUser := 1
+	if req.MaxUsesPerUser != nil {
+		perUser = *req.MaxUsesPerUser
+	}
+	code, err := s.discounts.CreatePromoCode(ctx, model.PromoCode{
+		Code:           req.Code,
+		Item:           req.Item,
+		Kind:           req.Kind,
+		Value:          req.Value,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: perUser,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		CreatedBy:      ctx.Value("name").(string),
+	})
+	if errors.Is(err, discounts.ErrInvalidPromoCode) {
+		respondWithError(w, http.StatusBadRequest,
+			"code must have 3-32 letters, digits, _ or -, kind must be percent (1-100) or fixed with a positive value")
+		return
+	}
+	if errors.Is(err, storage.ErrMerchNotFound) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrPromoCodeExists) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, code)
+}
+
+func (s *Service) GetPromoCodesHandler(w http.ResponseWriter, r *http.Request) {
+	codes, err := s.discounts.ListPromoCodes(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, codes)
+}
+
+func (s *Service) DisablePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.discounts.DisablePromoCode(r.Context(), mux.Vars(r)["code"])
+	if errors.Is(err, storage.ErrPromoCodeNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"avito-merch-store/internal/fulfillment"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type ChooseLocationRequest struct {
+	LocationID int `json:"locationId"`
+}
+
+type CreateLocationRequest struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// OfficeManagerMiddleware lets through office managers and admins.
+func (s *Service) OfficeManagerMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Context().Value("name").(string)
+		if !s.officeManagers[name] && !s.admins[name] {
+			respondWithError(w, http.StatusForbidden, "office manager rights required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Service) GetOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orders, err := s.fulfillment.List(ctx, ctx.Value("name").(string))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, orders)
+}
+
+func (s *Service) ChooseLocationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	var req ChooseLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	order, err := s.fulfillment.ChooseLocation(ctx, ctx.Value("name").(string), id, req.LocationID)
+	respondWithOrder(w, order, err)
+}
+
+func (s *Service) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	s.handleOrder(w, r, s.fulfillment.Cancel)
+}
+
+// GetFulfillmentQueueHandler lists orders to prepare, ?status= and ?location= narrow the queue down.
+func (s *Service) GetFulfillmentQueueHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	locationID := 0
+	if location := query.Get("location"); location != "" {
+		var err error
+		if locationID, err = strconv.Atoi(location); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid location id")
+			return
+		}
+	}
+	orders, err := s.fulfillment.Queue(r.Context(), query.Get("status"), locationID)
+	if errors.Is(err, fulfillment.ErrInvalidStatus) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, orders)
+}
+
+func (s *Service) MarkOrderReadyHandler(w http.ResponseWriter, r *http.Request) {
+	s.handleOrder(w, r, s.fulfillment.MarkReady)
+}
+
+func (s *Service) MarkOrderDeliveredHandler(w http.ResponseWriter, r *http.Request) {
+	s.handleOrder(w, r, s.fulfillment.MarkDelivered)
+}
+
+func (s *Service) CancelOrderByManagerHandler(w http.ResponseWriter, r *http.Request) {
+	s.handleOrder(w, r, s.fulfillment.CancelByManager)
+}
+
+func (s *Service) GetPickupLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	locations, err := s.fulfillment.ListLocations(r.Context(), true)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, locations)
+}
+
+func (s *Service) GetAllPickupLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	locations, err := s.fulfillment.ListLocations(r.Context(), false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, locations)
+}
+
+func (s *Service) CreatePickupLocationHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	location, err := s.fulfillment.CreateLocation(r.Context(), req.Name, req.Address)
+	if errors.Is(err, fulfillment.ErrInvalidLocation) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrLocationExists) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, location)
+}
+
+func (s *Service) DisablePickupLocationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid location id")
+		return
+	}
+	err = s.fulfillment.DisableLocation(r.Context(), id)
+	if errors.Is(err, storage.ErrLocationNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, nil)
+}
+
+func (s *Service) handleOrder(w http.ResponseWriter, r *http.Request,
+	handle func(ctx context.Context, username string, id int) (*model.Order, error)) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	order, err := handle(ctx, ctx.Value("name").(string), id)
+	respondWithOrder(w, order, err)
+}
+
+func respondWithOrder(w http.ResponseWriter, order *model.Order, err error) {
+	if errors.Is(err, storage.ErrOrderNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrLocationNotFound) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrOrderStatus) || errors.Is(err, fulfillment.ErrLocationRequired) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, order)
+}
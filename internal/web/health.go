@@ -0,0 +1,21 @@
+package web
+
+import (
+	"avito-merch-store/internal/health"
+	"net/http"
+)
+
+// LivenessHandler reports that the process is running and able to serve requests.
+func (s *Service) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, health.Report{Status: health.StatusUp})
+}
+
+// ReadinessHandler reports the status of every component the service depends on.
+func (s *Service) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report, ready := s.health.Ready(r.Context())
+	if !ready {
+		respondWithJSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
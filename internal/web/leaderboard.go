@@ -0,0 +1,59 @@
+package web
+
+import (
+	"avito-merch-store/internal/leaderboard"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type OptOutRequest struct {
+	OptOut bool `json:"optOut"`
+}
+
+func (s *Service) GetLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	board := query.Get("board")
+	if board == "" {
+		board = model.BoardBalance
+	}
+	period := query.Get("period")
+	if period == "" {
+		period = model.PeriodAllTime
+	}
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	result, err := s.leaderboard.Get(r.Context(), board, period, limit)
+	if errors.Is(err, leaderboard.ErrUnknownBoard) || errors.Is(err, leaderboard.ErrUnknownPeriod) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (s *Service) SetLeaderboardOptOutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req OptOutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	if err := s.leaderboard.SetOptOut(ctx, ctx.Value("name").(string), req.OptOut); err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, req)
+}
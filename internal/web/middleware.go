@@ -0,0 +1,182 @@
+package web
+
+import (
+	"avito-merch-store/internal/logging"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/tracing"
+	"context"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of streaming handlers.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+const requestIDHeader = "X-Request-ID"
+
+type stateKey struct{}
+
+// requestState is shared between LoggingMiddleware and the handlers it wraps,
+// so values discovered deeper in the chain (like the username) reach the access log.
+type requestState struct {
+	username string
+}
+
+func setUsername(ctx context.Context, username string) {
+	if st, ok := ctx.Value(stateKey{}).(*requestState); ok {
+		st.username = username
+	}
+}
+
+// LoggingMiddleware assigns a request ID (taken from X-Request-ID when present),
+// echoes it in the response and writes an access log line once the request is served.
+func (s *Service) LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		st := &requestState{}
+		ctx := logging.WithRequestID(r.Context(), id)
+		ctx = context.WithValue(ctx, stateKey{}, st)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(ctx).Log(ctx, level, "request",
+			"method", r.Method,
+			"route", routeTemplate(r),
+			"status", rec.status,
+			"latency", time.Since(start),
+			"username", st.username,
+		)
+	})
+}
+
+// TracingMiddleware continues the trace from the incoming traceparent header
+// and wraps the request in a server span named after the route template.
+func (s *Service) TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		propagator := otel.GetTextMapPropagator()
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		ctx, span := tracing.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+			),
+		)
+		defer span.End()
+		propagator.Inject(ctx, propagation.HeaderCarrier(w.Header()))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// MetricsMiddleware records request count and latency labeled by the route template,
+// so /api/buy/{item} is reported as a single series.
+func (s *Service) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.status)
+		route := routeTemplate(r)
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unknown"
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "unknown"
+	}
+	return tpl
+}
+
+// RateLimitByUser limits requests of the authenticated user under the named route policy.
+// It must be wrapped by AuthMiddleware.
+func (s *Service) RateLimitByUser(route string, next http.HandlerFunc) http.HandlerFunc {
+	return s.rateLimit(route, func(r *http.Request) string {
+		return "user:" + r.Context().Value("name").(string)
+	}, next)
+}
+
+// RateLimitByIP limits requests coming from the same client address under the named route policy.
+func (s *Service) RateLimitByIP(route string, next http.HandlerFunc) http.HandlerFunc {
+	return s.rateLimit(route, func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host
+	}, next)
+}
+
+func (s *Service) rateLimit(route string, key func(r *http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	policy, ok := s.limits[route]
+	if s.limiter == nil || !ok {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		allowed, wait, err := s.limiter.Allow(ctx, route+":"+key(r), policy)
+		if err != nil {
+			// a broken limiter must not take the whole API down
+			logging.FromContext(ctx).Error("rate limiter failed", "route", route, "error", err)
+			next(w, r)
+			return
+		}
+		if !allowed {
+			metrics.RateLimited.WithLabelValues(route).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondWithError(w, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"avito-merch-store/internal/notify"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type MarkReadRequest struct {
+	IDs []int `json:"ids"`
+}
+
+type MarkReadResponse struct {
+	Marked int `json:"marked"`
+}
+
+// GetNotificationsHandler lists the inbox, ?unread=true leaves out read notifications.
+func (s *Service) GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	notifications, err := s.notifier.List(ctx, ctx.Value("name").(string), r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+// MarkNotificationsReadHandler marks the listed notifications as read, all of them without ids.
+func (s *Service) MarkNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	marked, err := s.notifier.MarkRead(ctx, ctx.Value("name").(string), req.IDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, MarkReadResponse{Marked: marked})
+}
+
+func (s *Service) GetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	prefs, err := s.notifier.Preferences(ctx, ctx.Value("name").(string))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (s *Service) SetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req model.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	prefs, err := s.notifier.SetPreferences(ctx, ctx.Value("name").(string), req)
+	if errors.Is(err, notify.ErrInvalidPreferences) || errors.Is(err, notify.ErrChannelUnavailable) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
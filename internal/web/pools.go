@@ -0,0 +1,116 @@
+package web
+
+import (
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/pools"
+	"avito-merch-store/internal/storage"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type CreatePoolRequest struct {
+	Item      string `json:"item"`
+	Recipient string `json:"recipient"`
+}
+
+type ContributeRequest struct {
+	Amount int `json:"amount"`
+}
+
+func (s *Service) CreatePoolHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CreatePoolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	pool, err := s.pools.Create(ctx, ctx.Value("name").(string), req.Item, req.Recipient)
+	if err != nil {
+		respondWithPoolError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, pool)
+}
+
+func (s *Service) GetPoolsHandler(w http.ResponseWriter, r *http.Request) {
+	pools, err := s.pools.ListOpen(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, pools)
+}
+
+func (s *Service) GetPoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid pool id")
+		return
+	}
+	pool, err := s.pools.Get(r.Context(), id)
+	if err != nil {
+		respondWithPoolError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, pool)
+}
+
+func (s *Service) ContributeToPoolHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid pool id")
+		return
+	}
+	var req ContributeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	pool, err := s.pools.Contribute(ctx, ctx.Value("name").(string), id, req.Amount)
+	if err != nil {
+		respondWithPoolError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, pool)
+}
+
+func (s *Service) CancelPoolHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid pool id")
+		return
+	}
+	pool, err := s.pools.Cancel(ctx, ctx.Value("name").(string), id)
+	if err != nil {
+		respondWithPoolError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, pool)
+}
+
+func respondWithPoolError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrPoolNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrPoolNotOpen):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, pools.ErrNotOrganizer):
+		respondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, storage.ErrMerchNotFound),
+		errors.Is(err, pools.ErrInvalidRecipient),
+		errors.Is(err, pools.ErrIncorrectAmount),
+		errors.Is(err, storage.ErrPoolOverfunded),
+		errors.Is(err, storage.ErrPoolVariantItem),
+		errors.Is(err, merchant.ErrNotEnoughCoins):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrUserNotFound):
+		respondWithErrorCode(w, http.StatusBadRequest, "user_not_found", "user not found")
+	default:
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+	}
+}
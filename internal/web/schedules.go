@@ -0,0 +1,112 @@
+package web
+
+import (
+	"avito-merch-store/internal/scheduler"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CreateScheduleRequest struct {
+	Name       string    `json:"name"`
+	Amount     int       `json:"amount"`
+	Period     string    `json:"period"`
+	MaxBalance *int      `json:"maxBalance"`
+	StartAt    time.Time `json:"startAt"`
+}
+
+type ScheduleResponse struct {
+	Schedule *model.Schedule     `json:"schedule"`
+	Runs     []model.ScheduleRun `json:"runs"`
+}
+
+func (s *Service) CreateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+
+	schedule, err := s.scheduler.Create(ctx, model.Schedule{
+		Name:       req.Name,
+		Amount:     req.Amount,
+		Period:     req.Period,
+		MaxBalance: req.MaxBalance,
+		CreatedBy:  ctx.Value("name").(string),
+	}, req.StartAt)
+	if errors.Is(err, scheduler.ErrInvalidSchedule) {
+		respondWithError(w, http.StatusBadRequest,
+			"name and positive amount are required, period must be daily, weekly or monthly")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, schedule)
+}
+
+func (s *Service) GetSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	schedules, err := s.scheduler.List(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, schedules)
+}
+
+func (s *Service) GetScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid schedule id")
+		return
+	}
+	schedule, runs, err := s.scheduler.Get(r.Context(), id)
+	if err != nil {
+		respondWithScheduleError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ScheduleResponse{schedule, runs})
+}
+
+func (s *Service) PauseScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid schedule id")
+		return
+	}
+	schedule, err := s.scheduler.Pause(r.Context(), id)
+	if err != nil {
+		respondWithScheduleError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, schedule)
+}
+
+func (s *Service) ResumeScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid schedule id")
+		return
+	}
+	schedule, err := s.scheduler.Resume(r.Context(), id)
+	if err != nil {
+		respondWithScheduleError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, schedule)
+}
+
+func respondWithScheduleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrScheduleNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"avito-merch-store/internal/sso"
+	"avito-merch-store/model"
+	"errors"
+	"net/http"
+)
+
+// SSOLoginHandler redirects to the identity provider, ?login_hint= is passed on to it.
+func (s *Service) SSOLoginHandler(w http.ResponseWriter, r *http.Request) {
+	redirect, err := s.sso.Begin(r.Context(), r.URL.Query().Get("login_hint"))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, sww(err.Error()))
+		return
+	}
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// SSOCallbackHandler completes the login the provider redirected back from
+// and responds with a store token, like AuthHandler does.
+func (s *Service) SSOCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		respondWithError(w, http.StatusUnauthorized, "identity provider login failed: "+e)
+		return
+	}
+	if query.Get("state") == "" || query.Get("code") == "" {
+		respondWithError(w, http.StatusBadRequest, "state and code are required")
+		return
+	}
+	key, err := s.sso.Complete(r.Context(), query.Get("state"), query.Get("code"))
+	if errors.Is(err, sso.ErrInvalidState) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, sso.ErrLoginFailed) {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, model.AuthResponseWeb{Token: key})
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"avito-merch-store/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// keepAlive is how often an idle stream sends a comment, so proxies do not close it.
+const keepAlive = 15 * time.Second
+
+// StreamHandler pushes balance changes, transfers and purchases of the user as
+// server-sent events until the client disconnects. The first event is the current balance.
+func (s *Service) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := ctx.Value("name").(string)
+
+	// subscribe before reading the balance, so that no change falls in between
+	updates, unsubscribe := s.live.Subscribe(username)
+	defer unsubscribe()
+	info, err := s.merch.GetInfoByUsername(ctx, username)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	send := func(update model.Update) error {
+		data, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if send(model.Update{Type: model.UpdateBalance, Coins: info.Coins}) != nil {
+		return
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.live.Done():
+			return
+		case update := <-updates:
+			if send(update) != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
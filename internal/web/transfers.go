@@ -0,0 +1,61 @@
+package web
+
+import (
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+func (s *Service) GetPendingTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	transfers, err := s.merch.GetPendingTransfers(ctx, ctx.Value("name").(string))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, transfers)
+}
+
+func (s *Service) AcceptTransferHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveTransfer(w, r, s.merch.AcceptTransfer)
+}
+
+func (s *Service) DeclineTransferHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveTransfer(w, r, s.merch.DeclineTransfer)
+}
+
+func (s *Service) CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveTransfer(w, r, s.merch.CancelTransfer)
+}
+
+func (s *Service) resolveTransfer(w http.ResponseWriter, r *http.Request,
+	resolve func(ctx context.Context, username string, id int) (*model.Transaction, error)) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid transfer id")
+		return
+	}
+	transfer, err := resolve(ctx, ctx.Value("name").(string), id)
+	if errors.Is(err, merchant.ErrTransactionNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, merchant.ErrTransferForbidden) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, merchant.ErrTransactionNotPending) || errors.Is(err, merchant.ErrTransactionExpired) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, transfer)
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type CreateVariantRequest struct {
+	Size       string `json:"size"`
+	Color      string `json:"color"`
+	Stock      int    `json:"stock"`
+	PriceDelta int    `json:"priceDelta"`
+}
+
+type UpdateVariantRequest struct {
+	Stock      int `json:"stock"`
+	PriceDelta int `json:"priceDelta"`
+}
+
+func (s *Service) GetVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	variants, err := s.merch.GetVariants(r.Context(), mux.Vars(r)["item"])
+	if errors.Is(err, storage.ErrMerchNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, variants)
+}
+
+func (s *Service) CreateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	variant, err := s.merch.CreateVariant(r.Context(), model.ItemVariant{
+		Item:       mux.Vars(r)["item"],
+		Size:       req.Size,
+		Color:      req.Color,
+		Stock:      req.Stock,
+		PriceDelta: req.PriceDelta,
+	})
+	if errors.Is(err, merchant.ErrInvalidVariant) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrMerchNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, storage.ErrVariantExists) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, variant)
+}
+
+func (s *Service) UpdateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid variant id")
+		return
+	}
+	var req UpdateVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	variant, err := s.merch.UpdateVariant(r.Context(), id, req.Stock, req.PriceDelta)
+	if errors.Is(err, merchant.ErrInvalidVariant) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, merchant.ErrVariantNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, variant)
+}
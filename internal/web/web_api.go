@@ -1,16 +1,36 @@
 package web
 
 import (
+	"avito-merch-store/internal/achievements"
+	"avito-merch-store/internal/apikeys"
 	"avito-merch-store/internal/auth"
+	"avito-merch-store/internal/coinrequests"
+	"avito-merch-store/internal/discounts"
+	"avito-merch-store/internal/fulfillment"
+	"avito-merch-store/internal/grants"
+	"avito-merch-store/internal/health"
+	"avito-merch-store/internal/images"
+	"avito-merch-store/internal/leaderboard"
+	"avito-merch-store/internal/live"
 	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/notify"
+	"avito-merch-store/internal/pools"
+	"avito-merch-store/internal/ratelimit"
+	"avito-merch-store/internal/scheduler"
+	"avito-merch-store/internal/sso"
 	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/internal/wishlist"
 	"avito-merch-store/model"
 	"context"
 	"encoding/json"
 	"errors"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -20,6 +40,161 @@ type Service struct {
 	storage storage.AuthStorage
 	auth    auth.Authenticator
 	merch   merchant.Merchant
+	health  *health.Checker
+	limiter ratelimit.Limiter
+	limits  map[string]ratelimit.Policy
+	admins  map[string]bool
+	grants  *grants.Granter
+
+	scheduler *scheduler.Scheduler
+	requests  *coinrequests.Manager
+	pools     *pools.Manager
+	wishlists *wishlist.Manager
+
+	leaderboard  *leaderboard.Manager
+	achievements *achievements.Tracker
+	discounts    *discounts.Manager
+	images       *images.Store
+
+	fulfillment    *fulfillment.Manager
+	officeManagers map[string]bool
+	notifier       *notify.Notifier
+	live           *live.Hub
+	apiKeys        *apikeys.Manager
+	sso            *sso.Manager
+}
+
+// Option configures optional parts of the Service.
+type Option func(*Service)
+
+// WithRateLimit enables rate limiting. limits maps route names
+// (auth, info, transactions, sendCoin, buy, coinRequests, pools, wishlist, integrations) to their policies,
+// routes without a policy are not limited.
+func WithRateLimit(limiter ratelimit.Limiter, limits map[string]ratelimit.Policy) Option {
+	return func(s *Service) {
+		s.limiter = limiter
+		s.limits = limits
+	}
+}
+
+// WithAdmins grants admin rights to the listed usernames.
+func WithAdmins(usernames []string) Option {
+	return func(s *Service) {
+		s.admins = make(map[string]bool, len(usernames))
+		for _, name := range usernames {
+			s.admins[name] = true
+		}
+	}
+}
+
+// WithGrants exposes admin endpoints to grant and revoke coins.
+func WithGrants(granter *grants.Granter) Option {
+	return func(s *Service) {
+		s.grants = granter
+	}
+}
+
+// WithScheduler exposes admin endpoints to manage recurring allowances.
+func WithScheduler(sch *scheduler.Scheduler) Option {
+	return func(s *Service) {
+		s.scheduler = sch
+	}
+}
+
+// WithCoinRequests exposes endpoints to ask other users for coins.
+func WithCoinRequests(requests *coinrequests.Manager) Option {
+	return func(s *Service) {
+		s.requests = requests
+	}
+}
+
+// WithPools exposes endpoints to crowdfund purchases.
+func WithPools(manager *pools.Manager) Option {
+	return func(s *Service) {
+		s.pools = manager
+	}
+}
+
+// WithWishlists exposes endpoints to keep and share wishlists.
+func WithWishlists(manager *wishlist.Manager) Option {
+	return func(s *Service) {
+		s.wishlists = manager
+	}
+}
+
+// WithLeaderboard exposes the leaderboards and the opt-out setting.
+func WithLeaderboard(manager *leaderboard.Manager) Option {
+	return func(s *Service) {
+		s.leaderboard = manager
+	}
+}
+
+// WithAchievements exposes the achievement definitions and the badges of the user.
+func WithAchievements(tracker *achievements.Tracker) Option {
+	return func(s *Service) {
+		s.achievements = tracker
+	}
+}
+
+// WithDiscounts exposes admin endpoints to manage sales and promo codes.
+func WithDiscounts(manager *discounts.Manager) Option {
+	return func(s *Service) {
+		s.discounts = manager
+	}
+}
+
+// WithFulfillment enables orders of purchased items. officeManagers, together with
+// the admins, work through the fulfillment queue.
+func WithFulfillment(manager *fulfillment.Manager, officeManagers []string) Option {
+	return func(s *Service) {
+		s.fulfillment = manager
+		s.officeManagers = make(map[string]bool, len(officeManagers))
+		for _, name := range officeManagers {
+			s.officeManagers[name] = true
+		}
+	}
+}
+
+// WithNotifications enables the inbox and the notification preferences of users.
+func WithNotifications(notifier *notify.Notifier) Option {
+	return func(s *Service) {
+		s.notifier = notifier
+	}
+}
+
+// WithAPIKeys enables API keys of integrations and the routes they can call.
+func WithAPIKeys(manager *apikeys.Manager) Option {
+	return func(s *Service) {
+		s.apiKeys = manager
+	}
+}
+
+// WithSSO enables logging in with the OpenID Connect provider of manager.
+func WithSSO(manager *sso.Manager) Option {
+	return func(s *Service) {
+		s.sso = manager
+	}
+}
+
+// WithLive enables the stream of live balance and history updates.
+func WithLive(hub *live.Hub) Option {
+	return func(s *Service) {
+		s.live = hub
+	}
+}
+
+// WithImages enables uploading catalog images to store and serves them.
+func WithImages(store *images.Store) Option {
+	return func(s *Service) {
+		s.images = store
+	}
+}
+
+// WithHealth exposes /healthz and /readyz backed by checker.
+func WithHealth(checker *health.Checker) Option {
+	return func(s *Service) {
+		s.health = checker
+	}
 }
 
 type SendCoinRequest struct {
@@ -27,24 +202,133 @@ type SendCoinRequest struct {
 	Amount int    `json:"amount"`
 }
 
-func NewService(storage storage.AuthStorage, auth auth.Authenticator, merchant merchant.Merchant) *Service {
+func NewService(storage storage.AuthStorage, auth auth.Authenticator, merchant merchant.Merchant, opts ...Option) *Service {
 	s := &Service{
 		router:  mux.NewRouter(),
 		storage: storage,
 		auth:    auth,
 		merch:   merchant,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 
 	s.configureRouter()
 	return s
 }
 
 func (s *Service) configureRouter() {
-	s.router.HandleFunc("/api/info", s.AuthMiddleware(s.GetInfoHandler)).Methods("GET")
-	s.router.HandleFunc("/api/transactions", s.AuthMiddleware(s.GetTransactionsHandler)).Methods("GET")
-	s.router.HandleFunc("/api/sendCoin", s.AuthMiddleware(s.SendCoinHandler)).Methods("POST")
-	s.router.HandleFunc("/api/buy/{item}", s.AuthMiddleware(s.BuyItemHandler)).Methods("GET")
-	s.router.HandleFunc("/api/auth", s.AuthHandler).Methods("POST")
+	s.router.Use(s.LoggingMiddleware)
+	s.router.Use(s.TracingMiddleware)
+	s.router.Use(s.MetricsMiddleware)
+	s.router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	s.router.HandleFunc("/api/info", s.AuthMiddleware(s.RateLimitByUser("info", s.GetInfoHandler))).Methods("GET")
+	s.router.HandleFunc("/api/transactions", s.AuthMiddleware(s.RateLimitByUser("transactions", s.GetTransactionsHandler))).Methods("GET")
+	s.router.HandleFunc("/api/sendCoin", s.AuthMiddleware(s.RateLimitByUser("sendCoin", s.SendCoinHandler))).Methods("POST")
+	s.router.HandleFunc("/api/buy/{item}", s.AuthMiddleware(s.RateLimitByUser("buy", s.BuyItemHandler))).Methods("GET")
+	s.router.HandleFunc("/api/transfers/pending", s.AuthMiddleware(s.RateLimitByUser("transactions", s.GetPendingTransfersHandler))).Methods("GET")
+	s.router.HandleFunc("/api/transfers/{id:[0-9]+}/accept", s.AuthMiddleware(s.RateLimitByUser("sendCoin", s.AcceptTransferHandler))).Methods("POST")
+	s.router.HandleFunc("/api/transfers/{id:[0-9]+}/decline", s.AuthMiddleware(s.RateLimitByUser("sendCoin", s.DeclineTransferHandler))).Methods("POST")
+	s.router.HandleFunc("/api/transfers/{id:[0-9]+}/cancel", s.AuthMiddleware(s.RateLimitByUser("sendCoin", s.CancelTransferHandler))).Methods("POST")
+	s.router.HandleFunc("/api/merch", s.AuthMiddleware(s.RateLimitByUser("info", s.GetCatalogHandler))).Methods("GET")
+	s.router.HandleFunc("/api/merch/{item}/variants", s.AuthMiddleware(s.RateLimitByUser("info", s.GetVariantsHandler))).Methods("GET")
+	s.router.HandleFunc("/api/admin/merch/{item}/variants", s.AdminMiddleware(s.CreateVariantHandler)).Methods("POST")
+	s.router.HandleFunc("/api/admin/variants/{id:[0-9]+}", s.AdminMiddleware(s.UpdateVariantHandler)).Methods("PUT")
+	s.router.HandleFunc("/api/admin/merch/{item}", s.AdminMiddleware(s.UpdateItemDetailsHandler)).Methods("PUT")
+	s.router.HandleFunc("/api/auth", s.RateLimitByIP("auth", s.AuthHandler)).Methods("POST")
+	if s.grants != nil {
+		s.router.HandleFunc("/api/admin/grants", s.AdminMiddleware(s.CreateGrantsHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/grants", s.AdminMiddleware(s.GetGrantsHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/grants/{id:[0-9]+}", s.AdminMiddleware(s.GetGrantHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/grants/{id:[0-9]+}/approve", s.AdminMiddleware(s.ApproveGrantHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/grants/{id:[0-9]+}/reject", s.AdminMiddleware(s.RejectGrantHandler)).Methods("POST")
+	}
+	if s.scheduler != nil {
+		s.router.HandleFunc("/api/admin/schedules", s.AdminMiddleware(s.CreateScheduleHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/schedules", s.AdminMiddleware(s.GetSchedulesHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/schedules/{id:[0-9]+}", s.AdminMiddleware(s.GetScheduleHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/schedules/{id:[0-9]+}/pause", s.AdminMiddleware(s.PauseScheduleHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/schedules/{id:[0-9]+}/resume", s.AdminMiddleware(s.ResumeScheduleHandler)).Methods("POST")
+	}
+	if s.requests != nil {
+		s.router.HandleFunc("/api/coinRequests", s.AuthMiddleware(s.RateLimitByUser("coinRequests", s.CreateCoinRequestHandler))).Methods("POST")
+		s.router.HandleFunc("/api/coinRequests", s.AuthMiddleware(s.RateLimitByUser("transactions", s.GetCoinRequestsHandler))).Methods("GET")
+		s.router.HandleFunc("/api/coinRequests/{id:[0-9]+}/pay", s.AuthMiddleware(s.RateLimitByUser("sendCoin", s.PayCoinRequestHandler))).Methods("POST")
+		s.router.HandleFunc("/api/coinRequests/{id:[0-9]+}/reject", s.AuthMiddleware(s.RateLimitByUser("coinRequests", s.RejectCoinRequestHandler))).Methods("POST")
+	}
+	if s.pools != nil {
+		s.router.HandleFunc("/api/pools", s.AuthMiddleware(s.RateLimitByUser("pools", s.CreatePoolHandler))).Methods("POST")
+		s.router.HandleFunc("/api/pools", s.AuthMiddleware(s.RateLimitByUser("info", s.GetPoolsHandler))).Methods("GET")
+		s.router.HandleFunc("/api/pools/{id:[0-9]+}", s.AuthMiddleware(s.RateLimitByUser("info", s.GetPoolHandler))).Methods("GET")
+		s.router.HandleFunc("/api/pools/{id:[0-9]+}/contribute", s.AuthMiddleware(s.RateLimitByUser("pools", s.ContributeToPoolHandler))).Methods("POST")
+		s.router.HandleFunc("/api/pools/{id:[0-9]+}/cancel", s.AuthMiddleware(s.RateLimitByUser("pools", s.CancelPoolHandler))).Methods("POST")
+	}
+	if s.wishlists != nil {
+		s.router.HandleFunc("/api/wishlist", s.AuthMiddleware(s.RateLimitByUser("info", s.GetWishlistHandler))).Methods("GET")
+		s.router.HandleFunc("/api/wishlist/visibility", s.AuthMiddleware(s.RateLimitByUser("wishlist", s.SetWishlistVisibilityHandler))).Methods("PUT")
+		s.router.HandleFunc("/api/wishlist/items/{item}", s.AuthMiddleware(s.RateLimitByUser("wishlist", s.AddWishlistItemHandler))).Methods("PUT")
+		s.router.HandleFunc("/api/wishlist/items/{item}", s.AuthMiddleware(s.RateLimitByUser("wishlist", s.RemoveWishlistItemHandler))).Methods("DELETE")
+		s.router.HandleFunc("/api/users/{username}/wishlist", s.AuthMiddleware(s.RateLimitByUser("info", s.GetUserWishlistHandler))).Methods("GET")
+	}
+	if s.leaderboard != nil {
+		s.router.HandleFunc("/api/leaderboard", s.AuthMiddleware(s.RateLimitByUser("info", s.GetLeaderboardHandler))).Methods("GET")
+		s.router.HandleFunc("/api/leaderboard/optOut", s.AuthMiddleware(s.RateLimitByUser("info", s.SetLeaderboardOptOutHandler))).Methods("PUT")
+	}
+	if s.achievements != nil {
+		s.router.HandleFunc("/api/achievements", s.AuthMiddleware(s.RateLimitByUser("info", s.GetAchievementsHandler))).Methods("GET")
+	}
+	if s.discounts != nil {
+		s.router.HandleFunc("/api/admin/sales", s.AdminMiddleware(s.CreateSaleHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/sales", s.AdminMiddleware(s.GetSalesHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/sales/{id:[0-9]+}", s.AdminMiddleware(s.EndSaleHandler)).Methods("DELETE")
+		s.router.HandleFunc("/api/admin/promoCodes", s.AdminMiddleware(s.CreatePromoCodeHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/promoCodes", s.AdminMiddleware(s.GetPromoCodesHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/promoCodes/{code}", s.AdminMiddleware(s.DisablePromoCodeHandler)).Methods("DELETE")
+	}
+	if s.fulfillment != nil {
+		s.router.HandleFunc("/api/orders", s.AuthMiddleware(s.RateLimitByUser("info", s.GetOrdersHandler))).Methods("GET")
+		s.router.HandleFunc("/api/orders/{id:[0-9]+}/location", s.AuthMiddleware(s.RateLimitByUser("buy", s.ChooseLocationHandler))).Methods("PUT")
+		s.router.HandleFunc("/api/orders/{id:[0-9]+}/cancel", s.AuthMiddleware(s.RateLimitByUser("buy", s.CancelOrderHandler))).Methods("POST")
+		s.router.HandleFunc("/api/pickupLocations", s.AuthMiddleware(s.RateLimitByUser("info", s.GetPickupLocationsHandler))).Methods("GET")
+		s.router.HandleFunc("/api/fulfillment/orders", s.OfficeManagerMiddleware(s.GetFulfillmentQueueHandler)).Methods("GET")
+		s.router.HandleFunc("/api/fulfillment/orders/{id:[0-9]+}/ready", s.OfficeManagerMiddleware(s.MarkOrderReadyHandler)).Methods("POST")
+		s.router.HandleFunc("/api/fulfillment/orders/{id:[0-9]+}/deliver", s.OfficeManagerMiddleware(s.MarkOrderDeliveredHandler)).Methods("POST")
+		s.router.HandleFunc("/api/fulfillment/orders/{id:[0-9]+}/cancel", s.OfficeManagerMiddleware(s.CancelOrderByManagerHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/pickupLocations", s.AdminMiddleware(s.CreatePickupLocationHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/pickupLocations", s.AdminMiddleware(s.GetAllPickupLocationsHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/pickupLocations/{id:[0-9]+}", s.AdminMiddleware(s.DisablePickupLocationHandler)).Methods("DELETE")
+	}
+	if s.notifier != nil {
+		s.router.HandleFunc("/api/notifications", s.AuthMiddleware(s.RateLimitByUser("info", s.GetNotificationsHandler))).Methods("GET")
+		s.router.HandleFunc("/api/notifications/read", s.AuthMiddleware(s.RateLimitByUser("info", s.MarkNotificationsReadHandler))).Methods("POST")
+		s.router.HandleFunc("/api/notifications/preferences", s.AuthMiddleware(s.RateLimitByUser("info", s.GetNotificationPreferencesHandler))).Methods("GET")
+		s.router.HandleFunc("/api/notifications/preferences", s.AuthMiddleware(s.RateLimitByUser("info", s.SetNotificationPreferencesHandler))).Methods("PUT")
+	}
+	if s.sso != nil {
+		s.router.HandleFunc("/api/auth/sso/login", s.RateLimitByIP("auth", s.SSOLoginHandler)).Methods("GET")
+		s.router.HandleFunc("/api/auth/sso/callback", s.RateLimitByIP("auth", s.SSOCallbackHandler)).Methods("GET")
+	}
+	if s.apiKeys != nil {
+		s.router.HandleFunc("/api/admin/apiKeys", s.AdminMiddleware(s.CreateAPIKeyHandler)).Methods("POST")
+		s.router.HandleFunc("/api/admin/apiKeys", s.AdminMiddleware(s.GetAPIKeysHandler)).Methods("GET")
+		s.router.HandleFunc("/api/admin/apiKeys/{id:[0-9]+}", s.AdminMiddleware(s.RevokeAPIKeyHandler)).Methods("DELETE")
+		s.router.HandleFunc("/api/integrations/users/{username}", s.APIKeyMiddleware(model.ScopeUsersRead, s.RateLimitByUser("integrations", s.GetUserBalanceHandler))).Methods("GET")
+		if s.grants != nil {
+			s.router.HandleFunc("/api/integrations/grants", s.APIKeyMiddleware(model.ScopeGrantsWrite, s.RateLimitByUser("integrations", s.CreateGrantsHandler))).Methods("POST")
+			s.router.HandleFunc("/api/integrations/grants/{id:[0-9]+}", s.APIKeyMiddleware(model.ScopeGrantsRead, s.RateLimitByUser("integrations", s.GetGrantHandler))).Methods("GET")
+		}
+	}
+	if s.live != nil {
+		s.router.HandleFunc("/api/stream", s.AuthMiddleware(s.RateLimitByUser("info", s.StreamHandler))).Methods("GET")
+	}
+	if s.images != nil {
+		s.router.HandleFunc("/api/admin/merch/{item}/image", s.AdminMiddleware(s.UploadItemImageHandler)).Methods("PUT")
+		s.router.PathPrefix(s.images.Prefix()).Handler(s.images.Handler()).Methods("GET")
+	}
+	if s.health != nil {
+		s.router.HandleFunc("/healthz", s.LivenessHandler).Methods("GET")
+		s.router.HandleFunc("/readyz", s.ReadinessHandler).Methods("GET")
+	}
 }
 
 func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -65,11 +349,15 @@ func (s *Service) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		_, span := tracing.Start(r.Context(), "auth.ValidateKey")
 		key, err := s.auth.ValidateKey(tokenString)
+		tracing.End(span, err)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		setUsername(r.Context(), key)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", key))
 		ctx := context.WithValue(r.Context(), "name", key)
 		next(w, r.WithContext(ctx))
 	}
@@ -77,7 +365,7 @@ func (s *Service) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 func (s *Service) GetInfoHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	info, err := s.merch.GetInfoByUsername(ctx.Value("name").(string))
+	info, err := s.merch.GetInfoByUsername(ctx, ctx.Value("name").(string))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
 		return
@@ -88,7 +376,7 @@ func (s *Service) GetInfoHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Service) GetTransactionsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	username := ctx.Value("name").(string)
-	transactions, err := s.merch.GetTransactions(username)
+	transactions, err := s.merch.GetTransactions(ctx, username)
 	if errors.Is(err, storage.ErrUserNotFound) {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 	}
@@ -98,6 +386,36 @@ func (s *Service) GetTransactionsHandler(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusOK, transactions)
 }
 
+// sendCoinErrors maps merchant errors of SendCoin to response statuses and error codes.
+var sendCoinErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{merchant.ErrNotEnoughCoins, http.StatusBadRequest, "not_enough_coins"},
+	{merchant.ErrIncorrectCount, http.StatusBadRequest, "incorrect_amount"},
+	{merchant.ErrTransferTooLarge, http.StatusBadRequest, "transfer_too_large"},
+	{merchant.ErrDailyLimitExceeded, http.StatusForbidden, "daily_limit_exceeded"},
+	{merchant.ErrWeeklyLimitExceeded, http.StatusForbidden, "weekly_limit_exceeded"},
+	{merchant.ErrRecipientLimitExceeded, http.StatusForbidden, "recipient_limit_exceeded"},
+	{merchant.ErrAccountTooNew, http.StatusForbidden, "account_too_new"},
+}
+
+// respondWithSendError writes the response for a failed transfer.
+func respondWithSendError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrUserNotFound) {
+		respondWithErrorCode(w, http.StatusBadRequest, "user_not_found", "user not found")
+		return
+	}
+	for _, e := range sendCoinErrors {
+		if errors.Is(err, e.err) {
+			respondWithErrorCode(w, e.status, e.code, err.Error())
+			return
+		}
+	}
+	respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+}
+
 func (s *Service) SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	body, err := io.ReadAll(r.Body)
@@ -124,21 +442,13 @@ func (s *Service) SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "you can't send money for yourself")
 		return
 	}
-	err = s.merch.SendCoin(ctx.Value("name").(string), requestData.ToUser, requestData.Amount)
-	if errors.Is(err, storage.ErrUserNotFound) {
-		respondWithError(w, http.StatusBadRequest, "user not found")
-		return
-	}
-	if errors.Is(err, merchant.ErrNotEnoughCoins) {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-	if errors.Is(err, merchant.ErrIncorrectCount) {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+	transfer, err := s.merch.SendCoin(ctx, ctx.Value("name").(string), requestData.ToUser, requestData.Amount)
+	if err != nil {
+		respondWithSendError(w, err)
 		return
 	}
-	if err != nil {
-		respondWithJSON(w, http.StatusInternalServerError, sww(err.Error()))
+	if transfer.Status == model.TransactionPending {
+		respondWithJSON(w, http.StatusAccepted, transfer)
 		return
 	}
 	respondWithJSON(w, http.StatusOK, nil)
@@ -148,11 +458,24 @@ func (s *Service) BuyItemHandler(w http.ResponseWriter, r *http.Request) {
 	item := mux.Vars(r)["item"]
 	ctx := r.Context()
 
-	err := s.merch.Buy(ctx.Value("name").(string), item)
-	if errors.Is(err, merchant.ErrNotEnoughCoins) {
+	query := r.URL.Query()
+	err := s.merch.Buy(ctx, ctx.Value("name").(string), item, query.Get("size"), query.Get("color"),
+		query.Get("promo"))
+	if errors.Is(err, merchant.ErrNotEnoughCoins) || errors.Is(err, merchant.ErrVariantRequired) ||
+		errors.Is(err, merchant.ErrVariantNotFound) {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if errors.Is(err, merchant.ErrOutOfStock) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	for _, e := range promoCodeErrors {
+		if errors.Is(err, e.err) {
+			respondWithErrorCode(w, http.StatusBadRequest, e.code, err.Error())
+			return
+		}
+	}
 	if errors.Is(err, storage.ErrMerchNotFound) {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -167,40 +490,21 @@ func (s *Service) BuyItemHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Service) AuthHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	var req model.AuthRequestWeb
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil || req.Username == "" || req.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "username or password is empty or fields is not correct")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, auth.ErrEmptyCredentials.Error())
 		return
 	}
-	if !s.storage.CheckContains(req.Username) {
-		hash, err := s.auth.HashPassword(req.Password)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
-		}
-		err = s.storage.AddUser(req.Username, hash)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
-			return
-		}
-		err = s.merch.AddUser(req.Username)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
-			return
-		}
-	} else {
-		hash, err := s.storage.GetUserHash(req.Username)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "user does not exist")
-			return
-		}
-		if !s.auth.CheckPassword(hash, req.Password) {
-			respondWithError(w, http.StatusUnauthorized, "password is not correct")
-			return
-		}
+	key, err := auth.Login(ctx, s.auth, s.storage, s.merch.AddUser, req.Username, req.Password)
+	if errors.Is(err, auth.ErrEmptyCredentials) || errors.Is(err, auth.ErrReservedUsername) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, auth.ErrUnknownUser) || errors.Is(err, auth.ErrWrongPassword) {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
 	}
-
-	key, err := s.auth.GenerateKey(req.Username)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
 		return
@@ -217,7 +521,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	if payload != nil {
 		err := json.NewEncoder(w).Encode(payload)
 		if err != nil {
-			log.Println(err)
+			slog.Error("cannot encode response", "error", err)
 		}
 	}
 }
@@ -226,6 +530,11 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, model.ErrorResponseWeb{Errors: message})
 }
 
+// respondWithErrorCode adds a machine readable errorCode to the error response.
+func respondWithErrorCode(w http.ResponseWriter, code int, errorCode string, message string) {
+	respondWithJSON(w, code, model.ErrorResponseWeb{Errors: message, Code: errorCode})
+}
+
 func sww(e string) string {
 	return "something went wrong:\n" + e
 }
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/wishlist"
+	"avito-merch-store/model"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type VisibilityRequest struct {
+	Visibility string `json:"visibility"`
+}
+
+func (s *Service) GetWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	list, err := s.wishlists.Get(ctx, ctx.Value("name").(string))
+	respondWithWishlist(w, list, err)
+}
+
+func (s *Service) GetUserWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := s.wishlists.GetPublic(r.Context(), mux.Vars(r)["username"])
+	respondWithWishlist(w, list, err)
+}
+
+func (s *Service) AddWishlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	list, err := s.wishlists.Add(ctx, ctx.Value("name").(string), mux.Vars(r)["item"])
+	respondWithWishlist(w, list, err)
+}
+
+func (s *Service) RemoveWishlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	list, err := s.wishlists.Remove(ctx, ctx.Value("name").(string), mux.Vars(r)["item"])
+	respondWithWishlist(w, list, err)
+}
+
+func (s *Service) SetWishlistVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req VisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "error: invalid JSON format")
+		return
+	}
+	list, err := s.wishlists.SetVisibility(ctx, ctx.Value("name").(string), req.Visibility)
+	respondWithWishlist(w, list, err)
+}
+
+func respondWithWishlist(w http.ResponseWriter, list *model.Wishlist, err error) {
+	switch {
+	case err == nil:
+		respondWithJSON(w, http.StatusOK, list)
+	case errors.Is(err, wishlist.ErrWishlistNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrMerchNotFound), errors.Is(err, wishlist.ErrInvalidVisibility):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, sww(err.Error()))
+	}
+}
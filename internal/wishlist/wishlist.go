@@ -0,0 +1,81 @@
+package wishlist
+
+import (
+	"avito-merch-store/internal/storage"
+	"avito-merch-store/internal/tracing"
+	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidVisibility = fmt.Errorf("visibility must be private or public")
+var ErrWishlistNotFound = fmt.Errorf("wishlist not found")
+
+// Manager keeps the wishlists of users. Private wishlists are visible to their owners only.
+type Manager struct {
+	wishlists storage.WishlistStorage
+}
+
+func CreateManager(wishlists storage.WishlistStorage) *Manager {
+	return &Manager{wishlists}
+}
+
+func (m *Manager) Get(ctx context.Context, username string) (_ *model.Wishlist, err error) {
+	ctx, span := tracing.Start(ctx, "Wishlist.Get")
+	defer func() { tracing.End(span, err) }()
+
+	return m.wishlists.GetWishlist(ctx, username)
+}
+
+// GetPublic returns the wishlist of owner as seen by other users. Private wishlists
+// are reported as missing so that they can't be told apart from unknown users.
+func (m *Manager) GetPublic(ctx context.Context, owner string) (_ *model.Wishlist, err error) {
+	ctx, span := tracing.Start(ctx, "Wishlist.GetPublic")
+	defer func() { tracing.End(span, err) }()
+
+	wishlist, err := m.wishlists.GetWishlist(ctx, owner)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, ErrWishlistNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if wishlist.Visibility != model.WishlistPublic {
+		return nil, ErrWishlistNotFound
+	}
+	return wishlist, nil
+}
+
+func (m *Manager) Add(ctx context.Context, username string, item string) (_ *model.Wishlist, err error) {
+	ctx, span := tracing.Start(ctx, "Wishlist.Add")
+	defer func() { tracing.End(span, err) }()
+
+	if err = m.wishlists.AddItem(ctx, username, item); err != nil {
+		return nil, err
+	}
+	return m.wishlists.GetWishlist(ctx, username)
+}
+
+func (m *Manager) Remove(ctx context.Context, username string, item string) (_ *model.Wishlist, err error) {
+	ctx, span := tracing.Start(ctx, "Wishlist.Remove")
+	defer func() { tracing.End(span, err) }()
+
+	if err = m.wishlists.RemoveItem(ctx, username, item); err != nil {
+		return nil, err
+	}
+	return m.wishlists.GetWishlist(ctx, username)
+}
+
+func (m *Manager) SetVisibility(ctx context.Context, username string, visibility string) (_ *model.Wishlist, err error) {
+	ctx, span := tracing.Start(ctx, "Wishlist.SetVisibility")
+	defer func() { tracing.End(span, err) }()
+
+	if visibility != model.WishlistPrivate && visibility != model.WishlistPublic {
+		return nil, ErrInvalidVisibility
+	}
+	if err = m.wishlists.SetVisibility(ctx, username, visibility); err != nil {
+		return nil, err
+	}
+	return m.wishlists.GetWishlist(ctx, username)
+}
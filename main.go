@@ -1,23 +1,90 @@
 package main
 
 import (
+	"avito-merch-store/internal/achievements"
+	"avito-merch-store/internal/apikeys"
 	"avito-merch-store/internal/auth"
+	"avito-merch-store/internal/coinrequests"
+	"avito-merch-store/internal/discounts"
+	"avito-merch-store/internal/events"
+	"avito-merch-store/internal/expiry"
+	"avito-merch-store/internal/fulfillment"
+	"avito-merch-store/internal/grants"
+	"avito-merch-store/internal/health"
+	"avito-merch-store/internal/images"
+	"avito-merch-store/internal/leaderboard"
+	"avito-merch-store/internal/live"
+	"avito-merch-store/internal/logging"
 	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/metrics"
+	"avito-merch-store/internal/notify"
+	"avito-merch-store/internal/pools"
+	"avito-merch-store/internal/ratelimit"
+	"avito-merch-store/internal/rpc"
+	"avito-merch-store/internal/scheduler"
+	"avito-merch-store/internal/sso"
 	"avito-merch-store/internal/storage"
 	"avito-merch-store/internal/storage/postgres"
+	"avito-merch-store/internal/tracing"
 	"avito-merch-store/internal/web"
+	"avito-merch-store/internal/wishlist"
 	"avito-merch-store/model"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 func StartServer(addr string) {
 
 }
 
-func runServer(ptx string,
+// shutdownDelay is how long the server keeps serving after readiness turns off,
+// giving load balancers time to notice before connections are closed.
+var shutdownDelay = 5 * time.Second
+
+// rateLimits are the per-route policies. Authentication is limited by client IP
+// because bcrypt is expensive, the other routes are limited by username.
+var rateLimits = map[string]ratelimit.Policy{
+	"auth":         ratelimit.PerMinute(10, 5),
+	"sendCoin":     ratelimit.PerMinute(30, 10),
+	"buy":          ratelimit.PerMinute(30, 10),
+	"info":         ratelimit.PerMinute(120, 30),
+	"transactions": ratelimit.PerMinute(120, 30),
+	"coinRequests": ratelimit.PerMinute(30, 10),
+	"pools":        ratelimit.PerMinute(30, 10),
+	"wishlist":     ratelimit.PerMinute(60, 20),
+	"integrations": ratelimit.PerMinute(300, 60),
+}
+
+// createRateLimiter builds the limiter selected by backend: "memory" (default),
+// "postgres" for multi-instance deployments or "off". A nil limiter disables limiting.
+func createRateLimiter(backend string, ptx string) (ratelimit.Limiter, error) {
+	switch backend {
+	case "", "memory":
+		return ratelimit.CreateMemoryLimiter(), nil
+	case "postgres":
+		return postgres.CreateRateLimitStoragePostgres(ptx)
+	case "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", backend)
+	}
+}
+
+func runServer(ctx context.Context,
+	ptx string,
+	migrations string,
 	port string,
 	au auth.Authenticator,
 	stor storage.AuthStorage,
@@ -25,18 +92,452 @@ func runServer(ptx string,
 	inventory storage.InventoryStorage,
 	transaction storage.TransactionStorage,
 	merch storage.MerchStorage,
-	wg1 *sync.WaitGroup) {
+	wg1 *sync.WaitGroup) error {
 	// /internal/storage/postgres/migrations
 
-	service := web.NewService(stor, au,
-		merchant.CreateMerchant(users, inventory, transaction, merch))
+	checker := health.NewChecker(2 * time.Second)
+	components := []struct {
+		name    string
+		storage any
+	}{
+		{"auth", stor},
+		{"users", users},
+		{"inventory", inventory},
+		{"transactions", transaction},
+		{"merch", merch},
+	}
+	for _, c := range components {
+		if p, ok := c.storage.(health.Pinger); ok {
+			checker.Add(c.name, p.Ping)
+		}
+	}
+	status, err := postgres.CreateMigrationStatusPostgres(ptx, migrations)
+	if err != nil {
+		return err
+	}
+	checker.Add("postgres", status.Ping)
+	checker.Add("migrations", status.Check)
+
+	limiter, err := createRateLimiter(os.Getenv("RATE_LIMIT_BACKEND"), ptx)
+	if err != nil {
+		return err
+	}
+	if p, ok := limiter.(health.Pinger); ok {
+		checker.Add("rate_limits", p.Ping)
+	}
+	if buckets, ok := limiter.(ratelimit.IdleBuckets); ok {
+		sweep, err := time.ParseDuration(getEnv("RATE_LIMIT_SWEEP_INTERVAL", "10m"))
+		if err != nil || sweep <= 0 {
+			return fmt.Errorf("invalid RATE_LIMIT_SWEEP_INTERVAL %q", os.Getenv("RATE_LIMIT_SWEEP_INTERVAL"))
+		}
+		go ratelimit.CreateSweeper(buckets, ratelimit.RefillTime(rateLimits), sweep).Run(ctx)
+	}
+
+	grantStorage, err := postgres.CreateGrantStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("grants", grantStorage.Ping)
+	threshold, err := strconv.Atoi(getEnv("GRANT_APPROVAL_THRESHOLD", "10000"))
+	if err != nil {
+		return fmt.Errorf("invalid GRANT_APPROVAL_THRESHOLD: %w", err)
+	}
+
+	scheduleStorage, err := postgres.CreateScheduleStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("schedules", scheduleStorage.Ping)
+	interval, err := time.ParseDuration(getEnv("SCHEDULER_INTERVAL", "1m"))
+	if err != nil {
+		return fmt.Errorf("invalid SCHEDULER_INTERVAL: %w", err)
+	}
+	go scheduler.CreateScheduler(scheduleStorage, interval).Run(ctx)
+
+	lotStorage, err := postgres.CreateLotStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	policies, err := expiry.ParsePolicies(os.Getenv("COIN_EXPIRY"))
+	if err != nil {
+		return err
+	}
+	err = lotStorage.SetExpiryPolicies(ctx, policies)
+	if err != nil {
+		return err
+	}
+	go expiry.CreateExpirer(lotStorage, interval).Run(ctx)
+
+	limits, err := transferLimits()
+	if err != nil {
+		return err
+	}
+
+	achievementStorage, err := postgres.CreateAchievementStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("achievements", achievementStorage.Ping)
+	rewards, err := achievements.ParseRewards(os.Getenv("ACHIEVEMENT_REWARDS"))
+	if err != nil {
+		return err
+	}
+
+	tracker := achievements.CreateTracker(achievementStorage, rewards)
+
+	discountStorage, err := postgres.CreateDiscountStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("discounts", discountStorage.Ping)
+	pricing := discounts.CreateManager(discountStorage)
+
+	notificationStorage, err := postgres.CreateNotificationStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("notifications", notificationStorage.Ping)
+	channels := []notify.Channel{notify.CreateWebhook(10 * time.Second)}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		channels = append(channels, notify.CreateEmail(addr, getEnv("SMTP_FROM", "merch-store@localhost"),
+			os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), 10*time.Second))
+	}
+	notifier := notify.CreateNotifier(notificationStorage, channels...)
+	dispatcher, err := notificationDispatcher(ptx, channels)
+	if err != nil {
+		return err
+	}
+	go dispatcher.Run(ctx)
+
+	outboxStorage, err := postgres.CreateOutboxStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("outbox", outboxStorage.Ping)
+	relay, err := eventRelay(ptx)
+	if err != nil {
+		return err
+	}
+	go relay.Run(ctx)
+
+	updateStorage, err := postgres.CreateUpdateStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("updates", updateStorage.Ping)
+	listener, err := postgres.CreateUpdateStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	hub := live.CreateHub(updateStorage, listener)
+	go hub.Run(ctx)
+
+	merchantOpts := []merchant.Option{
+		merchant.WithTransferLimits(limits),
+		merchant.WithAchievements(tracker),
+		merchant.WithPricing(pricing),
+		merchant.WithNotifier(notifier),
+		merchant.WithUpdates(hub),
+	}
+	switch mode := getEnv("TRANSFER_MODE", "instant"); mode {
+	case "instant":
+	case "pending":
+		timeout, err := time.ParseDuration(getEnv("PENDING_TRANSFER_TIMEOUT", "72h"))
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid PENDING_TRANSFER_TIMEOUT %q", os.Getenv("PENDING_TRANSFER_TIMEOUT"))
+		}
+		merchantOpts = append(merchantOpts, merchant.WithPendingTransfers(timeout))
+	default:
+		return fmt.Errorf("unknown transfer mode %q", mode)
+	}
+	// transfers left pending from an earlier pending mode run are reverted in any mode
+	revertStorage, err := postgres.CreateTransactionStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	go merchant.CreatePendingReverter(revertStorage, interval).Run(ctx)
+
+	merchantService := merchant.CreateMerchant(users, inventory, transaction, merch, merchantOpts...)
+
+	requestStorage, err := postgres.CreateCoinRequestStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("coin_requests", requestStorage.Ping)
+	requestTTL, err := time.ParseDuration(getEnv("COIN_REQUEST_TTL", "168h"))
+	if err != nil || requestTTL <= 0 {
+		return fmt.Errorf("invalid COIN_REQUEST_TTL %q", os.Getenv("COIN_REQUEST_TTL"))
+	}
+	requestJobStorage, err := postgres.CreateCoinRequestStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	go coinrequests.CreateManager(requestJobStorage, nil, requestTTL, interval).Run(ctx)
+
+	poolStorage, err := postgres.CreatePoolStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("pools", poolStorage.Ping)
+	poolTTL, err := time.ParseDuration(getEnv("POOL_TTL", "336h"))
+	if err != nil || poolTTL <= 0 {
+		return fmt.Errorf("invalid POOL_TTL %q", os.Getenv("POOL_TTL"))
+	}
+	poolJobStorage, err := postgres.CreatePoolStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	go pools.CreateManager(poolJobStorage, poolTTL, interval).Run(ctx)
+
+	wishlistStorage, err := postgres.CreateWishlistStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("wishlists", wishlistStorage.Ping)
+
+	leaderboardStorage, err := postgres.CreateLeaderboardStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("leaderboards", leaderboardStorage.Ping)
+	refresh, err := time.ParseDuration(getEnv("LEADERBOARD_REFRESH_INTERVAL", "5m"))
+	if err != nil || refresh <= 0 {
+		return fmt.Errorf("invalid LEADERBOARD_REFRESH_INTERVAL %q", os.Getenv("LEADERBOARD_REFRESH_INTERVAL"))
+	}
+	refreshStorage, err := postgres.CreateLeaderboardStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	go leaderboard.CreateManager(refreshStorage, refresh).Run(ctx)
+
+	orderStorage, err := postgres.CreateOrderStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("orders", orderStorage.Ping)
+
+	apiKeyStorage, err := postgres.CreateAPIKeyStoragePostgres(ptx)
+	if err != nil {
+		return err
+	}
+	checker.Add("api_keys", apiKeyStorage.Ping)
+	ssoManager, err := singleSignOn(ctx, ptx, stor, au, merchantService.AddUser)
+	if err != nil {
+		return err
+	}
+
+	imageStore, err := images.CreateStore(getEnv("IMAGE_DIR", "data/images"), "/images/")
+	if err != nil {
+		return err
+	}
+
+	service := web.NewService(stor, au, merchantService,
+		web.WithHealth(checker),
+		web.WithRateLimit(limiter, rateLimits),
+		web.WithAdmins(splitList(os.Getenv("ADMIN_USERS"))),
+		web.WithGrants(grants.CreateGranter(grantStorage, threshold, grants.WithNotifier(notifier))),
+		web.WithScheduler(scheduler.CreateScheduler(scheduleStorage, interval)),
+		web.WithCoinRequests(coinrequests.CreateManager(requestStorage, &merchantService, requestTTL, interval)),
+		web.WithPools(pools.CreateManager(poolStorage, poolTTL, interval)),
+		web.WithWishlists(wishlist.CreateManager(wishlistStorage)),
+		web.WithLeaderboard(leaderboard.CreateManager(leaderboardStorage, refresh)),
+		web.WithAchievements(tracker),
+		web.WithDiscounts(pricing),
+		web.WithImages(imageStore),
+		web.WithFulfillment(fulfillment.CreateManager(orderStorage, fulfillment.WithNotifier(notifier)),
+			splitList(os.Getenv("OFFICE_MANAGERS"))),
+		web.WithNotifications(notifier),
+		web.WithLive(hub),
+		web.WithAPIKeys(apikeys.CreateManager(apiKeyStorage)),
+		web.WithSSO(ssoManager))
+	server := &http.Server{Addr: ":" + port, Handler: service}
+
+	// the gRPC API shares the merchant with the JSON API, it listens on a port of its own
+	grpcListener, err := net.Listen("tcp", ":"+getEnv("GRPC_PORT", "9090"))
+	if err != nil {
+		return err
+	}
+	grpcServer := rpc.NewServer(stor, au, &merchantService)
+
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	go func() {
+		serveErr <- grpcServer.Serve(grpcListener)
+	}()
 	wg1.Done()
-	log.Fatal(http.ListenAndServe(":"+port, service))
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "delay", shutdownDelay)
+	checker.SetShuttingDown()
+	time.Sleep(shutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	go func() {
+		<-shutdownCtx.Done()
+		grpcServer.Stop()
+	}()
+	grpcServer.GracefulStop()
+	return server.Shutdown(shutdownCtx)
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// notificationDispatcher reads the retry policy of notification deliveries. The dispatcher
+// gets its own storage, connections are not shared between goroutines.
+func notificationDispatcher(ptx string, channels []notify.Channel) (*notify.Dispatcher, error) {
+	interval, err := time.ParseDuration(getEnv("NOTIFY_INTERVAL", "10s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOTIFY_INTERVAL: %w", err)
+	}
+	attempts, err := strconv.Atoi(getEnv("NOTIFY_MAX_ATTEMPTS", "5"))
+	if err != nil || attempts < 1 {
+		return nil, fmt.Errorf("invalid NOTIFY_MAX_ATTEMPTS %q", os.Getenv("NOTIFY_MAX_ATTEMPTS"))
+	}
+	backoff, err := time.ParseDuration(getEnv("NOTIFY_BACKOFF", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOTIFY_BACKOFF: %w", err)
+	}
+	deliveries, err := postgres.CreateNotificationStoragePostgres(ptx)
+	if err != nil {
+		return nil, err
+	}
+	return notify.CreateDispatcher(deliveries, interval, attempts, backoff, channels...), nil
+}
+
+// eventRelay configures the sinks of domain events. Without sinks events are only
+// kept in the outbox until the retention is over.
+func eventRelay(ptx string) (*events.Relay, error) {
+	interval, err := time.ParseDuration(getEnv("EVENTS_RELAY_INTERVAL", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENTS_RELAY_INTERVAL: %w", err)
+	}
+	backoff, err := time.ParseDuration(getEnv("EVENTS_RETRY_BACKOFF", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENTS_RETRY_BACKOFF: %w", err)
+	}
+	retention, err := time.ParseDuration(getEnv("EVENTS_RETENTION", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENTS_RETENTION: %w", err)
+	}
+	var sinks []events.Sink
+	if url := os.Getenv("EVENTS_HTTP_URL"); url != "" {
+		sinks = append(sinks, events.CreateHTTPSink(url, os.Getenv("EVENTS_HTTP_TOKEN"), 10*time.Second))
+	}
+	if path := os.Getenv("EVENTS_FILE"); path != "" {
+		sinks = append(sinks, events.CreateFileSink(path))
+	}
+	outbox, err := postgres.CreateOutboxStoragePostgres(ptx)
+	if err != nil {
+		return nil, err
+	}
+	return events.CreateRelay(outbox, interval, backoff, retention, sinks...), nil
+}
+
+// singleSignOn configures the OpenID Connect login, it is disabled without OIDC_ISSUER.
+// OIDC_MOCK_ADDR serves a mock provider for local runs, its issuer is http://<that address>.
+func singleSignOn(ctx context.Context, ptx string, users storage.AuthStorage, au auth.Authenticator,
+	register func(ctx context.Context, username string) error) (*sso.Manager, error) {
+	if addr := os.Getenv("OIDC_MOCK_ADDR"); addr != "" {
+		mock, err := sso.NewMockProvider(os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"))
+		if err != nil {
+			return nil, err
+		}
+		server := &http.Server{Addr: addr, Handler: mock}
+		go func() {
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("mock identity provider stopped", "error", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			_ = server.Close()
+		}()
+	}
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	redirect := os.Getenv("OIDC_REDIRECT_URL")
+	if os.Getenv("OIDC_CLIENT_ID") == "" || redirect == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+	}
+	logins, err := postgres.CreateSSOStoragePostgres(ptx)
+	if err != nil {
+		return nil, err
+	}
+	provider := sso.CreateProvider(sso.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  redirect,
+	})
+	return sso.CreateManager(provider, logins, users, au, register), nil
+}
+
+// transferLimits reads SendCoin policies, unset variables disable the check.
+func transferLimits() (merchant.TransferLimits, error) {
+	var limits merchant.TransferLimits
+	ints := []struct {
+		key   string
+		value *int
+	}{
+		{"TRANSFER_MAX_AMOUNT", &limits.MaxPerTransfer},
+		{"TRANSFER_DAILY_CAP", &limits.DailyCap},
+		{"TRANSFER_WEEKLY_CAP", &limits.WeeklyCap},
+		{"TRANSFER_RECIPIENT_DAILY_MAX", &limits.MaxPerRecipientDaily},
+	}
+	for _, v := range ints {
+		n, err := strconv.Atoi(getEnv(v.key, "0"))
+		if err != nil {
+			return limits, fmt.Errorf("invalid %s: %w", v.key, err)
+		}
+		*v.value = n
+	}
+	age, err := time.ParseDuration(getEnv("TRANSFER_MIN_ACCOUNT_AGE", "0"))
+	if err != nil {
+		return limits, fmt.Errorf("invalid TRANSFER_MIN_ACCOUNT_AGE: %w", err)
+	}
+	limits.MinAccountAge = age
+	return limits, nil
+}
+
+func splitList(value string) []string {
+	var res []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
 }
 
 func main() {
+	slog.SetDefault(logging.New(os.Stdout, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL")))
 	ptx := os.Getenv("POSTGRES_PATH")
 
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("TRACE_EXPORTER"), "avito-merch-store")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("cannot shutdown tracing", "error", err)
+		}
+	}()
+
 	items := []model.Item{
 		{"t-shirt", 80},
 		{"cup", 20},
@@ -50,7 +551,11 @@ func main() {
 		{"pink-hoody", 500},
 	}
 
-	err := postgres.DownMigrations(ptx, "/migrations")
+	// dropping the schema on every start would also drop the scheduler state,
+	// so it is only done on request
+	if os.Getenv("RESET_DATABASE") == "true" {
+		err = postgres.DownMigrations(ptx, "/migrations")
+	}
 	err = postgres.UpMigrations(ptx, "/migrations")
 
 	stor, err := postgres.CreateAuthStoragePostgres(ptx)
@@ -66,8 +571,25 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	supply, err := postgres.CreateUserStoragePostgres(ptx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	metrics.RegisterCoinSupply(func() (int, error) {
+		return supply.TotalCoins(context.Background())
+	})
+	if delay, err := time.ParseDuration(os.Getenv("SHUTDOWN_DELAY")); err == nil {
+		shutdownDelay = delay
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	var wg1 sync.WaitGroup
 	wg1.Add(1)
-	log.Printf("Starting test server on :8080")
-	runServer(ptx, "8080", au, stor, a, b, c, d, &wg1)
+	slog.Info("starting server", "port", "8080")
+	err = runServer(ctx, ptx, "/migrations", "8080", au, stor, a, b, c, d, &wg1)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server stopped", "error", err)
+	}
 }
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Achievement is awarded once per user, Reward coins are credited with it.
+type Achievement struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Reward      int    `json:"reward,omitempty"`
+}
+
+type Award struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	AwardedAt time.Time `json:"awardedAt"`
+}
+
+// ActivityStats is what achievement rules are evaluated against.
+type ActivityStats struct {
+	Purchases          int
+	DistinctItems      int
+	CatalogSize        int
+	DistinctRecipients int
+	ReceivedCoins      int
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	ScopeGrantsWrite = "grants:write"
+	ScopeGrantsRead  = "grants:read"
+	ScopeUsersRead   = "users:read"
+)
+
+// Scopes lists every scope an API key can be given.
+var Scopes = []string{ScopeGrantsWrite, ScopeGrantsRead, ScopeUsersRead}
+
+// APIKey is a credential of an integration. The key itself is shown once at creation,
+// Prefix identifies it afterwards.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	CoinRequestPending  = "pending"
+	CoinRequestPaid     = "paid"
+	CoinRequestRejected = "rejected"
+	CoinRequestExpired  = "expired"
+)
+
+// CoinRequest asks Payer to send Amount coins to Requester.
+type CoinRequest struct {
+	ID         int        `json:"id"`
+	Requester  string     `json:"requester"`
+	Payer      string     `json:"payer"`
+	Amount     int        `json:"amount"`
+	Note       string     `json:"note,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
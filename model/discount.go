@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+// Sale lowers the price of Item, or of every item when Item is empty, between StartsAt and EndsAt.
+type Sale struct {
+	ID        int       `json:"id"`
+	Item      string    `json:"item,omitempty"`
+	Kind      string    `json:"kind"`
+	Value     int       `json:"value"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PromoCode lowers the price of one purchase. MaxUses limits redemptions in total
+// and MaxUsesPerUser per user, zero means unlimited.
+type PromoCode struct {
+	Code           string     `json:"code"`
+	Item           string     `json:"item,omitempty"`
+	Kind           string     `json:"kind"`
+	Value          int        `json:"value"`
+	MaxUses        int        `json:"maxUses"`
+	MaxUsesPerUser int        `json:"maxUsesPerUser"`
+	Uses           int        `json:"uses"`
+	StartsAt       time.Time  `json:"startsAt"`
+	EndsAt         *time.Time `json:"endsAt,omitempty"`
+	CreatedBy      string     `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Purchase is a bought item with the price actually paid.
+type Purchase struct {
+	Username      string `json:"username"`
+	Item          string `json:"item"`
+	OriginalPrice int    `json:"originalPrice"`
+	Price         int    `json:"price"`
+	Discount      int    `json:"discount"`
+	PromoCode     string `json:"promoCode,omitempty"`
+	VariantID     int    `json:"variantId,omitempty"`
+}
+
+// DiscountOf returns how many coins a discount of kind and value takes off price.
+func DiscountOf(kind string, value int, price int) int {
+	off := value
+	if kind == DiscountPercent {
+		off = price * value / 100
+	}
+	return min(off, price)
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventUserRegistered   = "UserRegistered"
+	EventCoinsTransferred = "CoinsTransferred"
+	EventCoinsReserved    = "CoinsReserved"
+	EventItemPurchased    = "ItemPurchased"
+)
+
+// Event is a domain event as published to sinks. Events may be published more
+// than once, consumers deduplicate them by ID. Sequence orders the events.
+type Event struct {
+	ID        string          `json:"id"`
+	Sequence  int64           `json:"sequence"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Attempts  int             `json:"-"`
+}
+
+type UserRegistered struct {
+	Username string `json:"username"`
+	Coins    int    `json:"coins"`
+}
+
+type CoinsTransferred struct {
+	TransferID int    `json:"transferId"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Amount     int    `json:"amount"`
+}
+
+// CoinsReserved is published when a pending transfer takes the amount from the
+// sender, CoinsTransferred follows once the receiver accepts it.
+type CoinsReserved struct {
+	TransferID int       `json:"transferId"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Amount     int       `json:"amount"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// ItemPurchased is published for purchases by users and for completed pools,
+// Pooled purchases are paid by the contributors of the pool.
+type ItemPurchased struct {
+	PurchaseID    int    `json:"purchaseId"`
+	Username      string `json:"username"`
+	Item          string `json:"item"`
+	VariantID     int    `json:"variantId,omitempty"`
+	Price         int    `json:"price"`
+	OriginalPrice int    `json:"originalPrice"`
+	PromoCode     string `json:"promoCode,omitempty"`
+	Pooled        bool   `json:"pooled,omitempty"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// SystemUser is the sender of coins issued by the store itself
+// and the receiver of revoked coins.
+const SystemUser = "system"
+
+const (
+	GrantPendingApproval = "pending_approval"
+	GrantApproved        = "approved"
+	GrantApplied         = "applied"
+	GrantFailed          = "failed"
+	GrantRejected        = "rejected"
+)
+
+// GrantItem credits Amount coins to Username, a negative amount revokes coins.
+type GrantItem struct {
+	ID       int    `json:"-"`
+	Username string `json:"username"`
+	Amount   int    `json:"amount"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error,omitempty"`
+}
+
+type GrantBatch struct {
+	ID         int         `json:"id"`
+	CreatedBy  string      `json:"createdBy"`
+	Status     string      `json:"status"`
+	Total      int         `json:"total"`
+	ApprovedBy string      `json:"approvedBy,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	Items      []GrantItem `json:"items"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Identity links the subject of an identity provider to a store user.
+type Identity struct {
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastLoginAt time.Time `json:"lastLoginAt"`
+}
+
+// SSOLogin is a login waiting for the identity provider to redirect back.
+// Verifier is the PKCE code verifier of the authorization request.
+type SSOLogin struct {
+	State     string
+	Nonce     string
+	Verifier  string
+	ExpiresAt time.Time
+}
@@ -1,7 +1,10 @@
 package model
 
 type InventoryItem struct {
-	UserID   int
-	ItemName string
-	Quantity int
+	UserID    int
+	ItemName  string
+	Quantity  int
+	VariantID int
+	Size      string
+	Color     string
 }
@@ -1,6 +1,23 @@
 package model
 
+// Item is an item the store is seeded with, its catalog metadata is managed by admins.
 type Item struct {
 	Name  string
 	Price int
 }
+
+// ItemDetails is the catalog metadata of an item. Items are listed by
+// DisplayOrder first, items with equal order keep their original order.
+type ItemDetails struct {
+	Category     string   `json:"category,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	ImageURL     string   `json:"imageUrl,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	DisplayOrder int      `json:"displayOrder"`
+}
+
+// CatalogFilter narrows the catalog down, empty fields match every item.
+type CatalogFilter struct {
+	Category string
+	Tag      string
+}
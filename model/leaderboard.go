@@ -0,0 +1,26 @@
+package model
+
+const (
+	BoardBalance   = "balance"
+	BoardSenders   = "senders"
+	BoardReceivers = "receivers"
+	BoardPurchases = "purchases"
+)
+
+const (
+	PeriodWeek    = "week"
+	PeriodMonth   = "month"
+	PeriodAllTime = "all"
+)
+
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	Username string `json:"username"`
+	Value    int    `json:"value"`
+}
+
+type Leaderboard struct {
+	Board   string             `json:"board"`
+	Period  string             `json:"period"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Sources of coin lots, expiry policies are configured per source.
+const (
+	LotInitial   = "initial"
+	LotAllowance = "allowance"
+	LotTransfer  = "transfer"
+	LotGrant     = "grant"
+	LotRefund    = "refund"
+	LotReward    = "reward"
+)
+
+type CoinLot struct {
+	ID        int
+	UserID    int
+	Amount    int
+	Remaining int
+	Source    string
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+}
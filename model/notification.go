@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+const (
+	NotificationCoinsReceived = "coins_received"
+	NotificationOrderStatus   = "order_status"
+	NotificationGrant         = "grant"
+)
+
+const (
+	ChannelInbox   = "inbox"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+type Notification struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+// NotificationPreferences are the channels a user is notified through.
+// WebhookSecret signs the webhook requests.
+type NotificationPreferences struct {
+	Username      string `json:"-"`
+	Inbox         bool   `json:"inbox"`
+	Email         bool   `json:"email"`
+	EmailAddress  string `json:"emailAddress,omitempty"`
+	Webhook       bool   `json:"webhook"`
+	WebhookURL    string `json:"webhookUrl,omitempty"`
+	WebhookSecret string `json:"webhookSecret,omitempty"`
+}
+
+// Delivery is a notification to be sent through an outside channel.
+type Delivery struct {
+	ID           int
+	Channel      string
+	Attempts     int
+	Notification Notification
+}
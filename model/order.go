@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+const (
+	OrderPlaced    = "placed"
+	OrderReady     = "ready_for_pickup"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
+// Order is the handover of a purchased item. It is placed with the purchase,
+// gets ready for pickup at the chosen location and ends delivered or cancelled.
+type Order struct {
+	ID         int             `json:"id"`
+	PurchaseID int             `json:"purchaseId"`
+	Username   string          `json:"username"`
+	Item       string          `json:"item"`
+	VariantID  int             `json:"variantId,omitempty"`
+	Size       string          `json:"size,omitempty"`
+	Color      string          `json:"color,omitempty"`
+	Refund     int             `json:"refund"`
+	Status     string          `json:"status"`
+	Location   *PickupLocation `json:"location,omitempty"`
+	HandledBy  string          `json:"handledBy,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+type PickupLocation struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	Active  bool   `json:"active"`
+}
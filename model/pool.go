@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+	PoolOpen      = "open"
+	PoolCompleted = "completed"
+	PoolCancelled = "cancelled"
+	PoolExpired   = "expired"
+)
+
+// Pool collects coins from several users to buy Item for Recipient.
+// Contributions are held in escrow until Collected reaches Target.
+type Pool struct {
+	ID            int                `json:"id"`
+	Item          string             `json:"item"`
+	Recipient     string             `json:"recipient"`
+	Organizer     string             `json:"organizer"`
+	Target        int                `json:"target"`
+	Collected     int                `json:"collected"`
+	Status        string             `json:"status"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	ExpiresAt     time.Time          `json:"expiresAt"`
+	ClosedAt      *time.Time         `json:"closedAt,omitempty"`
+	Contributions []PoolContribution `json:"contributions,omitempty"`
+}
+
+type PoolContribution struct {
+	Username  string    `json:"username"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+const (
+	ScheduleActive = "active"
+	SchedulePaused = "paused"
+)
+
+const (
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+)
+
+// Schedule pays Amount coins to every user once per Period.
+// When MaxBalance is set, balances are topped up to it at most.
+type Schedule struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Amount     int       `json:"amount"`
+	MaxBalance *int      `json:"maxBalance,omitempty"`
+	Period     string    `json:"period"`
+	Status     string    `json:"status"`
+	NextRunAt  time.Time `json:"nextRunAt"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type ScheduleRun struct {
+	ID            int       `json:"id"`
+	ScheduleID    int       `json:"scheduleId"`
+	PeriodStart   time.Time `json:"periodStart"`
+	Status        string    `json:"status"`
+	UsersCredited int       `json:"usersCredited"`
+	CoinsTotal    int       `json:"coinsTotal"`
+	Error         string    `json:"error,omitempty"`
+	FinishedAt    time.Time `json:"finishedAt"`
+}
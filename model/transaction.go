@@ -2,10 +2,21 @@ package model
 
 import "time"
 
+const (
+	TransactionCompleted = "completed"
+	TransactionPending   = "pending"
+	TransactionDeclined  = "declined"
+	TransactionCancelled = "cancelled"
+	TransactionExpired   = "expired"
+)
+
 type Transaction struct {
 	ID           int
 	SenderName   string
 	ReceiverName string
 	Amount       int
 	CreatedAt    time.Time
+	Reason       string
+	Status       string
+	ExpiresAt    *time.Time
 }
@@ -0,0 +1,24 @@
+package model
+
+const (
+	UpdateBalance          = "balance"
+	UpdateTransferSent     = "transfer_sent"
+	UpdateTransferReceived = "transfer_received"
+	UpdatePurchase         = "purchase"
+)
+
+// Update is pushed to the live streams of a user when their balance or history changes.
+type Update struct {
+	Type string `json:"type"`
+	// Coins is the balance after the change.
+	Coins    int             `json:"coins"`
+	Transfer *Transaction    `json:"transfer,omitempty"`
+	Purchase *PurchaseUpdate `json:"purchase,omitempty"`
+}
+
+type PurchaseUpdate struct {
+	Item  string `json:"item"`
+	Size  string `json:"size,omitempty"`
+	Color string `json:"color,omitempty"`
+	Price int    `json:"price"`
+}
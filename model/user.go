@@ -1,7 +1,10 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       int
-	Username string
-	Coins    int
+	ID        int
+	Username  string
+	Coins     int
+	CreatedAt time.Time
 }
@@ -0,0 +1,24 @@
+package model
+
+import "strings"
+
+// ItemVariant is a size and color of an item. Its price is the item price plus PriceDelta.
+type ItemVariant struct {
+	ID         int    `json:"id"`
+	Item       string `json:"item"`
+	Size       string `json:"size,omitempty"`
+	Color      string `json:"color,omitempty"`
+	Stock      int    `json:"stock"`
+	PriceDelta int    `json:"priceDelta"`
+}
+
+// Label names the variant for people, e.g. "M/black".
+func (v ItemVariant) Label() string {
+	var parts []string
+	for _, p := range []string{v.Size, v.Color} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
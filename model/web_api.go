@@ -11,6 +11,7 @@ type AuthResponseWeb struct {
 
 type ErrorResponseWeb struct {
 	Errors string `json:"errors"`
+	Code   string `json:"code,omitempty"`
 }
 
 type InfoResponseWeb struct {
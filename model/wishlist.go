@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+	WishlistPrivate = "private"
+	WishlistPublic  = "public"
+)
+
+type Wishlist struct {
+	Username   string         `json:"username"`
+	Visibility string         `json:"visibility"`
+	Items      []WishlistItem `json:"items"`
+}
+
+type WishlistItem struct {
+	Item    string    `json:"item"`
+	Price   int       `json:"price"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// CatalogItem is an item of the store as shown in the catalog. Price is the
+// current price, OriginalPrice the price without sales.
+type CatalogItem struct {
+	Name          string `json:"name"`
+	Price         int    `json:"price"`
+	OriginalPrice int    `json:"originalPrice"`
+	Wishes        int    `json:"wishes"`
+	ItemDetails
+
+	Variants []ItemVariant `json:"variants,omitempty"`
+}
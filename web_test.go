@@ -2,14 +2,39 @@ package main
 
 import (
 	"avito-merch-store/internal/auth"
+	"avito-merch-store/internal/merchant"
+	"avito-merch-store/internal/notify"
+	"avito-merch-store/internal/ratelimit"
+	"avito-merch-store/internal/rpc/storepb"
+	"avito-merch-store/internal/sso"
+	"avito-merch-store/internal/storage"
 	"avito-merch-store/internal/storage/postgres"
+	"avito-merch-store/internal/web"
 	"avito-merch-store/model"
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -33,6 +58,11 @@ type InfoResponse struct {
 type InventoryItem struct {
 	Type     string `json:"type"`
 	Quantity int    `json:"quantity"`
+	Variants []struct {
+		Size     string `json:"size"`
+		Color    string `json:"color"`
+		Quantity int    `json:"quantity"`
+	} `json:"variants"`
 }
 
 type CoinHistory struct {
@@ -95,10 +125,58 @@ func getAuthToken(t *testing.T, serverURL string, usr string, pass string) strin
 	return authResp.Token
 }
 
+func doRequest(t *testing.T, serverURL string, headers map[string]string, method string, path string, body string,
+	out any) int {
+	req, err := http.NewRequest(method, serverURL+path, bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatalf("Ошибка создания запроса %s: %v", path, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка выполнения запроса %s: %v", path, err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}(res.Body)
+	if out != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("Ошибка декодирования ответа %s: %v", path, err)
+		}
+	}
+	return res.StatusCode
+}
+
 func TestAPI(t *testing.T) {
 	i := 0
 
 	ptx := os.Getenv("POSTGRES_PATH")
+	// the suite authenticates many users from one address
+	t.Setenv("RATE_LIMIT_BACKEND", "off")
+	t.Setenv("ADMIN_USERS", "testuser")
+	t.Setenv("IMAGE_DIR", t.TempDir())
+	t.Setenv("NOTIFY_INTERVAL", "100ms")
+	eventsFile := filepath.Join(t.TempDir(), "events.jsonl")
+	t.Setenv("EVENTS_FILE", eventsFile)
+	t.Setenv("EVENTS_RELAY_INTERVAL", "100ms")
+	t.Setenv("GRANT_APPROVAL_THRESHOLD", "1000")
+	t.Setenv("TRANSFER_MAX_AMOUNT", "500")
+	idp, err := sso.NewMockProvider("merch-store", "secret")
+	if err != nil {
+		log.Fatal(err)
+	}
+	idpServer := httptest.NewServer(idp)
+	defer idpServer.Close()
+	t.Setenv("OIDC_ISSUER", idpServer.URL)
+	t.Setenv("OIDC_CLIENT_ID", "merch-store")
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_REDIRECT_URL", "http://127.0.0.1:8080/api/auth/sso/callback")
 	items := []model.Item{
 		{"t-shirt", 80},
 		{"cup", 20},
@@ -111,7 +189,7 @@ func TestAPI(t *testing.T) {
 		{"wallet", 50},
 		{"pink-hoody", 500},
 	}
-	err := postgres.DownMigrations(ptx, "/internal/storage/postgres/migrations")
+	err = postgres.DownMigrations(ptx, "/internal/storage/postgres/migrations")
 	err = postgres.UpMigrations(ptx, "/internal/storage/postgres/migrations")
 
 	stor, err := postgres.CreateAuthStoragePostgres(ptx)
@@ -130,7 +208,7 @@ func TestAPI(t *testing.T) {
 	var wg1 sync.WaitGroup
 
 	wg1.Add(1)
-	go runServer(ptx, "8080", au, stor, a, b, c, d, &wg1)
+	go runServer(context.Background(), ptx, "/internal/storage/postgres/migrations", "8080", au, stor, a, b, c, d, &wg1)
 	wg1.Wait()
 
 	URL := "http://127.0.0.1:8080"
@@ -357,4 +435,1412 @@ func TestAPI(t *testing.T) {
 			t.Errorf("Ожидался статус 400 для некорректного item, получен %d", res.StatusCode)
 		}
 	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		res, err := http.Get(URL + "/metrics")
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /metrics: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Ожидался статус 200 от /metrics, получен %d", res.StatusCode)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("Ошибка чтения ответа /metrics: %v", err)
+		}
+		for _, name := range []string{"merch_http_requests_total", "merch_purchases_total", "merch_coins_transferred_total"} {
+			if !bytes.Contains(body, []byte(name)) {
+				t.Errorf("Метрика %s отсутствует в ответе /metrics", name)
+			}
+		}
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		req, err := http.NewRequest("GET", URL+"/api/info", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/info: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("X-Request-ID", "test-request-id")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/info: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		if id := res.Header.Get("X-Request-ID"); id != "test-request-id" {
+			t.Errorf("Ожидался X-Request-ID test-request-id, получен %q", id)
+		}
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/readyz"} {
+			res, err := http.Get(URL + path)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса %s: %v", path, err)
+			}
+			var report struct {
+				Status string `json:"status"`
+			}
+			err = json.NewDecoder(res.Body).Decode(&report)
+			if closeErr := res.Body.Close(); closeErr != nil {
+				log.Println(closeErr)
+			}
+			if err != nil {
+				t.Fatalf("Ошибка декодирования ответа %s: %v", path, err)
+			}
+			if res.StatusCode != http.StatusOK || report.Status != "up" {
+				t.Errorf("Ожидался статус 200 и up от %s, получен %d и %s", path, res.StatusCode, report.Status)
+			}
+		}
+	})
+
+	t.Run("Tracing", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		otel.SetTracerProvider(provider)
+		defer provider.Shutdown(context.Background())
+
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+		req, err := http.NewRequest("GET", URL+"/api/info", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/info: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/info: %v", err)
+		}
+		res.Body.Close()
+
+		// the server span ends right after the response is written
+		var spans tracetest.SpanStubs
+		find := func(name string, parent tracetest.SpanStub) *tracetest.SpanStub {
+			for _, span := range spans {
+				if strings.HasPrefix(span.Name, name) && span.Parent.SpanID() == parent.SpanContext.SpanID() {
+					return &span
+				}
+			}
+			return nil
+		}
+		var serverSpan, merchantSpan, storageSpan *tracetest.SpanStub
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			spans = exporter.GetSpans()
+			for _, span := range spans {
+				if span.Name == "GET /api/info" && span.SpanContext.TraceID().String() == traceID {
+					serverSpan = &span
+				}
+			}
+			if serverSpan == nil {
+				continue
+			}
+			if merchantSpan = find("Merchant.GetInfoByUsername", *serverSpan); merchantSpan == nil {
+				continue
+			}
+			if storageSpan = find("users.", *merchantSpan); storageSpan != nil {
+				break
+			}
+		}
+		if serverSpan == nil || merchantSpan == nil || storageSpan == nil {
+			t.Fatalf("Ожидалась цепочка спанов HTTP -> merchant -> storage, получено %+v %+v %+v",
+				serverSpan, merchantSpan, storageSpan)
+		}
+		if serverSpan.SpanKind != trace.SpanKindServer || storageSpan.SpanKind != trace.SpanKindClient {
+			t.Errorf("Ожидались виды спанов server и client, получено %v и %v",
+				serverSpan.SpanKind, storageSpan.SpanKind)
+		}
+	})
+
+	t.Run("Grants", func(t *testing.T) {
+		anotherHeader := "Bearer " + getAuthToken(t, URL, "anotherUser", "password")
+		cases := []struct {
+			name   string
+			header string
+			body   string
+			status int
+		}{
+			{"NotAdmin", anotherHeader, `{"grants": [{"username": "testuser", "amount": 5, "reason": "bonus"}]}`, http.StatusForbidden},
+			{"Applied", authHeader, `{"grants": [{"username": "anotherUser", "amount": 5, "reason": "bonus"}]}`, http.StatusOK},
+			{"NeedsApproval", authHeader, `{"grants": [{"username": "anotherUser", "amount": 5000, "reason": "bonus"}]}`, http.StatusAccepted},
+			{"UnknownUser", authHeader, `{"grants": [{"username": "nobody", "amount": 5, "reason": "bonus"}]}`, http.StatusUnprocessableEntity},
+			{"NoReason", authHeader, `{"grants": [{"username": "anotherUser", "amount": 5}]}`, http.StatusBadRequest},
+		}
+		for _, c := range cases {
+			req, err := http.NewRequest("POST", URL+"/api/admin/grants", bytes.NewReader([]byte(c.body)))
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса /api/admin/grants: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", c.header)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса /api/admin/grants: %v", err)
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != c.status {
+				t.Errorf("%s: ожидался статус %d, получен %d", c.name, c.status, res.StatusCode)
+			}
+		}
+	})
+
+	t.Run("Schedules", func(t *testing.T) {
+		body := []byte(`{"name": "monthly allowance", "amount": 100, "period": "monthly", "maxBalance": 2000}`)
+		req, err := http.NewRequest("POST", URL+"/api/admin/schedules", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/admin/schedules: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/admin/schedules: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("Ожидался статус 201 при создании расписания, получен %d", res.StatusCode)
+		}
+		var schedule struct {
+			ID     int    `json:"id"`
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&schedule); err != nil {
+			t.Fatalf("Ошибка декодирования ответа /api/admin/schedules: %v", err)
+		}
+		if schedule.Status != "active" {
+			t.Errorf("Ожидалось активное расписание, получено %s", schedule.Status)
+		}
+	})
+
+	t.Run("SendCoin_TooLarge", func(t *testing.T) {
+		body, err := json.Marshal(SendCoinRequest{ToUser: "anotherUser", Amount: 600})
+		if err != nil {
+			t.Fatalf("Ошибка маршалинга запроса /api/sendCoin: %v", err)
+		}
+		req, err := http.NewRequest("POST", URL+"/api/sendCoin", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/sendCoin: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/sendCoin: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		var errResp struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&errResp); err != nil {
+			t.Fatalf("Ошибка декодирования ответа /api/sendCoin: %v", err)
+		}
+		if res.StatusCode != http.StatusBadRequest || errResp.Code != "transfer_too_large" {
+			t.Errorf("Ожидался статус 400 и код transfer_too_large, получен %d и %s", res.StatusCode, errResp.Code)
+		}
+	})
+
+	t.Run("Transfers_NotFound", func(t *testing.T) {
+		req, err := http.NewRequest("POST", URL+"/api/transfers/999999/accept", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/transfers: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/transfers: %v", err)
+		}
+		if err := res.Body.Close(); err != nil {
+			log.Println(err)
+		}
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("Ожидался статус 404 для несуществующего перевода, получен %d", res.StatusCode)
+		}
+	})
+
+	t.Run("PendingTransfers", func(t *testing.T) {
+		pending := httptest.NewServer(web.NewService(stor, au,
+			merchant.CreateMerchant(a, b, c, d, merchant.WithPendingTransfers(time.Hour))))
+		defer pending.Close()
+		expiring := httptest.NewServer(web.NewService(stor, au,
+			merchant.CreateMerchant(a, b, c, d, merchant.WithPendingTransfers(100*time.Millisecond))))
+		defer expiring.Close()
+		sender := map[string]string{"Authorization": "Bearer " + getAuthToken(t, URL, "pending_sender", "password")}
+		receiver := map[string]string{"Authorization": "Bearer " + getAuthToken(t, URL, "pending_receiver", "password")}
+		coins := func(headers map[string]string) int {
+			var info InfoResponse
+			if code := doRequest(t, URL, headers, "GET", "/api/info", "", &info); code != http.StatusOK {
+				t.Fatalf("Ожидался статус 200 от /api/info, получен %d", code)
+			}
+			return info.Coins
+		}
+		send := func(serverURL string, amount int) int {
+			var transfer model.Transaction
+			body := fmt.Sprintf(`{"toUser": "pending_receiver", "amount": %d}`, amount)
+			code := doRequest(t, serverURL, sender, "POST", "/api/sendCoin", body, &transfer)
+			if code != http.StatusAccepted || transfer.Status != model.TransactionPending {
+				t.Fatalf("Ожидался статус 202 и ожидающий перевод, получен %d и %q", code, transfer.Status)
+			}
+			return transfer.ID
+		}
+
+		id := send(pending.URL, 100)
+		if got := coins(sender); got != 900 {
+			t.Errorf("Ожидалось 900 монет у отправителя после резервирования, получено %d", got)
+		}
+		if got := coins(receiver); got != 1000 {
+			t.Errorf("Ожидалось 1000 монет у получателя до принятия перевода, получено %d", got)
+		}
+		if code := doRequest(t, pending.URL, receiver, "POST", fmt.Sprintf("/api/transfers/%d/accept", id), "", nil); code != http.StatusOK {
+			t.Fatalf("Ожидался статус 200 при принятии перевода, получен %d", code)
+		}
+		if got := coins(receiver); got != 1100 {
+			t.Errorf("Ожидалось 1100 монет у получателя после принятия перевода, получено %d", got)
+		}
+
+		id = send(pending.URL, 50)
+		if got := coins(sender); got != 850 {
+			t.Errorf("Ожидалось 850 монет у отправителя после резервирования, получено %d", got)
+		}
+		path := fmt.Sprintf("/api/transfers/%d/decline", id)
+		if code := doRequest(t, pending.URL, receiver, "POST", path, "", nil); code != http.StatusOK {
+			t.Fatalf("Ожидался статус 200 при отклонении перевода, получен %d", code)
+		}
+		if got := coins(sender); got != 900 {
+			t.Errorf("Ожидалось 900 монет у отправителя после отклонения перевода, получено %d", got)
+		}
+		if code := doRequest(t, pending.URL, receiver, "POST", path, "", nil); code != http.StatusConflict {
+			t.Errorf("Ожидался статус 409 при повторном отклонении перевода, получен %d", code)
+		}
+
+		id = send(expiring.URL, 30)
+		time.Sleep(200 * time.Millisecond)
+		if code := doRequest(t, expiring.URL, receiver, "POST", fmt.Sprintf("/api/transfers/%d/accept", id), "", nil); code != http.StatusConflict {
+			t.Errorf("Ожидался статус 409 при принятии просроченного перевода, получен %d", code)
+		}
+		if got := coins(receiver); got != 1100 {
+			t.Errorf("Просроченный перевод не должен дойти до получателя, получено %d монет", got)
+		}
+		if err := merchant.CreatePendingReverter(c, time.Hour).RevertExpired(context.Background()); err != nil {
+			t.Fatalf("Ошибка возврата просроченных переводов: %v", err)
+		}
+		if got := coins(sender); got != 900 {
+			t.Errorf("Ожидалось 900 монет у отправителя после возврата просроченного перевода, получено %d", got)
+		}
+		transfer, err := c.GetTransaction(context.Background(), id)
+		if err != nil || transfer.Status != model.TransactionExpired {
+			t.Errorf("Ожидался статус перевода %q, получен %v (%v)", model.TransactionExpired, transfer, err)
+		}
+	})
+
+	t.Run("CoinRequests", func(t *testing.T) {
+		anotherHeader := "Bearer " + getAuthToken(t, URL, "anotherUser", "password")
+		body := []byte(`{"from": ["testuser"], "amount": 5, "note": "team gift"}`)
+		req, err := http.NewRequest("POST", URL+"/api/coinRequests", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/coinRequests: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", anotherHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/coinRequests: %v", err)
+		}
+		var requests []struct {
+			ID int `json:"id"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&requests)
+		if closeErr := res.Body.Close(); closeErr != nil {
+			log.Println(closeErr)
+		}
+		if res.StatusCode != http.StatusCreated || err != nil || len(requests) != 1 {
+			t.Fatalf("Ожидался статус 201 и один запрос монет, получен %d", res.StatusCode)
+		}
+
+		payURL := fmt.Sprintf("%s/api/coinRequests/%d/pay", URL, requests[0].ID)
+		for _, status := range []int{http.StatusOK, http.StatusConflict} {
+			req, err := http.NewRequest("POST", payURL, nil)
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса оплаты: %v", err)
+			}
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса оплаты: %v", err)
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != status {
+				t.Errorf("Ожидался статус %d при оплате запроса, получен %d", status, res.StatusCode)
+			}
+		}
+	})
+
+	t.Run("Pools", func(t *testing.T) {
+		body := []byte(`{"item": "cup", "recipient": "anotherUser"}`)
+		req, err := http.NewRequest("POST", URL+"/api/pools", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/pools: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/pools: %v", err)
+		}
+		var pool struct {
+			ID     int    `json:"id"`
+			Target int    `json:"target"`
+			Status string `json:"status"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&pool)
+		if closeErr := res.Body.Close(); closeErr != nil {
+			log.Println(closeErr)
+		}
+		if res.StatusCode != http.StatusCreated || err != nil || pool.Target != 20 {
+			t.Fatalf("Ожидался статус 201 и цель 20 монет, получен %d и %d", res.StatusCode, pool.Target)
+		}
+
+		contributeURL := fmt.Sprintf("%s/api/pools/%d/contribute", URL, pool.ID)
+		steps := []struct {
+			amount int
+			status int
+			pool   string
+		}{
+			{15, http.StatusOK, "open"},
+			{10, http.StatusBadRequest, ""},
+			{5, http.StatusOK, "completed"},
+		}
+		for _, step := range steps {
+			body := []byte(fmt.Sprintf(`{"amount": %d}`, step.amount))
+			req, err := http.NewRequest("POST", contributeURL, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса взноса: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса взноса: %v", err)
+			}
+			pool.Status = ""
+			if res.StatusCode == http.StatusOK {
+				if err := json.NewDecoder(res.Body).Decode(&pool); err != nil {
+					t.Fatalf("Ошибка декодирования ответа взноса: %v", err)
+				}
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != step.status || pool.Status != step.pool {
+				t.Errorf("Взнос %d: ожидался статус %d и пул %q, получен %d и %q",
+					step.amount, step.status, step.pool, res.StatusCode, pool.Status)
+			}
+		}
+		history, err := c.GetTransactionHistory(context.Background(), "testuser", -1)
+		if err != nil {
+			t.Fatalf("Ошибка получения истории: %v", err)
+		}
+		reason := fmt.Sprintf("pool %d: cup for anotherUser", pool.ID)
+		if !slices.ContainsFunc(history, func(tr model.Transaction) bool {
+			return tr.Reason == reason && tr.Amount == 20 && tr.ReceiverName == model.SystemUser
+		}) {
+			t.Errorf("Ожидалась запись о взносах в пул в истории транзакций")
+		}
+	})
+
+	t.Run("Wishlist", func(t *testing.T) {
+		anotherHeader := "Bearer " + getAuthToken(t, URL, "anotherUser", "password")
+		steps := []struct {
+			method string
+			path   string
+			header string
+			body   string
+			status int
+		}{
+			{"PUT", "/api/wishlist/items/hoody", authHeader, "", http.StatusOK},
+			{"PUT", "/api/wishlist/items/yacht", authHeader, "", http.StatusBadRequest},
+			{"GET", "/api/users/testuser/wishlist", anotherHeader, "", http.StatusNotFound},
+			{"PUT", "/api/wishlist/visibility", authHeader, `{"visibility": "public"}`, http.StatusOK},
+			{"GET", "/api/users/testuser/wishlist", anotherHeader, "", http.StatusOK},
+		}
+		for _, step := range steps {
+			req, err := http.NewRequest(step.method, URL+step.path, bytes.NewReader([]byte(step.body)))
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса %s: %v", step.path, err)
+			}
+			req.Header.Set("Authorization", step.header)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса %s: %v", step.path, err)
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != step.status {
+				t.Errorf("%s %s: ожидался статус %d, получен %d", step.method, step.path, step.status, res.StatusCode)
+			}
+		}
+
+		req, err := http.NewRequest("GET", URL+"/api/merch", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/merch: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/merch: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		var catalog []struct {
+			Name   string `json:"name"`
+			Wishes int    `json:"wishes"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&catalog); err != nil {
+			t.Fatalf("Ошибка декодирования ответа /api/merch: %v", err)
+		}
+		for _, item := range catalog {
+			if item.Name == "hoody" && item.Wishes != 1 {
+				t.Errorf("Ожидалось одно пожелание для hoody, получено %d", item.Wishes)
+			}
+		}
+	})
+
+	t.Run("Leaderboard", func(t *testing.T) {
+		get := func(query string) (int, []string) {
+			req, err := http.NewRequest("GET", URL+"/api/leaderboard"+query, nil)
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса /api/leaderboard: %v", err)
+			}
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса /api/leaderboard: %v", err)
+			}
+			defer func(Body io.ReadCloser) {
+				err := Body.Close()
+				if err != nil {
+					log.Println(err)
+				}
+			}(res.Body)
+			var board struct {
+				Entries []struct {
+					Username string `json:"username"`
+				} `json:"entries"`
+			}
+			var names []string
+			if res.StatusCode == http.StatusOK {
+				if err := json.NewDecoder(res.Body).Decode(&board); err != nil {
+					t.Fatalf("Ошибка декодирования ответа /api/leaderboard: %v", err)
+				}
+				for _, e := range board.Entries {
+					names = append(names, e.Username)
+				}
+			}
+			return res.StatusCode, names
+		}
+
+		if status, _ := get("?board=luck"); status != http.StatusBadRequest {
+			t.Errorf("Ожидался статус 400 для неизвестной таблицы, получен %d", status)
+		}
+		status, names := get("?board=balance&limit=100")
+		if status != http.StatusOK || !slices.Contains(names, "testuser") {
+			t.Fatalf("Ожидался testuser в таблице балансов, получен %d и %v", status, names)
+		}
+
+		req, err := http.NewRequest("PUT", URL+"/api/leaderboard/optOut", bytes.NewReader([]byte(`{"optOut": true}`)))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/leaderboard/optOut: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/leaderboard/optOut: %v", err)
+		}
+		if err := res.Body.Close(); err != nil {
+			log.Println(err)
+		}
+		if _, names := get("?board=balance&limit=100"); slices.Contains(names, "testuser") {
+			t.Errorf("testuser отказался от таблиц, но остался в них: %v", names)
+		}
+	})
+
+	t.Run("Achievements", func(t *testing.T) {
+		req, err := http.NewRequest("GET", URL+"/api/info", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/info: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/info: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		var info struct {
+			Badges []struct {
+				Code string `json:"code"`
+			} `json:"badges"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+			t.Fatalf("Ошибка декодирования ответа /api/info: %v", err)
+		}
+		if len(info.Badges) == 0 || info.Badges[0].Code != "first_purchase" {
+			t.Errorf("Ожидалось достижение first_purchase после покупки, получено %v", info.Badges)
+		}
+	})
+
+	t.Run("Discounts", func(t *testing.T) {
+		steps := []struct {
+			method string
+			path   string
+			body   string
+			status int
+		}{
+			{"POST", "/api/admin/sales", `{"item": "pen", "kind": "percent", "value": 50, "endsAt": "2100-01-01T00:00:00Z"}`, http.StatusCreated},
+			{"POST", "/api/admin/promoCodes", `{"code": "PEN5", "item": "pen", "kind": "fixed", "value": 5}`, http.StatusCreated},
+			{"GET", "/api/buy/pen?promo=pen5", "", http.StatusOK},
+			{"GET", "/api/buy/pen?promo=PEN5", "", http.StatusBadRequest},
+			{"GET", "/api/buy/cup?promo=PEN5", "", http.StatusBadRequest},
+		}
+		for _, step := range steps {
+			req, err := http.NewRequest(step.method, URL+step.path, bytes.NewReader([]byte(step.body)))
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса %s: %v", step.path, err)
+			}
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса %s: %v", step.path, err)
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != step.status {
+				t.Errorf("%s %s: ожидался статус %d, получен %d", step.method, step.path, step.status, res.StatusCode)
+			}
+		}
+
+		req, err := http.NewRequest("GET", URL+"/api/merch", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/merch: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/merch: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		var catalog []struct {
+			Name          string `json:"name"`
+			Price         int    `json:"price"`
+			OriginalPrice int    `json:"originalPrice"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&catalog); err != nil {
+			t.Fatalf("Ошибка декодирования ответа /api/merch: %v", err)
+		}
+		for _, item := range catalog {
+			if item.Name == "pen" && (item.Price != 5 || item.OriginalPrice != 10) {
+				t.Errorf("Ожидалась цена pen 5 вместо 10, получено %d вместо %d", item.Price, item.OriginalPrice)
+			}
+		}
+	})
+
+	t.Run("Variants", func(t *testing.T) {
+		steps := []struct {
+			method string
+			path   string
+			body   string
+			status int
+		}{
+			{"POST", "/api/admin/merch/wallet/variants", `{"size": "L", "color": "black", "stock": 1, "priceDelta": 5}`, http.StatusCreated},
+			{"POST", "/api/admin/merch/wallet/variants", `{"size": "L", "color": "black", "stock": 1}`, http.StatusConflict},
+			{"GET", "/api/buy/wallet", "", http.StatusBadRequest},
+			{"GET", "/api/buy/wallet?size=S&color=black", "", http.StatusBadRequest},
+			{"GET", "/api/buy/wallet?size=L&color=black", "", http.StatusOK},
+			{"GET", "/api/buy/wallet?size=L&color=black", "", http.StatusConflict},
+			{"POST", "/api/pools", `{"item": "wallet", "recipient": "anotherUser"}`, http.StatusBadRequest},
+		}
+		for _, step := range steps {
+			req, err := http.NewRequest(step.method, URL+step.path, bytes.NewReader([]byte(step.body)))
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса %s: %v", step.path, err)
+			}
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса %s: %v", step.path, err)
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != step.status {
+				t.Errorf("%s %s: ожидался статус %d, получен %d", step.method, step.path, step.status, res.StatusCode)
+			}
+		}
+
+		req, err := http.NewRequest("GET", URL+"/api/info", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса /api/info: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса /api/info: %v", err)
+		}
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				log.Println(err)
+			}
+		}(res.Body)
+		var info InfoResponse
+		if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+			t.Fatalf("Ошибка декодирования ответа /api/info: %v", err)
+		}
+		for _, item := range info.Inventory {
+			if item.Type == "wallet" && (len(item.Variants) != 1 || item.Variants[0].Size != "L") {
+				t.Errorf("Ожидался вариант L/black в инвентаре, получено %+v", item.Variants)
+			}
+		}
+	})
+
+	t.Run("Catalog", func(t *testing.T) {
+		steps := []struct {
+			method string
+			path   string
+			body   string
+			status int
+		}{
+			{"PUT", "/api/admin/merch/cup", `{"category": "kitchen", "description": "Mug", "tags": ["Ceramic", "ceramic"], "displayOrder": -1}`, http.StatusOK},
+			{"PUT", "/api/admin/merch/spaceship", `{"category": "kitchen"}`, http.StatusNotFound},
+			{"PUT", "/api/admin/merch/cup/image", "not an image", http.StatusUnsupportedMediaType},
+		}
+		for _, step := range steps {
+			req, err := http.NewRequest(step.method, URL+step.path, bytes.NewReader([]byte(step.body)))
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса %s: %v", step.path, err)
+			}
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса %s: %v", step.path, err)
+			}
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if res.StatusCode != step.status {
+				t.Errorf("%s %s: ожидался статус %d, получен %d", step.method, step.path, step.status, res.StatusCode)
+			}
+		}
+
+		png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+		req, err := http.NewRequest("PUT", URL+"/api/admin/merch/cup/image", bytes.NewReader(png))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса загрузки изображения: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса загрузки изображения: %v", err)
+		}
+		var image struct {
+			ImageURL string `json:"imageUrl"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&image)
+		if err := res.Body.Close(); err != nil {
+			log.Println(err)
+		}
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("Ошибка загрузки изображения: статус %d, %v", res.StatusCode, err)
+		}
+		res, err = http.Get(URL + image.ImageURL)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса %s: %v", image.ImageURL, err)
+		}
+		if err := res.Body.Close(); err != nil {
+			log.Println(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Изображение %s недоступно: статус %d", image.ImageURL, res.StatusCode)
+		}
+
+		for _, path := range []string{"/api/merch?category=kitchen", "/api/merch?tag=CERAMIC"} {
+			req, err := http.NewRequest("GET", URL+path, nil)
+			if err != nil {
+				t.Fatalf("Ошибка создания запроса %s: %v", path, err)
+			}
+			req.Header.Set("Authorization", authHeader)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Ошибка выполнения запроса %s: %v", path, err)
+			}
+			var catalog []struct {
+				Name     string   `json:"name"`
+				Tags     []string `json:"tags"`
+				ImageURL string   `json:"imageUrl"`
+			}
+			err = json.NewDecoder(res.Body).Decode(&catalog)
+			if err := res.Body.Close(); err != nil {
+				log.Println(err)
+			}
+			if err != nil {
+				t.Fatalf("Ошибка декодирования ответа %s: %v", path, err)
+			}
+			if len(catalog) != 1 || catalog[0].Name != "cup" || !slices.Equal(catalog[0].Tags, []string{"ceramic"}) ||
+				catalog[0].ImageURL != image.ImageURL {
+				t.Errorf("%s: ожидался только cup с тегом ceramic, получено %+v", path, catalog)
+			}
+		}
+	})
+
+	t.Run("Fulfillment", func(t *testing.T) {
+		auth := map[string]string{"Authorization": authHeader}
+		type order struct {
+			ID     int    `json:"id"`
+			Item   string `json:"item"`
+			Status string `json:"status"`
+		}
+		lastOrder := func() order {
+			var orders []order
+			if status := doRequest(t, URL, auth, "GET", "/api/orders", "", &orders); status != http.StatusOK || len(orders) == 0 {
+				t.Fatalf("Ожидался список заказов, статус %d", status)
+			}
+			return orders[0]
+		}
+
+		var location struct {
+			ID int `json:"id"`
+		}
+		if status := doRequest(t, URL, auth, "POST", "/api/admin/pickupLocations", `{"name": "Lesnaya 7", "address": "5th floor"}`, &location); status != http.StatusCreated {
+			t.Fatalf("Ошибка создания пункта выдачи: статус %d", status)
+		}
+
+		var before, after InfoResponse
+		doRequest(t, URL, auth, "GET", "/api/info", "", &before)
+		if status := doRequest(t, URL, auth, "GET", "/api/buy/book", "", nil); status != http.StatusOK {
+			t.Fatalf("Ошибка покупки book: статус %d", status)
+		}
+		o := lastOrder()
+		if o.Item != "book" || o.Status != "placed" {
+			t.Fatalf("Ожидался размещенный заказ book, получено %+v", o)
+		}
+		type step struct {
+			method string
+			path   string
+			body   string
+			status int
+		}
+		run := func(steps []step) {
+			for _, step := range steps {
+				if status := doRequest(t, URL, auth, step.method, step.path, step.body, nil); status != step.status {
+					t.Errorf("%s %s: ожидался статус %d, получен %d", step.method, step.path, step.status, status)
+				}
+			}
+		}
+		run([]step{
+			{"POST", fmt.Sprintf("/api/fulfillment/orders/%d/ready", o.ID), "", http.StatusConflict},
+			{"PUT", fmt.Sprintf("/api/orders/%d/location", o.ID), `{"locationId": 0}`, http.StatusBadRequest},
+			{"PUT", fmt.Sprintf("/api/orders/%d/location", o.ID), fmt.Sprintf(`{"locationId": %d}`, location.ID), http.StatusOK},
+			{"POST", fmt.Sprintf("/api/fulfillment/orders/%d/ready", o.ID), "", http.StatusOK},
+			{"POST", fmt.Sprintf("/api/fulfillment/orders/%d/cancel", o.ID), "", http.StatusOK},
+			{"POST", fmt.Sprintf("/api/fulfillment/orders/%d/deliver", o.ID), "", http.StatusConflict},
+		})
+		doRequest(t, URL, auth, "GET", "/api/info", "", &after)
+		if after.Coins != before.Coins {
+			t.Errorf("Ожидался возврат монет за отмененный заказ: было %d, стало %d", before.Coins, after.Coins)
+		}
+		books := func(info InfoResponse) int {
+			for _, item := range info.Inventory {
+				if item.Type == "book" {
+					return item.Quantity
+				}
+			}
+			return 0
+		}
+		if books(after) != books(before) {
+			t.Errorf("Ожидалось изъятие book из инвентаря после отмены: было %d, стало %d", books(before), books(after))
+		}
+
+		doRequest(t, URL, auth, "GET", "/api/buy/book", "", nil)
+		o = lastOrder()
+		run([]step{
+			{"PUT", fmt.Sprintf("/api/orders/%d/location", o.ID), fmt.Sprintf(`{"locationId": %d}`, location.ID), http.StatusOK},
+			{"POST", fmt.Sprintf("/api/fulfillment/orders/%d/ready", o.ID), "", http.StatusOK},
+			{"POST", fmt.Sprintf("/api/fulfillment/orders/%d/deliver", o.ID), "", http.StatusOK},
+			{"POST", fmt.Sprintf("/api/orders/%d/cancel", o.ID), "", http.StatusConflict},
+		})
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		auth := map[string]string{"Authorization": authHeader}
+		another := map[string]string{"Authorization": "Bearer " + getAuthToken(t, URL, "anotherUser", "password")}
+
+		received := make(chan bool, 10)
+		var secret string
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			signature := "sha256=" + notify.Sign(secret, r.Header.Get("X-Notification-Timestamp"), body)
+			received <- r.Header.Get("X-Notification-Signature") == signature
+		}))
+		defer hook.Close()
+
+		if status := doRequest(t, URL, auth, "PUT", "/api/notifications/preferences", `{"inbox": true, "webhook": true, "webhookUrl": "ftp://hook"}`, nil); status != http.StatusBadRequest {
+			t.Errorf("Ожидался статус 400 для неверного webhookUrl, получен %d", status)
+		}
+		var prefs struct {
+			WebhookSecret string `json:"webhookSecret"`
+		}
+		body := fmt.Sprintf(`{"inbox": true, "webhook": true, "webhookUrl": %q}`, hook.URL)
+		if status := doRequest(t, URL, auth, "PUT", "/api/notifications/preferences", body, &prefs); status != http.StatusOK || prefs.WebhookSecret == "" {
+			t.Fatalf("Ошибка сохранения настроек уведомлений: статус %d", status)
+		}
+		secret = prefs.WebhookSecret
+
+		if status := doRequest(t, URL, another, "POST", "/api/sendCoin", `{"toUser": "testuser", "amount": 5}`, nil); status != http.StatusOK {
+			t.Fatalf("Ошибка перевода монет: статус %d", status)
+		}
+		var inbox []struct {
+			Kind  string `json:"kind"`
+			Title string `json:"title"`
+		}
+		doRequest(t, URL, auth, "GET", "/api/notifications?unread=true", "", &inbox)
+		if len(inbox) == 0 || inbox[0].Kind != "coins_received" || inbox[0].Title != "anotherUser sent you 5 coins" {
+			t.Errorf("Ожидалось уведомление о полученных монетах, получено %+v", inbox)
+		}
+		select {
+		case valid := <-received:
+			if !valid {
+				t.Errorf("Неверная подпись webhook")
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Webhook не получил уведомление")
+		}
+
+		var marked struct {
+			Marked int `json:"marked"`
+		}
+		doRequest(t, URL, auth, "POST", "/api/notifications/read", `{}`, &marked)
+		inbox = nil
+		doRequest(t, URL, auth, "GET", "/api/notifications?unread=true", "", &inbox)
+		if marked.Marked == 0 || len(inbox) != 0 {
+			t.Errorf("Ожидалось прочтение всех уведомлений, отмечено %d, осталось %d", marked.Marked, len(inbox))
+		}
+		doRequest(t, URL, auth, "PUT", "/api/notifications/preferences", `{"inbox": true}`, nil)
+	})
+
+	t.Run("EmailTimeout", func(t *testing.T) {
+		// the server accepts connections but never greets
+		server, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Ошибка запуска SMTP сервера: %v", err)
+		}
+		defer server.Close()
+		go func() {
+			for {
+				conn, err := server.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+
+		email := notify.CreateEmail(server.Addr().String(), "merch-store@localhost", "", "", 200*time.Millisecond)
+		prefs := model.NotificationPreferences{Email: true, EmailAddress: "user@example.com"}
+		start := time.Now()
+		if err := email.Send(context.Background(), prefs, model.Notification{Title: "test"}); err == nil {
+			t.Errorf("Ожидалась ошибка отправки письма через молчащий сервер")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Отправка письма должна прерываться по таймауту, заняла %v", elapsed)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		email = notify.CreateEmail(server.Addr().String(), "merch-store@localhost", "", "", time.Minute)
+		start = time.Now()
+		if err := email.Send(ctx, prefs, model.Notification{Title: "test"}); err == nil {
+			t.Errorf("Ожидалась ошибка отправки письма после отмены контекста")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Отправка письма должна прерываться с контекстом, заняла %v", elapsed)
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		reqBody := []byte(`{"toUser": "anotherUser", "amount": 7}`)
+		req, err := http.NewRequest("POST", URL+"/api/sendCoin", bytes.NewReader(reqBody))
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Ошибка перевода монет: статус %d", res.StatusCode)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			data, _ := os.ReadFile(eventsFile)
+			found := false
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				var event struct {
+					ID      string `json:"id"`
+					Type    string `json:"type"`
+					Payload struct {
+						TransferID int    `json:"transferId"`
+						From       string `json:"from"`
+						To         string `json:"to"`
+						Amount     int    `json:"amount"`
+					} `json:"payload"`
+				}
+				if json.Unmarshal([]byte(line), &event) != nil {
+					continue
+				}
+				if event.Type == "CoinsTransferred" && event.Payload.From == "testuser" &&
+					event.Payload.To == "anotherUser" && event.Payload.Amount == 7 {
+					if event.ID == "" || event.Payload.TransferID == 0 {
+						t.Errorf("Неверное событие перевода: %s", line)
+					}
+					found = true
+				}
+			}
+			if found {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Событие CoinsTransferred не опубликовано")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", URL+"/api/stream", nil)
+		if err != nil {
+			t.Fatalf("Ошибка создания запроса: %v", err)
+		}
+		req.Header.Set("Authorization", authHeader)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса: %v", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("Ожидался поток событий, получен статус %d", res.StatusCode)
+		}
+
+		type update struct {
+			Type     string `json:"type"`
+			Coins    int    `json:"coins"`
+			Transfer *struct {
+				SenderName string
+				Amount     int
+			} `json:"transfer"`
+		}
+		updates := make(chan update, 10)
+		go func() {
+			scanner := bufio.NewScanner(res.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+				var u update
+				if ok && json.Unmarshal([]byte(data), &u) == nil {
+					updates <- u
+				}
+			}
+			close(updates)
+		}()
+		next := func() update {
+			select {
+			case u := <-updates:
+				return u
+			case <-ctx.Done():
+				t.Fatalf("Событие не получено")
+			}
+			return update{}
+		}
+
+		balance := next()
+		if balance.Type != "balance" {
+			t.Fatalf("Первым ожидался баланс, получено %+v", balance)
+		}
+		anotherHeader := "Bearer " + getAuthToken(t, URL, "anotherUser", "password")
+		sendReq, _ := http.NewRequest("POST", URL+"/api/sendCoin", bytes.NewReader([]byte(`{"toUser": "testuser", "amount": 3}`)))
+		sendReq.Header.Set("Authorization", anotherHeader)
+		sendRes, err := http.DefaultClient.Do(sendReq)
+		if err != nil {
+			t.Fatalf("Ошибка перевода монет: %v", err)
+		}
+		sendRes.Body.Close()
+
+		// the balance update of the trigger comes with the commit, before the transfer update
+		if changed := next(); changed.Type != "balance" || changed.Coins != balance.Coins+3 {
+			t.Errorf("Ожидалось изменение баланса на 3 монеты, получено %+v", changed)
+		}
+		received := next()
+		if received.Type != "transfer_received" || received.Coins != balance.Coins+3 ||
+			received.Transfer == nil || received.Transfer.SenderName != "anotherUser" {
+			t.Errorf("Ожидалось событие о полученных монетах, получено %+v", received)
+		}
+
+		// paths outside the merchant change the balance too, grants among them
+		code := doRequest(t, URL, map[string]string{"Authorization": authHeader}, "POST", "/api/admin/grants",
+			`{"grants": [{"username": "testuser", "amount": 7, "reason": "stream"}]}`, nil)
+		if code != http.StatusOK {
+			t.Fatalf("Ожидался статус 200 от /api/admin/grants, получен %d", code)
+		}
+		if granted := next(); granted.Type != "balance" || granted.Coins != balance.Coins+10 {
+			t.Errorf("Ожидалось изменение баланса после начисления, получено %+v", granted)
+		}
+	})
+
+	t.Run("GRPC", func(t *testing.T) {
+		conn, err := grpc.NewClient("127.0.0.1:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("Ошибка подключения к gRPC: %v", err)
+		}
+		defer conn.Close()
+		client := storepb.NewMerchStoreClient(conn)
+		ctx := context.Background()
+
+		if _, err := client.GetInfo(ctx, &storepb.GetInfoRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Ожидался код Unauthenticated без токена, получен %v", status.Code(err))
+		}
+		if _, err := client.Auth(ctx, &storepb.AuthRequest{Username: "grpcuser", Password: "wrong"}); err != nil {
+			t.Fatalf("Ошибка регистрации через gRPC: %v", err)
+		}
+		if _, err := client.Auth(ctx, &storepb.AuthRequest{Username: "grpcuser", Password: "other"}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Ожидался код Unauthenticated для неверного пароля, получен %v", status.Code(err))
+		}
+		auth, err := client.Auth(ctx, &storepb.AuthRequest{Username: "grpcuser", Password: "wrong"})
+		if err != nil {
+			t.Fatalf("Ошибка аутентификации через gRPC: %v", err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+auth.Token)
+
+		info, err := client.GetInfo(ctx, &storepb.GetInfoRequest{})
+		if err != nil || info.Coins != 1000 {
+			t.Fatalf("Ожидался баланс 1000, получен %v, ошибка %v", info.GetCoins(), err)
+		}
+		sent, err := client.SendCoin(ctx, &storepb.SendCoinRequest{ToUser: "anotherUser", Amount: 10})
+		if err != nil || sent.Transfer.GetAmount() != 10 || sent.Transfer.GetReceiver() != "anotherUser" {
+			t.Errorf("Ошибка перевода через gRPC: %v", err)
+		}
+		if _, err := client.SendCoin(ctx, &storepb.SendCoinRequest{ToUser: "anotherUser", Amount: 0}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Ожидался код InvalidArgument, получен %v", status.Code(err))
+		}
+		if _, err := client.SendCoin(ctx, &storepb.SendCoinRequest{ToUser: "nobody", Amount: 1}); status.Code(err) != codes.NotFound {
+			t.Errorf("Ожидался код NotFound, получен %v", status.Code(err))
+		}
+		if _, err := client.Buy(ctx, &storepb.BuyRequest{Item: "pen"}); err != nil {
+			t.Errorf("Ошибка покупки через gRPC: %v", err)
+		}
+		if _, err := client.Buy(ctx, &storepb.BuyRequest{Item: "unknown-item"}); status.Code(err) != codes.NotFound {
+			t.Errorf("Ожидался код NotFound для несуществующего товара, получен %v", status.Code(err))
+		}
+		history, err := client.GetTransactions(ctx, &storepb.GetTransactionsRequest{})
+		if err != nil || len(history.Transactions) == 0 {
+			t.Errorf("Ожидалась история переводов, ошибка %v", err)
+		}
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		anotherHeader := "Bearer " + getAuthToken(t, URL, "anotherUser", "password")
+		admin := map[string]string{"Authorization": authHeader}
+
+		body := `{"name": "hr-bot", "scopes": ["users:read", "grants:write"]}`
+		if status := doRequest(t, URL, map[string]string{"Authorization": anotherHeader}, "POST", "/api/admin/apiKeys", body, nil); status != http.StatusForbidden {
+			t.Errorf("Ожидался статус 403 для не администратора, получен %d", status)
+		}
+		if status := doRequest(t, URL, admin, "POST", "/api/admin/apiKeys", `{"name": "bot", "scopes": ["coins:steal"]}`, nil); status != http.StatusBadRequest {
+			t.Errorf("Ожидался статус 400 для неизвестного scope, получен %d", status)
+		}
+		var created struct {
+			Key    string `json:"key"`
+			APIKey struct {
+				ID int `json:"id"`
+			} `json:"apiKey"`
+		}
+		if status := doRequest(t, URL, admin, "POST", "/api/admin/apiKeys", body, &created); status != http.StatusCreated || created.Key == "" {
+			t.Fatalf("Ошибка создания ключа: статус %d", status)
+		}
+		key := map[string]string{"X-API-Key": created.Key}
+
+		var balance struct {
+			Coins int `json:"coins"`
+		}
+		if status := doRequest(t, URL, key, "GET", "/api/integrations/users/anotherUser", "", &balance); status != http.StatusOK || balance.Coins == 0 {
+			t.Errorf("Ошибка чтения баланса по ключу: статус %d", status)
+		}
+		bearer := map[string]string{"Authorization": "Bearer " + created.Key}
+		grant := `{"grants": [{"username": "anotherUser", "amount": 5, "reason": "onboarding"}]}`
+		var batch struct {
+			ID        int    `json:"id"`
+			CreatedBy string `json:"createdBy"`
+		}
+		if status := doRequest(t, URL, bearer, "POST", "/api/integrations/grants", grant, &batch); status != http.StatusOK || batch.CreatedBy != "apikey:hr-bot" {
+			t.Errorf("Ошибка начисления по ключу: статус %d, автор %q", status, batch.CreatedBy)
+		}
+		if status := doRequest(t, URL, key, "GET", fmt.Sprintf("/api/integrations/grants/%d", batch.ID), "", nil); status != http.StatusForbidden {
+			t.Errorf("Ожидался статус 403 без scope grants:read, получен %d", status)
+		}
+		if status := doRequest(t, URL, map[string]string{"X-API-Key": created.Key + "x"}, "GET", "/api/integrations/users/anotherUser", "", nil); status != http.StatusUnauthorized {
+			t.Errorf("Ожидался статус 401 для неверного ключа, получен %d", status)
+		}
+		if status := doRequest(t, URL, map[string]string{"X-API-Key": created.Key}, "GET", "/api/info", "", nil); status != http.StatusUnauthorized {
+			t.Errorf("Ключ не должен заменять токен пользователя, получен статус %d", status)
+		}
+
+		var keys []struct {
+			ID         int        `json:"id"`
+			LastUsedAt *time.Time `json:"lastUsedAt"`
+		}
+		doRequest(t, URL, admin, "GET", "/api/admin/apiKeys", "", &keys)
+		used := false
+		for _, k := range keys {
+			used = used || k.ID == created.APIKey.ID && k.LastUsedAt != nil
+		}
+		if !used {
+			t.Errorf("Ожидалось время последнего использования ключа")
+		}
+		if status := doRequest(t, URL, admin, "DELETE", fmt.Sprintf("/api/admin/apiKeys/%d", created.APIKey.ID), "", nil); status != http.StatusOK {
+			t.Errorf("Ошибка отзыва ключа: статус %d", status)
+		}
+		if status := doRequest(t, URL, key, "GET", "/api/integrations/users/anotherUser", "", nil); status != http.StatusUnauthorized {
+			t.Errorf("Ожидался статус 401 для отозванного ключа, получен %d", status)
+		}
+	})
+
+	t.Run("SSO", func(t *testing.T) {
+		login := func(hint string) (string, int) {
+			// the client follows the redirects to the identity provider and back
+			res, err := http.Get(URL + "/api/auth/sso/login?login_hint=" + hint)
+			if err != nil {
+				t.Fatalf("Ошибка входа через SSO: %v", err)
+			}
+			defer res.Body.Close()
+			var auth AuthResponse
+			if res.StatusCode == http.StatusOK {
+				if err := json.NewDecoder(res.Body).Decode(&auth); err != nil {
+					t.Fatalf("Ошибка декодирования ответа: %v", err)
+				}
+			}
+			return auth.Token, res.StatusCode
+		}
+		token, status := login("jane.doe")
+		if status != http.StatusOK || token == "" {
+			t.Fatalf("Ожидался токен после входа через SSO, получен статус %d", status)
+		}
+		if name, err := au.ValidateKey(token); err != nil || name != "jane.doe" {
+			t.Errorf("Ожидался пользователь jane.doe, получен %q", name)
+		}
+		req, _ := http.NewRequest("GET", URL+"/api/info", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса: %v", err)
+		}
+		var info InfoResponse
+		_ = json.NewDecoder(res.Body).Decode(&info)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || info.Coins != 1000 {
+			t.Errorf("Ожидался новый пользователь с 1000 монет, получен статус %d и %d монет", res.StatusCode, info.Coins)
+		}
+
+		if again, status := login("jane.doe"); status != http.StatusOK || again == "" {
+			t.Errorf("Ошибка повторного входа через SSO: статус %d", status)
+		}
+		reqBody, _ := json.Marshal(AuthRequest{Username: "jane.doe", Password: "guess"})
+		res, err = http.Post(URL+"/api/auth", "application/json", bytes.NewReader(reqBody))
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Пользователь SSO не должен входить по паролю, получен статус %d", res.StatusCode)
+		}
+
+		// an existing password user is not taken over by the same name at the provider
+		taken, status := login("testuser")
+		if name, err := au.ValidateKey(taken); status != http.StatusOK || err != nil || name != "testuser-2" {
+			t.Errorf("Ожидался новый пользователь testuser-2, получен %q со статусом %d", name, status)
+		}
+		res, err = http.Get(URL + "/api/auth/sso/callback?state=forged&code=forged")
+		if err != nil {
+			t.Fatalf("Ошибка выполнения запроса: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("Ожидался статус 400 для чужого state, получен %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Lots", func(t *testing.T) {
+		ctx := context.Background()
+		lots, err := postgres.CreateLotStoragePostgres(ptx)
+		if err != nil {
+			t.Fatalf("Ошибка подключения хранилища лотов: %v", err)
+		}
+		if err := lots.SetExpiryPolicies(ctx, map[string]time.Duration{model.LotTransfer: time.Hour}); err != nil {
+			t.Fatalf("Ошибка установки сроков жизни монет: %v", err)
+		}
+		defer lots.SetExpiryPolicies(ctx, nil)
+		getAuthToken(t, URL, "lots_spender", "password")
+		getAuthToken(t, URL, "lots_sender", "password")
+		for _, amount := range []int{100, 200} {
+			if _, err := c.Transfer(ctx, "lots_sender", "lots_spender", amount, storage.TransferCaps{}); err != nil {
+				t.Fatalf("Ошибка перевода %d монет: %v", amount, err)
+			}
+		}
+
+		// the initial coins and the first transfer are spent before the second transfer
+		if _, err := c.Transfer(ctx, "lots_spender", "lots_sender", 1100, storage.TransferCaps{}); err != nil {
+			t.Fatalf("Ошибка списания монет из нескольких лотов: %v", err)
+		}
+		spender, err := a.GetByUsername(ctx, "lots_spender")
+		if err != nil {
+			t.Fatalf("Ошибка получения пользователя: %v", err)
+		}
+		expiring, err := a.GetExpiringLots(ctx, spender.ID)
+		if err != nil {
+			t.Fatalf("Ошибка получения лотов: %v", err)
+		}
+		if spender.Coins != 200 || len(expiring) != 1 || expiring[0].Amount != 200 || expiring[0].Remaining != 200 {
+			t.Fatalf("Ожидался один нетронутый лот из 200 монет, баланс %d, лоты %+v", spender.Coins, expiring)
+		}
+
+		conn, err := pgx.Connect(ctx, ptx)
+		if err != nil {
+			t.Fatalf("Ошибка подключения к базе: %v", err)
+		}
+		defer conn.Close(ctx)
+		_, err = conn.Exec(ctx, `
+            UPDATE coin_lots SET expires_at = NOW() - INTERVAL '1 minute'
+            WHERE user_id = $1 AND expires_at IS NOT NULL
+        `, spender.ID)
+		if err != nil {
+			t.Fatalf("Ошибка переноса срока жизни лотов: %v", err)
+		}
+		if _, _, err := lots.ExpireLots(ctx, 100); err != nil {
+			t.Fatalf("Ошибка сгорания лотов: %v", err)
+		}
+		// the background expirer may be burning the same lots right now
+		for deadline := time.Now().Add(2 * time.Second); spender.Coins != 0 && time.Now().Before(deadline); {
+			time.Sleep(50 * time.Millisecond)
+			if spender, err = a.GetByUsername(ctx, "lots_spender"); err != nil {
+				t.Fatalf("Ошибка получения пользователя: %v", err)
+			}
+		}
+		if spender.Coins != 0 {
+			t.Errorf("Ожидалось сгорание оставшихся 200 монет, баланс %d", spender.Coins)
+		}
+		history, err := c.GetTransactionHistory(ctx, "lots_spender", -1)
+		if err != nil {
+			t.Fatalf("Ошибка получения истории: %v", err)
+		}
+		burnt := 0
+		for _, tr := range history {
+			if tr.ReceiverName == model.SystemUser {
+				burnt += tr.Amount
+			}
+		}
+		if burnt != 200 {
+			t.Errorf("Потраченные лоты не должны сгорать: ожидалось 200 сгоревших монет, получено %d", burnt)
+		}
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		memory := ratelimit.CreateMemoryLimiter()
+		pg, err := postgres.CreateRateLimitStoragePostgres(ptx)
+		if err != nil {
+			t.Fatalf("Ошибка подключения ограничителя: %v", err)
+		}
+		for name, limiter := range map[string]ratelimit.Limiter{"memory": memory, "postgres": pg} {
+			service := web.NewService(stor, au, merchant.CreateMerchant(a, b, c, d),
+				web.WithRateLimit(limiter, map[string]ratelimit.Policy{"info": ratelimit.PerMinute(1, 2)}))
+			server := httptest.NewServer(service)
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			limited := 0
+			for range 5 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req, _ := http.NewRequest("GET", server.URL+"/api/info", nil)
+					req.Header.Set("Authorization", authHeader)
+					res, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Errorf("%s: ошибка выполнения запроса: %v", name, err)
+						return
+					}
+					res.Body.Close()
+					if res.StatusCode != http.StatusTooManyRequests {
+						return
+					}
+					if res.Header.Get("Retry-After") == "" {
+						t.Errorf("%s: ожидался заголовок Retry-After", name)
+					}
+					mu.Lock()
+					limited++
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+			server.Close()
+			if limited != 3 {
+				t.Errorf("%s: ожидалось 3 отклоненных запроса из 5 при запасе 2, получено %d", name, limited)
+			}
+		}
+
+		if n, err := pg.DeleteIdle(context.Background(), time.Hour); err != nil || n != 0 {
+			t.Errorf("Недавно использованные корзины не должны удаляться, удалено %d, ошибка %v", n, err)
+		}
+		if n, err := pg.DeleteIdle(context.Background(), 0); err != nil || n == 0 {
+			t.Errorf("Ожидалось удаление простаивающих корзин, удалено %d, ошибка %v", n, err)
+		}
+	})
 }